@@ -0,0 +1,116 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// adaptiveBatching adjusts the batch size and the write concurrency of a
+// client to the latency of its writes, so throughput does not have to be
+// tuned by hand per cluster. Like TCP congestion control it is AIMD-style:
+// every write within the target latency grows the batch size by one
+// statement up to the configured batch size, a slow or failed write halves
+// it. Once batches are at their maximum, every publish without congestion
+// adds a worker up to the configured concurrency, a congested one halves
+// the workers. A nil controller keeps the configured values.
+type adaptiveBatching struct {
+	mu      sync.Mutex
+	target  time.Duration
+	maxSize int
+	maxConc int
+	size    int
+	conc    int
+	// congested is set by a slow or failed write since the last publish
+	congested bool
+}
+
+// newAdaptiveBatching returns a controller aiming at writes within target,
+// nil if target is not positive. It starts with single inserts and one
+// worker.
+func newAdaptiveBatching(target time.Duration, maxSize, maxConc int) *adaptiveBatching {
+	if target <= 0 {
+		return nil
+	}
+	if maxSize < 1 {
+		maxSize = 1
+	}
+	if maxConc < 1 {
+		maxConc = 1
+	}
+	return &adaptiveBatching{target: target, maxSize: maxSize, maxConc: maxConc, size: 1, conc: 1}
+}
+
+// observe adjusts the batch size to a write of the latency, failed if err is not nil.
+func (a *adaptiveBatching) observe(latency time.Duration, err error) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err != nil || latency > a.target {
+		a.congested = true
+		if a.size > 1 {
+			a.size /= 2
+			cassaLog.WithFields(log.Fields{
+				"latency":   latency,
+				"err":       err,
+				"batchSize": a.size,
+			}).Debug("Cassandra client shrinks its batches")
+		}
+		return
+	}
+	if a.size < a.maxSize {
+		a.size++
+	}
+}
+
+// batchSize returns the batch size to use, configured if a is nil.
+func (a *adaptiveBatching) batchSize(configured int) int {
+	if a == nil {
+		return configured
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.size
+}
+
+// concurrency returns the number of workers of a publish, configured if a
+// is nil. It adjusts the workers to the writes of the previous publish.
+func (a *adaptiveBatching) concurrency(configured int) int {
+	if a == nil {
+		return configured
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switch {
+	case a.congested:
+		if a.conc > 1 {
+			a.conc /= 2
+		}
+	case a.size >= a.maxSize && a.conc < a.maxConc:
+		a.conc++
+	}
+	a.congested = false
+	return a.conc
+}
@@ -0,0 +1,81 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAdaptiveBatching(t *testing.T) {
+	Convey("Given adaptive batching of up to 4 inserts and 2 workers", t, func() {
+		a := newAdaptiveBatching(100*time.Millisecond, 4, 2)
+
+		Convey("It starts with single inserts and one worker", func() {
+			So(a.batchSize(4), ShouldEqual, 1)
+			So(a.concurrency(2), ShouldEqual, 1)
+		})
+
+		Convey("Fast writes grow the batches up to the batch size", func() {
+			for i := 0; i < 10; i++ {
+				a.observe(10*time.Millisecond, nil)
+			}
+			So(a.batchSize(4), ShouldEqual, 4)
+
+			Convey("And then the workers up to the concurrency", func() {
+				So(a.concurrency(2), ShouldEqual, 2)
+				So(a.concurrency(2), ShouldEqual, 2)
+			})
+		})
+
+		Convey("Slow writes halve the batches", func() {
+			for i := 0; i < 3; i++ {
+				a.observe(10*time.Millisecond, nil)
+			}
+			a.observe(time.Second, nil)
+			So(a.batchSize(4), ShouldEqual, 2)
+		})
+
+		Convey("Failed writes halve the workers of the next publish", func() {
+			for i := 0; i < 3; i++ {
+				a.observe(10*time.Millisecond, nil)
+			}
+			So(a.concurrency(2), ShouldEqual, 2)
+			a.observe(10*time.Millisecond, errors.New("timeout"))
+			So(a.batchSize(4), ShouldEqual, 2)
+			So(a.concurrency(2), ShouldEqual, 1)
+		})
+	})
+
+	Convey("Given no target latency", t, func() {
+		a := newAdaptiveBatching(0, 4, 2)
+
+		Convey("The configured values are kept", func() {
+			So(a, ShouldBeNil)
+			a.observe(time.Second, nil)
+			So(a.batchSize(4), ShouldEqual, 4)
+			So(a.concurrency(2), ShouldEqual, 2)
+		})
+	})
+}
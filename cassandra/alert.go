@@ -0,0 +1,137 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"text/template"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultAlertTemplate is the payload posted to the alert webhook by default,
+// accepted by Slack and Mattermost incoming webhooks.
+const defaultAlertTemplate = `{"text": {{printf "snap Cassandra publisher failing for %s (%d publishes): %s" .Duration .Failures .Error | json}}}`
+
+// alertTimeout bounds the webhook request, so a hanging receiver does not pile up requests.
+const alertTimeout = 10 * time.Second
+
+// alertData is the data the alert template is executed with.
+type alertData struct {
+	// Since is the time of the first failed publish of the outage
+	Since time.Time
+	// Duration is how long publishes have been failing
+	Duration time.Duration
+	// Failures is the number of failed publishes
+	Failures int
+	// Error is the error of the last failed publish
+	Error string
+}
+
+// failureAlert posts to a webhook once publishes have failed continuously
+// for longer than the threshold, so outages do not go unnoticed until
+// dashboards go blank. It fires once per outage; a successful publish ends it.
+type failureAlert struct {
+	url       string
+	template  *template.Template
+	threshold time.Duration
+	client    *http.Client
+
+	mu       sync.Mutex
+	since    time.Time
+	failures int
+	fired    bool
+}
+
+// newFailureAlert returns an alert posting the payload built by tmpl to url.
+// Templates may use the json function to quote strings.
+func newFailureAlert(url, tmpl string, threshold time.Duration) (*failureAlert, error) {
+	t, err := template.New("alert").Funcs(template.FuncMap{"json": jsonString}).Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	return &failureAlert{
+		url:       url,
+		template:  t,
+		threshold: threshold,
+		client:    &http.Client{Timeout: alertTimeout},
+	}, nil
+}
+
+// record records the result of a publish and returns the payload posted to
+// the webhook, if the failures have just exceeded the threshold. The webhook
+// is called in the background. A nil failureAlert records nothing.
+func (a *failureAlert) record(err error, now time.Time) []byte {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err == nil {
+		a.since, a.failures, a.fired = time.Time{}, 0, false
+		return nil
+	}
+	if a.failures == 0 {
+		a.since = now
+	}
+	a.failures++
+	if a.fired || now.Sub(a.since) < a.threshold {
+		return nil
+	}
+	a.fired = true
+
+	var payload bytes.Buffer
+	data := alertData{Since: a.since, Duration: now.Sub(a.since), Failures: a.failures, Error: err.Error()}
+	if err := a.template.Execute(&payload, data); err != nil {
+		cassaLog.WithFields(log.Fields{
+			"err": err,
+		}).Error("alert template error")
+		return nil
+	}
+	go a.post(payload.Bytes())
+	return payload.Bytes()
+}
+
+// post sends the payload to the webhook.
+func (a *failureAlert) post(payload []byte) {
+	resp, err := a.client.Post(a.url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		cassaLog.WithFields(log.Fields{
+			"err": err,
+		}).Error("alert webhook error")
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		cassaLog.WithFields(log.Fields{
+			"status": resp.Status,
+		}).Error("alert webhook error")
+	}
+}
+
+// jsonString returns s as a quoted JSON string.
+func jsonString(s string) (string, error) {
+	b, err := json.Marshal(s)
+	return string(b), err
+}
@@ -0,0 +1,76 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFailureAlert(t *testing.T) {
+	Convey("Alert on sustained publish failures", t, func() {
+		posted := make(chan []byte, 1)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			posted <- body
+		}))
+		defer server.Close()
+
+		alert, err := newFailureAlert(server.URL, defaultAlertTemplate, time.Minute)
+		So(err, ShouldBeNil)
+		start := time.Now()
+		failure := errors.New(`no hosts "available"`)
+
+		Convey("So failures within the threshold should not alert", func() {
+			So(alert.record(failure, start), ShouldBeNil)
+			So(alert.record(failure, start.Add(30*time.Second)), ShouldBeNil)
+		})
+		Convey("So failures beyond the threshold should post the payload once", func() {
+			alert.record(failure, start)
+			payload := alert.record(failure, start.Add(2*time.Minute))
+			So(payload, ShouldNotBeNil)
+			msg := map[string]string{}
+			So(json.Unmarshal(<-posted, &msg), ShouldBeNil)
+			So(msg["text"], ShouldEqual, `snap Cassandra publisher failing for 2m0s (2 publishes): no hosts "available"`)
+			So(alert.record(failure, start.Add(3*time.Minute)), ShouldBeNil)
+		})
+		Convey("So a successful publish should end the outage", func() {
+			alert.record(failure, start)
+			alert.record(nil, start.Add(time.Minute))
+			So(alert.record(failure, start.Add(2*time.Minute)), ShouldBeNil)
+		})
+		Convey("So a nil alert should record nothing", func() {
+			var disabled *failureAlert
+			So(disabled.record(failure, start), ShouldBeNil)
+		})
+	})
+	Convey("Invalid alert templates should be refused", t, func() {
+		_, err := newFailureAlert("http://localhost", "{{.Error", time.Minute)
+		So(err, ShouldNotBeNil)
+	})
+}
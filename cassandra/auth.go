@@ -0,0 +1,70 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"fmt"
+
+	"github.com/gocql/gocql"
+)
+
+const (
+	dseAuthenticatorClass = "com.datastax.bdp.cassandra.auth.DseAuthenticator"
+	plainMechanism        = "PLAIN"
+	plainStartChallenge   = "PLAIN-START"
+)
+
+// dseProxyAuthenticator authenticates against DSE Unified Authentication with
+// the SASL PLAIN mechanism carrying an authorization id. The publisher logs in
+// with shared credentials and then acts as the authorized role.
+type dseProxyAuthenticator struct {
+	username        string
+	password        string
+	authorizationID string
+}
+
+// Challenge answers the DSE authenticator by selecting the PLAIN mechanism
+// and sends the credentials once the server starts the PLAIN exchange.
+func (a dseProxyAuthenticator) Challenge(req []byte) ([]byte, gocql.Authenticator, error) {
+	switch string(req) {
+	case dseAuthenticatorClass:
+		return []byte(plainMechanism), a, nil
+	case plainStartChallenge:
+		return a.credentials(), nil, nil
+	default:
+		return nil, nil, fmt.Errorf("unexpected authenticator %q, proxy authentication requires %s", req, dseAuthenticatorClass)
+	}
+}
+
+// Success is called when the authentication succeeds.
+func (a dseProxyAuthenticator) Success(data []byte) error {
+	return nil
+}
+
+// credentials returns the SASL PLAIN message: authzid, username and password separated by NUL.
+func (a dseProxyAuthenticator) credentials() []byte {
+	resp := make([]byte, 0, 2+len(a.authorizationID)+len(a.username)+len(a.password))
+	resp = append(resp, a.authorizationID...)
+	resp = append(resp, 0)
+	resp = append(resp, a.username...)
+	resp = append(resp, 0)
+	resp = append(resp, a.password...)
+	return resp
+}
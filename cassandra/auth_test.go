@@ -0,0 +1,56 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+
+	"github.com/gocql/gocql"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDseProxyAuthenticator(t *testing.T) {
+	Convey("Create DSE proxy authenticator", t, func() {
+		auth := dseProxyAuthenticator{username: "user", password: "pass", authorizationID: "service"}
+
+		Convey("So it should select the PLAIN mechanism for the DSE authenticator", func() {
+			resp, next, err := auth.Challenge([]byte(dseAuthenticatorClass))
+			So(err, ShouldBeNil)
+			So(string(resp), ShouldEqual, plainMechanism)
+			So(next, ShouldNotBeNil)
+
+			Convey("So it should send the authorization id with credentials on PLAIN-START", func() {
+				resp, next, err := next.Challenge([]byte(plainStartChallenge))
+				So(err, ShouldBeNil)
+				So(string(resp), ShouldEqual, "service\x00user\x00pass")
+				So(next, ShouldBeNil)
+			})
+		})
+		Convey("So it should reject other authenticators", func() {
+			_, _, err := auth.Challenge([]byte("org.apache.cassandra.auth.PasswordAuthenticator"))
+			So(err, ShouldNotBeNil)
+		})
+		Convey("So it should be used by a cluster when an authorization id is given", func() {
+			cluster := addSslOptions(gocql.NewCluster(serverAddress), &sslOptions{username: "user", password: "pass", authorizationID: "service"})
+			So(cluster.Authenticator, ShouldResemble, auth)
+		})
+	})
+}
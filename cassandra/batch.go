@@ -0,0 +1,265 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// writeBatch groups insert statements into unlogged batches of about size statements.
+// With a size of 1 every statement is executed on its own.
+type writeBatch struct {
+	session *gocql.Session
+	size    int
+	retry   retryPolicy
+	// inFlight bounds the statements and batches executing at once
+	inFlight inFlightLimit
+	// limit bounds the rows written per second
+	limit *rateLimit
+	// adaptive observes the latency of the writes, if the batch size is adaptive
+	adaptive *adaptiveBatching
+	batch    *gocql.Batch
+	// tags collects the tag rows instead of executing them, when set
+	tags *tagBatches
+	// cols is reused to build the columns of every insert, only the values
+	// copied out of it are handed to gocql
+	cols []column
+
+	// grouping collects the statements into group until flushGroup
+	grouping bool
+	group    []gocql.BatchEntry
+
+	// byPartition groups the statements into one batch per partition, in
+	// the order the partitions were first written to
+	byPartition bool
+	partitions  map[string][]gocql.BatchEntry
+	order       []string
+	count       int
+
+	// timings break down the latency of the publish, nil if not logged
+	timings *publishTimings
+
+	// query executes single statements instead of the session if set
+	query func(stmt string, values []interface{}) error
+}
+
+// newWriteBatch returns a writeBatch for the session, retrying failed executions with the policy.
+func newWriteBatch(session *gocql.Session, size int, retry retryPolicy) *writeBatch {
+	return &writeBatch{session: session, size: size, retry: retry}
+}
+
+// exec adds the statement to the batch, which is executed on flush.
+// Without batching the statement is executed immediately. Statements of an
+// unknown partition are executed on their own when grouped by partition.
+func (b *writeBatch) exec(stmt string, values ...interface{}) error {
+	return b.execIn("", stmt, values...)
+}
+
+// execIn adds the statement writing into the partition to the batch, the
+// partition identifies the table and the partition key of the row.
+func (b *writeBatch) execIn(partition, stmt string, values ...interface{}) error {
+	if b.grouping {
+		b.group = append(b.group, gocql.BatchEntry{Stmt: stmt, Args: values})
+		return nil
+	}
+	if b.size <= 1 {
+		return b.execQuery(stmt, values)
+	}
+	if b.byPartition {
+		if b.partitions == nil {
+			b.partitions = map[string][]gocql.BatchEntry{}
+		}
+		if _, ok := b.partitions[partition]; !ok {
+			b.order = append(b.order, partition)
+		}
+		b.partitions[partition] = append(b.partitions[partition], gocql.BatchEntry{Stmt: stmt, Args: values})
+		b.count++
+		return nil
+	}
+	if b.batch == nil {
+		b.batch = b.session.NewBatch(gocql.UnloggedBatch)
+	}
+	b.batch.Query(stmt, values...)
+	return nil
+}
+
+// startGroup groups the statements executed until flushGroup into one
+// unlogged batch, like the rows of a metric. Only statements which would be
+// executed on their own are grouped, batches already hold them together.
+func (b *writeBatch) startGroup() {
+	b.grouping = b.size <= 1
+}
+
+// flushGroup executes the statements grouped since startGroup.
+func (b *writeBatch) flushGroup() error {
+	entries := b.group
+	b.grouping, b.group = false, nil
+	switch len(entries) {
+	case 0:
+		return nil
+	case 1:
+		return b.execQuery(entries[0].Stmt, entries[0].Args)
+	}
+	return b.execBatch(entries)
+}
+
+// columns returns an empty slice to append the columns of an insert to.
+func (b *writeBatch) columns() []column {
+	return b.cols[:0]
+}
+
+// release keeps the columns of an insert, so the next insert reuses their memory.
+func (b *writeBatch) release(cols []column) {
+	b.cols = cols[:0]
+}
+
+// full returns true once the batch holds at least size statements.
+func (b *writeBatch) full() bool {
+	if b.byPartition {
+		return b.size > 1 && b.count >= b.size
+	}
+	return b.size > 1 && b.batch != nil && b.batch.Size() >= b.size
+}
+
+// flush executes the statements added since the last flush.
+func (b *writeBatch) flush() error {
+	if b.byPartition {
+		return b.flushPartitions()
+	}
+	if b.batch == nil || b.batch.Size() == 0 {
+		return nil
+	}
+	entries := b.batch.Entries
+	b.batch = nil
+	return b.execBatch(entries)
+}
+
+// flushPartitions executes an unlogged batch for every partition with
+// several statements and the other statements on their own, as batches
+// spanning partitions burden their coordinator. The errors are joined.
+func (b *writeBatch) flushPartitions() error {
+	partitions, order := b.partitions, b.order
+	b.partitions, b.order, b.count = nil, nil, 0
+
+	var errs []string
+	for _, partition := range order {
+		entries := partitions[partition]
+		if partition != "" && len(entries) > 1 {
+			if err := b.execBatch(entries); err != nil {
+				errs = append(errs, err.Error())
+			}
+			continue
+		}
+		for _, e := range entries {
+			if err := b.execQuery(e.Stmt, e.Args); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ";"))
+	}
+	return nil
+}
+
+// execQuery executes a single statement.
+func (b *writeBatch) execQuery(stmt string, values []interface{}) error {
+	return b.execute(1, func() error {
+		if b.query != nil {
+			return b.query(stmt, values)
+		}
+		return b.session.Query(stmt, values...).Exec()
+	})
+}
+
+// execWith executes a single statement at the consistency, bypassing the
+// batch as a batch is executed at one consistency.
+func (b *writeBatch) execWith(consistency gocql.Consistency, stmt string, values ...interface{}) error {
+	return b.execute(1, func() error {
+		return b.session.Query(stmt, values...).Consistency(consistency).Exec()
+	})
+}
+
+// execBatch executes the statements in an unlogged batch.
+func (b *writeBatch) execBatch(entries []gocql.BatchEntry) error {
+	return b.execute(len(entries), func() error {
+		// every execution gets a batch of its own, as gocql keeps the
+		// attempts of a batch in it
+		batch := b.session.NewBatch(gocql.UnloggedBatch)
+		batch.Entries = entries
+		return b.session.ExecuteBatch(batch)
+	})
+}
+
+// execute executes a write of rows with the retry policy, once the rate
+// limit and the in-flight limit let it pass. Its latency is reported to the
+// adaptive batching and the timings of the publish.
+func (b *writeBatch) execute(rows int, write func() error) error {
+	// speculative executions run attempts concurrently
+	var attempts int32
+	return b.retry.do(func() error {
+		attempt := atomic.AddInt32(&attempts, 1)
+		start := time.Now()
+		b.limit.wait(rows)
+		b.inFlight.acquire()
+		defer b.inFlight.release()
+		b.timings.addQueueWait(time.Since(start))
+
+		start = time.Now()
+		err := write()
+		latency := time.Since(start)
+		b.adaptive.observe(latency, err)
+		b.timings.addWrite(latency, attempt > 1)
+		return err
+	})
+}
+
+// inFlightLimit bounds the queries of a client executing at once, so bursts
+// of metrics neither overwhelm the coordinators nor exhaust the connections.
+// Retries wait for their delay without holding a slot. A nil limit does not
+// bound the queries.
+type inFlightLimit chan struct{}
+
+// newInFlightLimit returns a limit of max queries, nil if max is not positive.
+func newInFlightLimit(max int) inFlightLimit {
+	if max <= 0 {
+		return nil
+	}
+	return make(inFlightLimit, max)
+}
+
+// acquire blocks until a query may be executed.
+func (l inFlightLimit) acquire() {
+	if l != nil {
+		l <- struct{}{}
+	}
+}
+
+// release frees the slot of an executed query.
+func (l inFlightLimit) release() {
+	if l != nil {
+		<-l
+	}
+}
@@ -0,0 +1,125 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWriteBatch(t *testing.T) {
+	Convey("Create write batches", t, func() {
+		wb := newWriteBatch(nil, 50, retryPolicy{attempts: 3})
+		So(wb.size, ShouldEqual, 50)
+		So(wb.retry.attempts, ShouldEqual, 3)
+
+		Convey("So flushing an empty batch should do nothing", func() {
+			So(wb.flush(), ShouldBeNil)
+			So(newWriteBatch(nil, 1, retryPolicy{}).flush(), ShouldBeNil)
+		})
+
+		Convey("So inserts grouped by partition should be collected per partition", func() {
+			wb := newWriteBatch(nil, 3, retryPolicy{})
+			wb.byPartition = true
+			So(wb.execIn("a", "INSERT 1"), ShouldBeNil)
+			So(wb.execIn("b", "INSERT 2"), ShouldBeNil)
+			So(wb.full(), ShouldBeFalse)
+			So(wb.execIn("a", "INSERT 3", 1), ShouldBeNil)
+			So(wb.full(), ShouldBeTrue)
+			So(wb.order, ShouldResemble, []string{"a", "b"})
+			So(wb.partitions["a"], ShouldHaveLength, 2)
+			So(wb.partitions["a"][1].Args, ShouldResemble, []interface{}{1})
+			So(wb.batch, ShouldBeNil)
+		})
+
+		Convey("So the rows of a metric should be grouped without batching", func() {
+			wb := newWriteBatch(nil, 1, retryPolicy{})
+			wb.startGroup()
+			So(wb.execIn("metrics|a", "INSERT 1"), ShouldBeNil)
+			So(wb.exec("INSERT 2", "x"), ShouldBeNil)
+			So(wb.group, ShouldHaveLength, 2)
+			So(wb.group[1].Args, ShouldResemble, []interface{}{"x"})
+
+			wb.group = nil
+			So(wb.flushGroup(), ShouldBeNil)
+			So(wb.grouping, ShouldBeFalse)
+		})
+
+		Convey("So batches should not group the rows of a metric", func() {
+			wb.startGroup()
+			So(wb.grouping, ShouldBeFalse)
+			So(wb.flushGroup(), ShouldBeNil)
+		})
+	})
+}
+
+func TestMetricsPartition(t *testing.T) {
+	Convey("Given a point", t, func() {
+		m := *plugin.NewMetricType(core.NewNamespace("intel", "load"), time.Date(2020, 1, 1, 10, 30, 0, 0, time.UTC), map[string]string{core.STD_TAG_PLUGIN_RUNNING_ON: "h1"}, "", 1.0)
+		p, err := newPoint(m)
+		So(err, ShouldBeNil)
+		cc := &cassaClient{tableName: "metrics"}
+
+		Convey("Its partition is only computed for batches grouped by partition", func() {
+			So(cc.metricsPartition(&writeBatch{}, p), ShouldEqual, "")
+			So(cc.metricsPartition(&writeBatch{byPartition: true}, p), ShouldEqual, "metrics|/intel/load|0|h1")
+		})
+
+		Convey("Its bucket is part of its partition", func() {
+			cc.partitionBucket = time.Hour
+			So(cc.metricsPartition(&writeBatch{byPartition: true}, p), ShouldEqual, "metrics|/intel/load|0|h1|2020-01-01 10:00:00 +0000 UTC")
+		})
+	})
+}
+
+func TestInFlightLimit(t *testing.T) {
+	Convey("Given an in-flight limit of 2", t, func() {
+		l := newInFlightLimit(2)
+		l.acquire()
+		l.acquire()
+
+		Convey("A third query waits for a slot to be released", func() {
+			acquired := make(chan struct{})
+			go func() {
+				l.acquire()
+				close(acquired)
+			}()
+			select {
+			case <-acquired:
+				t.Error("acquired more slots than the limit")
+			case <-time.After(10 * time.Millisecond):
+			}
+			l.release()
+			<-acquired
+			So(len(l), ShouldEqual, 2)
+		})
+	})
+
+	Convey("Given no in-flight limit", t, func() {
+		l := newInFlightLimit(0)
+		So(l, ShouldBeNil)
+		So(func() { l.acquire(); l.release() }, ShouldNotPanic)
+	})
+}
@@ -0,0 +1,113 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+// LoadConfig reads a publisher config given as a JSON object, like the config
+// of the publisher in a task manifest.
+func LoadConfig(r io.Reader) (map[string]ctypes.ConfigValue, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var raw map[string]interface{}
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	config := map[string]ctypes.ConfigValue{}
+	for key, value := range raw {
+		switch v := value.(type) {
+		case string:
+			config[key] = ctypes.ConfigValueStr{Value: v}
+		case bool:
+			config[key] = ctypes.ConfigValueBool{Value: v}
+		case json.Number:
+			if i, err := v.Int64(); err == nil {
+				config[key] = ctypes.ConfigValueInt{Value: int(i)}
+			} else if f, err := v.Float64(); err == nil {
+				config[key] = ctypes.ConfigValueFloat{Value: f}
+			} else {
+				return nil, fmt.Errorf("invalid value of %s: %v", key, v)
+			}
+		default:
+			return nil, fmt.Errorf("invalid value of %s: %v", key, v)
+		}
+	}
+	return config, nil
+}
+
+// Bootstrap creates the keyspaces and tables of the publisher for the config
+// and returns, so the schema can be provisioned ahead of running tasks with
+// a user only allowed to write. The schema of every routed cluster and table
+// is created, with the settings of the table profiles.
+func Bootstrap(config map[string]ctypes.ConfigValue) error {
+	co, err := configClientOptions(config)
+	if err != nil {
+		return err
+	}
+	routes, err := parseConfigRoutes(config)
+	if err != nil {
+		return err
+	}
+	targets := routes.targets(co)
+	return runConcurrently(len(targets), co.schemaConcurrency, func(i int) error {
+		return bootstrapTarget(targets[i])
+	})
+}
+
+// bootstrapTarget creates the schema of a client of a config.
+func bootstrapTarget(t clientTarget) error {
+	co, err := newClientOptions(t.opts...)
+	if err != nil {
+		return err
+	}
+	session, err := getSession(co)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	return createSchema(session, co)
+}
+
+// configClientOptions validates the config against the config policy,
+// filling in the defaults, and returns the client options of the config.
+func configClientOptions(config map[string]ctypes.ConfigValue) (clientOptions, error) {
+	policy, err := NewCassandraPublisher().GetConfigPolicy()
+	if err != nil {
+		return clientOptions{}, err
+	}
+	processed, errs := policy.Get([]string{""}).Process(config)
+	if errs.HasErrors() {
+		msgs := []string{}
+		for _, e := range errs.Errors() {
+			msgs = append(msgs, e.Error())
+		}
+		return clientOptions{}, errors.New(strings.Join(msgs, ";"))
+	}
+	return prepareClientOptions(*processed), nil
+}
@@ -0,0 +1,51 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/intelsdi-x/snap/core/ctypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLoadConfig(t *testing.T) {
+	Convey("Load a publisher config from JSON", t, func() {
+		Convey("So values should get their config types", func() {
+			config, err := LoadConfig(strings.NewReader(`{"server": "127.0.0.1", "port": 9042, "ssl": false, "ratio": 0.5}`))
+			So(err, ShouldBeNil)
+			So(config[serverAddrRuleKey], ShouldResemble, ctypes.ConfigValueStr{Value: "127.0.0.1"})
+			So(config[portRuleKey], ShouldResemble, ctypes.ConfigValueInt{Value: 9042})
+			So(config[sslOptionsRuleKey], ShouldResemble, ctypes.ConfigValueBool{Value: false})
+			So(config["ratio"], ShouldResemble, ctypes.ConfigValueFloat{Value: 0.5})
+		})
+		Convey("So nested values should be refused", func() {
+			_, err := LoadConfig(strings.NewReader(`{"server": ["a", "b"]}`))
+			So(err, ShouldNotBeNil)
+		})
+		Convey("So a config missing the server should not be bootstrapped", func() {
+			config, err := LoadConfig(strings.NewReader(`{}`))
+			So(err, ShouldBeNil)
+			So(Bootstrap(config), ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,79 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// Partition buckets of the metrics table.
+const (
+	bucketHour = "hour"
+	bucketDay  = "day"
+	bucketWeek = "week"
+)
+
+// bucketSizes are the time spans of the partition buckets.
+var bucketSizes = map[string]time.Duration{
+	bucketHour: time.Hour,
+	bucketDay:  24 * time.Hour,
+	bucketWeek: 7 * 24 * time.Hour,
+}
+
+// bucketOf returns the start of the bucket of the given size holding t.
+// Days start at midnight UTC and weeks on Monday, as the zero time does.
+func bucketOf(t time.Time, size time.Duration) time.Time {
+	return t.UTC().Truncate(size)
+}
+
+// partitionKey returns the partition key columns of the metrics table.
+func partitionKey(bucket time.Duration) string {
+	if bucket > 0 {
+		return "ns, ver, host, bucket"
+	}
+	return "ns, ver, host"
+}
+
+// checkPartitionKey returns an error if the partition key of an existing
+// metrics table does not match the partition bucket setting, as the key of
+// a table cannot be altered.
+func checkPartitionKey(session *gocql.Session, co clientOptions) error {
+	km, err := session.KeyspaceMetadata(schemaIdentifier(co.keyspace, co.preserveCase))
+	if err != nil {
+		return err
+	}
+	tm, ok := km.Tables[schemaIdentifier(co.tableName, co.preserveCase)]
+	if !ok {
+		return fmt.Errorf("table %s.%s not found", co.keyspace, co.tableName)
+	}
+	names := make([]string, len(tm.PartitionKey))
+	for i, c := range tm.PartitionKey {
+		names[i] = c.Name
+	}
+	if key := partitionKey(co.partitionBucket); strings.Join(names, ", ") != key {
+		return fmt.Errorf("table %s.%s has the partition key (%s) instead of (%s), "+
+			"partitionBucket only applies to new tables, use another tableName", co.keyspace, co.tableName, strings.Join(names, ", "), key)
+	}
+	return nil
+}
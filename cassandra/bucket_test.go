@@ -0,0 +1,47 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPartitionBuckets(t *testing.T) {
+	Convey("Compute the partition bucket of metrics", t, func() {
+		// a Wednesday
+		ts := time.Date(2017, 3, 15, 13, 45, 30, 0, time.FixedZone("CET", 3600))
+		So(bucketOf(ts, bucketSizes[bucketHour]), ShouldResemble, time.Date(2017, 3, 15, 12, 0, 0, 0, time.UTC))
+		So(bucketOf(ts, bucketSizes[bucketDay]), ShouldResemble, time.Date(2017, 3, 15, 0, 0, 0, 0, time.UTC))
+		So(bucketOf(ts, bucketSizes[bucketWeek]), ShouldResemble, time.Date(2017, 3, 13, 0, 0, 0, 0, time.UTC))
+	})
+	Convey("Create bucketed metrics tables", t, func() {
+		co := clientOptions{keyspace: "snap", tableName: "metrics", timeColumn: "time", timeColumnType: "timestamp"}
+		So(metricsTableCQL(co), ShouldContainSubstring, "PRIMARY KEY ((ns, ver, host), time)")
+		So(metricsTableCQL(co), ShouldNotContainSubstring, "bucket")
+
+		co.partitionBucket = time.Hour
+		So(metricsTableCQL(co), ShouldContainSubstring, "bucket timestamp, PRIMARY KEY ((ns, ver, host, bucket), time)")
+		So(staticCQL(co), ShouldEqual, "INSERT INTO snap.metrics (ns, ver, host, bucket, unit, hostTags) VALUES (?, ?, ?, ?, ?, ?)")
+	})
+}
@@ -0,0 +1,67 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gocql/gocql"
+	log "github.com/sirupsen/logrus"
+)
+
+// build metadata, set at build time with
+// -ldflags "-X <package>.buildVersion=... -X <package>.gitCommit=..."
+var (
+	buildVersion = "dev"
+	gitCommit    = "unknown"
+)
+
+var (
+	createBuildTableCQL = `CREATE TABLE IF NOT EXISTS %s.builds (
+        host text,
+        started timestamp,
+        pluginVersion int,
+        buildVersion text,
+        gitCommit text,
+        PRIMARY KEY (host, started)
+    ) WITH CLUSTERING ORDER BY (started DESC);`
+	insertBuildCQL = `INSERT INTO %s.builds (host, started, pluginVersion, buildVersion, gitCommit) VALUES (?, ?, ?, ?, ?)`
+)
+
+// buildFields returns the build metadata as log fields.
+func buildFields() log.Fields {
+	return log.Fields{
+		"version":      version,
+		"buildVersion": buildVersion,
+		"gitCommit":    gitCommit,
+	}
+}
+
+// writeBuildInfo inserts a row with the build metadata of the running publisher into the builds table.
+func writeBuildInfo(session *gocql.Session, keyspace string, started time.Time) error {
+	host, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	return session.Query(fmt.Sprintf(insertBuildCQL, keyspace),
+		host, started, version, buildVersion, gitCommit).Exec()
+}
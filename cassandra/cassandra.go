@@ -20,13 +20,16 @@ limitations under the License.
 package cassandra
 
 import (
-	"bytes"
-	"encoding/gob"
+	"crypto/sha1"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/gocql/gocql"
 	"github.com/intelsdi-x/snap/control/plugin"
 	"github.com/intelsdi-x/snap/control/plugin/cpolicy"
 	"github.com/intelsdi-x/snap/core/ctypes"
@@ -38,40 +41,188 @@ const (
 	version    = 7
 	pluginType = plugin.PublisherPluginType
 
+	adaptiveBatchingRuleKey    = "adaptiveBatching"
+	alertTemplateRuleKey       = "alertTemplate"
+	alertThresholdRuleKey      = "alertThreshold"
+	alertWebhookRuleKey        = "alertWebhook"
+	authorizationIDRuleKey     = "authorizationId"
+	awsAccessKeyIDRuleKey      = "awsAccessKeyId"
+	awsRegionRuleKey           = "awsRegion"
+	awsSecretAccessKeyRuleKey  = "awsSecretAccessKey"
+	awsSessionTokenRuleKey     = "awsSessionToken"
+	batchByPartitionRuleKey    = "batchByPartition"
+	batchSizeRuleKey           = "batchSize"
+	boolTransitionsRuleKey     = "boolTransitions"
+	buildInfoRuleKey           = "buildInfo"
 	caPathRuleKey              = "caPath"
 	certPathRuleKey            = "certPath"
+	checksumRuleKey            = "checksum"
+	clusterRoutesRuleKey       = "clusterRoutes"
+	clusterSettingsRuleKey     = "clusterSettings"
+	coalesceDelayRuleKey       = "coalesceDelay"
+	coalesceMaxMetricsRuleKey  = "coalesceMaxMetrics"
+	connectBufferSizeRuleKey   = "connectBufferSize"
+	connectModeRuleKey         = "connectMode"
 	connectionTimeoutRuleKey   = "connectionTimeout"
+	compactionStrategyRuleKey  = "compactionStrategy"
+	compactionUnitRuleKey      = "compactionWindowUnit"
+	compactionSizeRuleKey      = "compactionWindowSize"
+	compressionRuleKey         = "compression"
+	consistencyRuleKey         = "consistency"
+	consistencyRoutesRuleKey   = "consistencyRoutes"
+	counterNamespacesRuleKey   = "counterNamespaces"
+	counterResetsRuleKey       = "counterResets"
+	createHostViewRuleKey      = "createHostView"
 	createKeyspaceRuleKey      = "createKeyspace"
+	createSchemaRuleKey        = "createSchema"
+	createTagMapIndexRuleKey   = "createTagMapIndex"
+	disableSkipMetadataRuleKey = "disableSkipMetadata"
+	disabledEventsRuleKey      = "disabledEvents"
+	doublePrecisionRuleKey     = "doublePrecision"
 	enableServerCertVerRuleKey = "serverCertVerification"
+	healthCheckIntervalRuleKey = "healthCheckInterval"
+	hostTagsRuleKey            = "hostTags"
+	identifierCaseRuleKey      = "identifierCase"
+	idleValidationRuleKey      = "idleValidation"
 	ignorePeerAddrRuleKey      = "ignorePeerAddr"
+	ingestTimeRuleKey          = "ingestTime"
 	initialHostLookupRuleKey   = "initialHostLookup"
+	insertTemplateRuleKey      = "insertTemplate"
+	int64ValRuleKey            = "int64Val"
 	keyPathRuleKey             = "keyPath"
 	keyspaceNameRuleKey        = "keyspaceName"
+	localDCRuleKey             = "localDC"
+	maxErrorLengthRuleKey      = "maxErrorLength"
+	maxInFlightRuleKey         = "maxInFlight"
+	maxMetricAgeRuleKey        = "maxMetricAge"
+	maxRoutingKeyInfoRuleKey   = "maxRoutingKeyInfo"
+	maxWritesPerSecondRuleKey  = "maxWritesPerSecond"
+	metadataOnlyRuleKey        = "metadataOnly"
+	nsColumnsRuleKey           = "nsColumns"
+	nsRewriteRuleKey           = "nsRewrite"
+	outOfOrderRuleKey          = "outOfOrder"
+	pageSizeRuleKey            = "pageSize"
+	partitionBucketRuleKey     = "partitionBucket"
 	passwordRuleKey            = "password"
+	poolStatsIntervalRuleKey   = "poolStatsInterval"
 	portRuleKey                = "port"
+	readOnlyRuleKey            = "readOnly"
+	replicationDCsRuleKey      = "replicationDataCenters"
+	replicationFactorRuleKey   = "replicationFactor"
+	replicationStrategyRuleKey = "replicationStrategy"
+	reconnectIntervalRuleKey   = "reconnectInterval"
+	retryAttemptsRuleKey       = "retryAttempts"
+	retryDelayRuleKey          = "retryDelay"
+	retryJitterRuleKey         = "retryJitter"
+	routeCacheSizeRuleKey      = "routeCacheSize"
+	schemaAgreementRuleKey     = "schemaAgreementTimeout"
+	schemaBufferSizeRuleKey    = "schemaBufferSize"
+	schemaConcurrencyRuleKey   = "schemaConcurrency"
+	selfStatsFileRuleKey       = "selfStatsFile"
+	selfStatsIntervalRuleKey   = "selfStatsInterval"
+	selfStatsTableRuleKey      = "selfStatsTable"
 	serverAddrRuleKey          = "server"
+	sharedTagSetsRuleKey       = "sharedTagSets"
+	speculativeAttemptsRuleKey = "speculativeAttempts"
+	speculativeDelayRuleKey    = "speculativeDelay"
+	spoolMaxSizeRuleKey        = "spoolMaxSize"
+	spoolPathRuleKey           = "spoolPath"
+	spoolSegmentSizeRuleKey    = "spoolSegmentSize"
 	sslOptionsRuleKey          = "ssl"
+	staticColumnsRuleKey       = "staticColumns"
+	staticTagsRuleKey          = "staticTags"
+	strValIndexRuleKey         = "strValIndex"
 	tableNameRuleKey           = "tableName"
+	tableProfilesRuleKey       = "tableProfiles"
+	tableRoutesRuleKey         = "tableRoutes"
+	tableTemplateRuleKey       = "tableTemplate"
+	tagBatchSizeRuleKey        = "tagBatchSize"
 	tagIndexRuleKey            = "tagIndex"
+	tagRowsWithMetricRuleKey   = "tagRowsWithMetric"
+	tagSetCacheSizeRuleKey     = "tagSetCacheSize"
+	tagSetIntervalRuleKey      = "tagSetInterval"
+	tagsExcludeRuleKey         = "tagsExclude"
+	tagsIncludeRuleKey         = "tagsInclude"
+	tagsKeyspaceRuleKey        = "tagsKeyspace"
+	tagsTableEnabledRuleKey    = "tagsTableEnabled"
+	tagsTTLRuleKey             = "tagsTtl"
+	timeColumnRuleKey          = "timeColumn"
+	timeColumnTypeRuleKey      = "timeColumnType"
 	timeoutRuleKey             = "timeout"
+	tokenAwareRuleKey          = "tokenAware"
+	ttlRuleKey                 = "ttl"
 	usernameRuleKey            = "username"
+	valTypeRuleKey             = "valType"
+	varintValRuleKey           = "varintVal"
+	versionTagRuleKey          = "versionTag"
+	writeConcurrencyRuleKey    = "writeConcurrency"
+	writeProbeRuleKey          = "writeProbe"
+	writeProfileRuleKey        = "writeProfile"
+	writeTimestampRuleKey      = "writeTimestamp"
 )
 
+// maxConcurrentPublishes is the number of publishes a publisher executes at
+// once, further ones wait for a slot. All state shared by the publishes,
+// like the clients, their caches and trackers and the self-metrics, is
+// locked, so snapteld may call Publish concurrently.
+const maxConcurrentPublishes = 4
+
 // Meta returns a plugin meta data
 func Meta() *plugin.PluginMeta {
-	return plugin.NewPluginMeta(name, version, pluginType, []string{plugin.SnapGOBContentType},
-		[]string{plugin.SnapGOBContentType}, plugin.RoutingStrategy(plugin.StickyRouting), plugin.ConcurrencyCount(1))
+	return plugin.NewPluginMeta(name, version, pluginType, []string{plugin.SnapGOBContentType, plugin.SnapJSONContentType},
+		[]string{plugin.SnapGOBContentType}, plugin.RoutingStrategy(plugin.StickyRouting), plugin.ConcurrencyCount(maxConcurrentPublishes))
 }
 
 // NewCassandraPublisher returns an instance of the Cassandra publisher
 // Client is not initiated until the first data publish happends.
 func NewCassandraPublisher() *CassandraPublisher {
-	return &CassandraPublisher{}
+	return &CassandraPublisher{configs: map[string]*configClients{}, publishes: newInFlightLimit(maxConcurrentPublishes)}
 }
 
 // CassandraPublisher defines Cassandra publisher
 type CassandraPublisher struct {
+	mu sync.Mutex
+	// clients of every publisher config seen, by config hash
+	configs map[string]*configClients
+	// publishes bounds the publishes executing at once
+	publishes inFlightLimit
+}
+
+// configClients are the clients publishing metrics for a publisher config.
+type configClients struct {
 	client *cassaClient
+
+	// alert is called when publishes fail continuously, nil if disabled
+	alert *failureAlert
+
+	// clients of the clusters metrics are routed to, by server
+	routes         []route
+	routeCache     *routeCache
+	clusterClients map[string]*cassaClient
+
+	// clients of the tables metrics are routed to, by cluster and table
+	tableRoutes  []route
+	tableCache   *routeCache
+	tableClients map[tableTarget]*cassaClient
+
+	// profiles override the storage settings of tables, by table name
+	profiles tableProfiles
+
+	// background connects the clients without blocking publishes, nil
+	// if they connect within the publish
+	background *backgroundConnect
+
+	// initMu keeps one initialization of the clients at a time
+	initMu sync.Mutex
+	// ready is set once all clients are initialized
+	ready bool
+}
+
+// tableTarget is a table metrics are routed to. An empty server is the
+// server of the config.
+type tableTarget struct {
+	server string
+	table  string
 }
 
 // GetConfigPolicy returns plugin mandatory fields as the config policy
@@ -79,41 +230,246 @@ func (cas *CassandraPublisher) GetConfigPolicy() (*cpolicy.ConfigPolicy, error)
 	cp := cpolicy.New()
 	config := cpolicy.NewPolicyNode()
 
+	adaptiveBatchingRule, err := cpolicy.NewIntegerRule(adaptiveBatchingRuleKey, false, 0)
+	handleErr(err)
+	adaptiveBatchingRule.Description = "Target write latency in milliseconds, if positive the batch size and the write concurrency start at 1 and adapt to the latency and the errors of the writes, up to batchSize and writeConcurrency, 0 keeps them fixed, default: 0"
+	config.Add(adaptiveBatchingRule)
+
+	alertTemplateRule, err := cpolicy.NewStringRule(alertTemplateRuleKey, false, defaultAlertTemplate)
+	handleErr(err)
+	alertTemplateRule.Description = "Go template of the payload posted to alertWebhook, with the fields Since, Duration, Failures and Error and the json function quoting strings, default: a Slack message"
+	config.Add(alertTemplateRule)
+
+	alertThresholdRule, err := cpolicy.NewIntegerRule(alertThresholdRuleKey, false, 300)
+	handleErr(err)
+	alertThresholdRule.Description = "Seconds publishes must fail continuously before alertWebhook is called, default: 300"
+	config.Add(alertThresholdRule)
+
+	alertWebhookRule, err := cpolicy.NewStringRule(alertWebhookRuleKey, false, "")
+	handleErr(err)
+	alertWebhookRule.Description = "URL posted to once when publishes have failed for longer than alertThreshold, empty disables alerts, default: empty"
+	config.Add(alertWebhookRule)
+
+	authorizationIDRule, err := cpolicy.NewStringRule(authorizationIDRuleKey, false, "")
+	handleErr(err)
+	authorizationIDRule.Description = "DSE role to act as after authenticating with username and password (proxy authentication)"
+	config.Add(authorizationIDRule)
+
+	awsAccessKeyIDRule, err := cpolicy.NewStringRule(awsAccessKeyIDRuleKey, false, "")
+	handleErr(err)
+	awsAccessKeyIDRule.Description = "AWS access key id of the SigV4 authentication, default: the AWS_ACCESS_KEY_ID environment variable"
+	config.Add(awsAccessKeyIDRule)
+
+	awsRegionRule, err := cpolicy.NewStringRule(awsRegionRuleKey, false, "")
+	handleErr(err)
+	awsRegionRule.Description = "AWS region of Amazon Keyspaces, enables SigV4 authentication over TLS, default: empty"
+	config.Add(awsRegionRule)
+
+	awsSecretAccessKeyRule, err := cpolicy.NewStringRule(awsSecretAccessKeyRuleKey, false, "")
+	handleErr(err)
+	awsSecretAccessKeyRule.Description = "AWS secret access key of the SigV4 authentication, default: the AWS_SECRET_ACCESS_KEY environment variable"
+	config.Add(awsSecretAccessKeyRule)
+
+	awsSessionTokenRule, err := cpolicy.NewStringRule(awsSessionTokenRuleKey, false, "")
+	handleErr(err)
+	awsSessionTokenRule.Description = "AWS session token of temporary credentials of the SigV4 authentication, default: the AWS_SESSION_TOKEN environment variable"
+	config.Add(awsSessionTokenRule)
+
+	batchByPartitionRule, err := cpolicy.NewBoolRule(batchByPartitionRuleKey, false, false)
+	handleErr(err)
+	batchByPartitionRule.Description = "If true, the inserts of a batch are grouped into one unlogged batch per partition, inserts into partitions of their own are executed alone, default: false"
+	config.Add(batchByPartitionRule)
+
+	batchSizeRule, err := cpolicy.NewIntegerRule(batchSizeRuleKey, false)
+	handleErr(err)
+	batchSizeRule.Description = "Maximum number of inserts sent in one unlogged batch, 1 sends every insert on its own, default: 1"
+	config.Add(batchSizeRule)
+
+	boolTransitionsRule, err := cpolicy.NewBoolRule(boolTransitionsRuleKey, false, false)
+	handleErr(err)
+	boolTransitionsRule.Description = "If true, record state changes of boolean metrics in the transitions table, default: false"
+	config.Add(boolTransitionsRule)
+
+	buildInfoRule, err := cpolicy.NewBoolRule(buildInfoRuleKey, false, false)
+	handleErr(err)
+	buildInfoRule.Description = "If true, record the build version and git commit of the publisher in the builds table, default: false"
+	config.Add(buildInfoRule)
+
 	caPathRule, err := cpolicy.NewStringRule(caPathRuleKey, false, "")
 	handleErr(err)
 	caPathRule.Description = "Path to the CA certificate for the Cassandra server"
 	config.Add(caPathRule)
 
+	checksumRule, err := cpolicy.NewBoolRule(checksumRuleKey, false, false)
+	handleErr(err)
+	checksumRule.Description = "If true, store a checksum of namespace, time, value and written tags in the checksum column of the metrics table, default: false"
+	config.Add(checksumRule)
+
 	certPathRule, err := cpolicy.NewStringRule(certPathRuleKey, false, "")
 	handleErr(err)
 	certPathRule.Description = "Path to the self signed certificate for the Cassandra client"
 	config.Add(certPathRule)
 
+	clusterRoutesRule, err := cpolicy.NewStringRule(clusterRoutesRuleKey, false, "")
+	handleErr(err)
+	clusterRoutesRule.Description = "Comma separated prefix=server rules publishing namespaces with a prefix to another Cassandra cluster"
+	config.Add(clusterRoutesRule)
+
+	clusterSettingsRule, err := cpolicy.NewStringRule(clusterSettingsRuleKey, false, "")
+	handleErr(err)
+	clusterSettingsRule.Description = "Semicolon separated server: key=value ... entries overriding settings of the config for a cluster of clusterRoutes, default: empty"
+	config.Add(clusterSettingsRule)
+
+	coalesceDelayRule, err := cpolicy.NewIntegerRule(coalesceDelayRuleKey, false, 0)
+	handleErr(err)
+	coalesceDelayRule.Description = "Milliseconds metrics of a publish wait to be written together with the ones of concurrent publishes of tasks with the same config, 0 disables it, default: 0"
+	config.Add(coalesceDelayRule)
+
+	coalesceMaxMetricsRule, err := cpolicy.NewIntegerRule(coalesceMaxMetricsRuleKey, false, 1000)
+	handleErr(err)
+	coalesceMaxMetricsRule.Description = "Number of pending coalesced metrics written without waiting for the coalesceDelay to end, default: 1000"
+	config.Add(coalesceMaxMetricsRule)
+
+	compactionStrategyRule, err := cpolicy.NewStringRule(compactionStrategyRuleKey, false, "")
+	handleErr(err)
+	compactionStrategyRule.Description = "Compaction strategy of the created time series tables: SizeTieredCompactionStrategy, LeveledCompactionStrategy or TimeWindowCompactionStrategy, default: the Cassandra default"
+	config.Add(compactionStrategyRule)
+
+	compactionUnitRule, err := cpolicy.NewStringRule(compactionUnitRuleKey, false, "DAYS")
+	handleErr(err)
+	compactionUnitRule.Description = "Unit of the TimeWindowCompactionStrategy window: MINUTES, HOURS or DAYS, default: DAYS"
+	config.Add(compactionUnitRule)
+
+	compactionSizeRule, err := cpolicy.NewIntegerRule(compactionSizeRuleKey, false, 1)
+	handleErr(err)
+	compactionSizeRule.Description = "Number of units of the TimeWindowCompactionStrategy window, default: 1"
+	config.Add(compactionSizeRule)
+
+	compressionRule, err := cpolicy.NewStringRule(compressionRuleKey, false, compressionNone)
+	handleErr(err)
+	compressionRule.Description = "Compression of the traffic to the cluster: none or snappy, default: none"
+	config.Add(compressionRule)
+
+	connectBufferSizeRule, err := cpolicy.NewIntegerRule(connectBufferSizeRuleKey, false, 10000)
+	handleErr(err)
+	connectBufferSizeRule.Description = "Maximum number of metrics buffered while connecting in the background, default: 10000"
+	config.Add(connectBufferSizeRule)
+
+	connectModeRule, err := cpolicy.NewStringRule(connectModeRuleKey, false, connectBlocking)
+	handleErr(err)
+	connectModeRule.Description = "Connect to the cluster within the first publish (blocking) or in the background, buffering the metrics published meanwhile (background), default: blocking"
+	config.Add(connectModeRule)
+
 	connectionTimeoutRule, err := cpolicy.NewIntegerRule(connectionTimeoutRuleKey, false, 2)
 	handleErr(err)
 	connectionTimeoutRule.Description = "Initial connection timeout in seconds, default: 2"
 	config.Add(connectionTimeoutRule)
 
+	consistencyRule, err := cpolicy.NewStringRule(consistencyRuleKey, false)
+	handleErr(err)
+	consistencyRule.Description = "Consistency level of writes, e.g. ONE, QUORUM or LOCAL_QUORUM, default: ONE"
+	config.Add(consistencyRule)
+
+	consistencyRoutesRule, err := cpolicy.NewStringRule(consistencyRoutesRuleKey, false, "")
+	handleErr(err)
+	consistencyRoutesRule.Description = "Comma separated namespace prefix to consistency rules overriding the consistency of the metrics rows, e.g. /sla/* -> QUORUM, default: empty"
+	config.Add(consistencyRoutesRule)
+
+	counterNamespacesRule, err := cpolicy.NewStringRule(counterNamespacesRuleKey, false, "")
+	handleErr(err)
+	counterNamespacesRule.Description = "Comma separated namespace prefixes of the counters counterResets detects resets of, empty selects all metrics, default: empty"
+	config.Add(counterNamespacesRule)
+
+	counterResetsRule, err := cpolicy.NewStringRule(counterResetsRuleKey, false, "")
+	handleErr(err)
+	counterResetsRule.Description = "Detection of counter resets: flag marks the rows after a reset in the counterReset column, marker writes a row of negative infinity a millisecond before them, empty disables it, default: empty"
+	config.Add(counterResetsRule)
+
+	createHostViewRule, err := cpolicy.NewBoolRule(createHostViewRuleKey, false, false)
+	handleErr(err)
+	createHostViewRule.Description = "If true, a materialized view of the metrics table keyed by host and time is created with the schema, so all metrics of a host in a time range can be queried at once, default: false"
+	config.Add(createHostViewRule)
+
 	createKeyspaceRule, err := cpolicy.NewBoolRule(createKeyspaceRuleKey, false, true)
 	handleErr(err)
 	createKeyspaceRule.Description = "Create keyspace if it's not exist, default: true"
 	config.Add(createKeyspaceRule)
 
+	createSchemaRule, err := cpolicy.NewBoolRule(createSchemaRuleKey, false, true)
+	handleErr(err)
+	createSchemaRule.Description = "If false, clients neither create nor alter keyspaces, tables and indexes, so they run with a user only allowed to write into a schema created by the bootstrap subcommand, default: true"
+	config.Add(createSchemaRule)
+
+	createTagMapIndexRule, err := cpolicy.NewBoolRule(createTagMapIndexRuleKey, false, false)
+	handleErr(err)
+	createTagMapIndexRule.Description = "If true, a secondary index on the entries of the tags map of the metrics table is created with the schema, so metrics can be queried by tag directly, default: false"
+	config.Add(createTagMapIndexRule)
+
+	disableSkipMetadataRule, err := cpolicy.NewBoolRule(disableSkipMetadataRuleKey, false, false)
+	handleErr(err)
+	disableSkipMetadataRule.Description = "Advanced: if true, result metadata is sent with every result instead of being cached, default: false"
+	config.Add(disableSkipMetadataRule)
+
+	disabledEventsRule, err := cpolicy.NewStringRule(disabledEventsRuleKey, false, "")
+	handleErr(err)
+	disabledEventsRule.Description = "Comma separated cluster events not to register for: status, topology, schema"
+	config.Add(disabledEventsRule)
+
+	doublePrecisionRule, err := cpolicy.NewIntegerRule(doublePrecisionRuleKey, false, -1)
+	handleErr(err)
+	doublePrecisionRule.Description = "Number of decimal places doubles are rounded to before they are written, -1 keeps their full precision, default: -1"
+	config.Add(doublePrecisionRule)
+
 	enableServerCertVerRule, err := cpolicy.NewBoolRule(enableServerCertVerRuleKey, false, true)
 	handleErr(err)
 	enableServerCertVerRule.Description = "If true, verify a hostname and a server key, default: true"
 	config.Add(enableServerCertVerRule)
 
+	healthCheckIntervalRule, err := cpolicy.NewIntegerRule(healthCheckIntervalRuleKey, false, 30)
+	handleErr(err)
+	healthCheckIntervalRule.Description = "Interval in seconds of probing the session before a publish and rebuilding it if it was closed or none of its hosts is available, 0 disables it, default: 30"
+	config.Add(healthCheckIntervalRule)
+
+	hostTagsRule, err := cpolicy.NewStringRule(hostTagsRuleKey, false, "")
+	handleErr(err)
+	hostTagsRule.Description = "Names of tags separated by a comma stored once per partition in the hostTags static column with staticColumns, default: empty"
+	config.Add(hostTagsRule)
+
+	identifierCaseRule, err := cpolicy.NewStringRule(identifierCaseRuleKey, false, identifierLower)
+	handleErr(err)
+	identifierCaseRule.Description = "Case of keyspace and table names: lower leaves them unquoted, so Cassandra folds them to lowercase, preserve quotes them to write into schemas created with mixed-case names, default: lower"
+	config.Add(identifierCaseRule)
+
+	idleValidationRule, err := cpolicy.NewIntegerRule(idleValidationRuleKey, false, 0)
+	handleErr(err)
+	idleValidationRule.Description = "Idle period in seconds after which one pooled connection is validated with a query before the next publish and all connections get TCP keepalives, 0 disables it, default: 0"
+	config.Add(idleValidationRule)
+
 	ignorePeerAddrRule, err := cpolicy.NewBoolRule(ignorePeerAddrRuleKey, false, false)
 	handleErr(err)
 	ignorePeerAddrRule.Description = "Turn off cluster hosts tracking, default: false"
 	config.Add(ignorePeerAddrRule)
 
+	ingestTimeRule, err := cpolicy.NewBoolRule(ingestTimeRuleKey, false, false)
+	handleErr(err)
+	ingestTimeRule.Description = "If true, store the time Cassandra received a metric in the ingestTime column of the metrics table, to analyse the collection to storage lag, default: false"
+	config.Add(ingestTimeRule)
+
 	initialHostLookupRule, err := cpolicy.NewBoolRule(initialHostLookupRuleKey, false, true)
 	handleErr(err)
 	initialHostLookupRule.Description = "Lookup for cluster hosts information, default: true"
 	config.Add(initialHostLookupRule)
 
+	insertTemplateRule, err := cpolicy.NewStringRule(insertTemplateRuleKey, false, "")
+	handleErr(err)
+	insertTemplateRule.Description = "INSERT statement writing metrics into a table not matching the built-in layout, with named placeholders like {ns}, {time} and {value} for the standard columns, default: empty"
+	config.Add(insertTemplateRule)
+
+	int64ValRule, err := cpolicy.NewBoolRule(int64ValRuleKey, false, false)
+	handleErr(err)
+	int64ValRule.Description = "If true, store integers in the int64Val column, keeping their precision, instead of as doubles, default: false"
+	config.Add(int64ValRule)
+
 	keyPathRule, err := cpolicy.NewStringRule(keyPathRuleKey, false, "")
 	handleErr(err)
 	keyPathRule.Description = "Path to the private key for the Cassandra client"
@@ -124,89 +480,582 @@ func (cas *CassandraPublisher) GetConfigPolicy() (*cpolicy.ConfigPolicy, error)
 	keyspaceNameRule.Description = "Keyspace name, default: snap"
 	config.Add(keyspaceNameRule)
 
+	localDCRule, err := cpolicy.NewStringRule(localDCRuleKey, false, "")
+	handleErr(err)
+	localDCRule.Description = "Data center whose hosts are preferred for writes, empty selects hosts of all data centers round-robin, default: empty"
+	config.Add(localDCRule)
+
+	maxErrorLengthRule, err := cpolicy.NewIntegerRule(maxErrorLengthRuleKey, false, 1024)
+	handleErr(err)
+	maxErrorLengthRule.Description = "Maximum length in bytes of the error returned to snap by a publish, longer errors are shortened and logged in full, 0 disables it, default: 1024"
+	config.Add(maxErrorLengthRule)
+
+	maxInFlightRule, err := cpolicy.NewIntegerRule(maxInFlightRuleKey, false, 0)
+	handleErr(err)
+	maxInFlightRule.Description = "Maximum number of insert statements and batches of a client executing at once, 0 does not bound them, default: 0"
+	config.Add(maxInFlightRule)
+
+	maxMetricAgeRule, err := cpolicy.NewIntegerRule(maxMetricAgeRuleKey, false, 0)
+	handleErr(err)
+	maxMetricAgeRule.Description = "Age in seconds beyond which metrics are dropped and counted instead of written, 0 writes all metrics, default: 0"
+	config.Add(maxMetricAgeRule)
+
+	maxRoutingKeyInfoRule, err := cpolicy.NewIntegerRule(maxRoutingKeyInfoRuleKey, false, 1000)
+	handleErr(err)
+	maxRoutingKeyInfoRule.Description = "Advanced: maximum number of cached routing key infos of prepared statements, default: 1000"
+	config.Add(maxRoutingKeyInfoRule)
+
+	maxWritesPerSecondRule, err := cpolicy.NewIntegerRule(maxWritesPerSecondRuleKey, false, 0)
+	handleErr(err)
+	maxWritesPerSecondRule.Description = "Maximum number of rows a client writes per second, 0 does not bound them, default: 0"
+	config.Add(maxWritesPerSecondRule)
+
+	metadataOnlyRule, err := cpolicy.NewBoolRule(metadataOnlyRuleKey, false, false)
+	handleErr(err)
+	metadataOnlyRule.Description = "If true, only maintain the tags table of tagIndex and write no samples into the metrics, tagsets and transitions tables, default: false"
+	config.Add(metadataOnlyRule)
+
+	nsColumnsRule, err := cpolicy.NewIntegerRule(nsColumnsRuleKey, false, 0)
+	handleErr(err)
+	nsColumnsRule.Description = "Number of leading namespace elements written into the ns0, ns1, ... columns of the metrics table, with the remaining ones in the nsRest column, 0 disables them, default: 0"
+	config.Add(nsColumnsRule)
+
+	nsRewriteRule, err := cpolicy.NewStringRule(nsRewriteRuleKey, false, "")
+	handleErr(err)
+	nsRewriteRule.Description = "Semicolon separated rewrites of the namespaces written into the ns column, e.g. strip:/intel; segment:psutil->ps; regex:^/(\\w+)/(.*)$->/$2/$1, default: empty"
+	config.Add(nsRewriteRule)
+
+	outOfOrderRule, err := cpolicy.NewStringRule(outOfOrderRuleKey, false, "")
+	handleErr(err)
+	outOfOrderRule.Description = "Detection of samples older than the latest one of their series: report logs them, flag also marks their rows in the outOfOrder column, empty disables it, default: empty"
+	config.Add(outOfOrderRule)
+
+	pageSizeRule, err := cpolicy.NewIntegerRule(pageSizeRuleKey, false, 5000)
+	handleErr(err)
+	pageSizeRule.Description = "Advanced: default page size of queries, default: 5000"
+	config.Add(pageSizeRule)
+
+	partitionBucketRule, err := cpolicy.NewStringRule(partitionBucketRuleKey, false, "")
+	handleErr(err)
+	partitionBucketRule.Description = "Time span of the partitions of a series in a newly created metrics table: hour, day or week, empty keeps one partition per series, default: empty"
+	config.Add(partitionBucketRule)
+
 	passwordRule, err := cpolicy.NewStringRule(passwordRuleKey, false, "")
 	handleErr(err)
 	passwordRule.Description = "Password used to authenticate to the Cassandra"
 	config.Add(passwordRule)
 
+	poolStatsIntervalRule, err := cpolicy.NewIntegerRule(poolStatsIntervalRuleKey, false, 0)
+	handleErr(err)
+	poolStatsIntervalRule.Description = "Interval in seconds of logging the state of the hosts and the requests in flight to them at debug level, 0 disables it, default: 0"
+	config.Add(poolStatsIntervalRule)
+
 	portRule, err := cpolicy.NewIntegerRule(portRuleKey, false, 9042)
 	handleErr(err)
 	portRule.Description = "Cassandra server port, default: 9042"
 	config.Add(portRule)
 
+	readOnlyRule, err := cpolicy.NewBoolRule(readOnlyRuleKey, false, false)
+	handleErr(err)
+	readOnlyRule.Description = "If true, never create the schema and refuse all writes, default: false"
+	config.Add(readOnlyRule)
+
+	replicationDCsRule, err := cpolicy.NewStringRule(replicationDCsRuleKey, false, "")
+	handleErr(err)
+	replicationDCsRule.Description = "Comma separated dc:factor replication factors of created keyspaces with NetworkTopologyStrategy"
+	config.Add(replicationDCsRule)
+
+	replicationFactorRule, err := cpolicy.NewIntegerRule(replicationFactorRuleKey, false, 1)
+	handleErr(err)
+	replicationFactorRule.Description = "Replication factor of created keyspaces with SimpleStrategy, default: 1"
+	config.Add(replicationFactorRule)
+
+	replicationStrategyRule, err := cpolicy.NewStringRule(replicationStrategyRuleKey, false, "SimpleStrategy")
+	handleErr(err)
+	replicationStrategyRule.Description = "Replication strategy of created keyspaces: SimpleStrategy or NetworkTopologyStrategy, default: SimpleStrategy"
+	config.Add(replicationStrategyRule)
+
+	reconnectIntervalRule, err := cpolicy.NewIntegerRule(reconnectIntervalRuleKey, false, 60)
+	handleErr(err)
+	reconnectIntervalRule.Description = "Interval in seconds of polling down hosts to reconnect to them, default: 60"
+	config.Add(reconnectIntervalRule)
+
+	retryAttemptsRule, err := cpolicy.NewIntegerRule(retryAttemptsRuleKey, false)
+	handleErr(err)
+	retryAttemptsRule.Description = "Maximum number of attempts of an insert failing with a timeout or unavailable replicas, 1 disables retries, default: 1"
+	config.Add(retryAttemptsRule)
+
+	retryDelayRule, err := cpolicy.NewIntegerRule(retryDelayRuleKey, false, 100)
+	handleErr(err)
+	retryDelayRule.Description = "Delay in milliseconds before the first retry of an insert, doubled for every further retry, default: 100"
+	config.Add(retryDelayRule)
+
+	retryJitterRule, err := cpolicy.NewIntegerRule(retryJitterRuleKey, false, 50)
+	handleErr(err)
+	retryJitterRule.Description = "Maximum random delay in milliseconds added to every retry of an insert, default: 50"
+	config.Add(retryJitterRule)
+
+	routeCacheSizeRule, err := cpolicy.NewIntegerRule(routeCacheSizeRuleKey, false, 10000)
+	handleErr(err)
+	routeCacheSizeRule.Description = "Maximum number of namespaces whose routing decision is cached, 0 disables the cache, default: 10000"
+	config.Add(routeCacheSizeRule)
+
+	schemaAgreementRule, err := cpolicy.NewIntegerRule(schemaAgreementRuleKey, false, 60)
+	handleErr(err)
+	schemaAgreementRule.Description = "Maximum time in seconds to wait for schema agreement after creating a table, default: 60"
+	config.Add(schemaAgreementRule)
+
+	schemaBufferSizeRule, err := cpolicy.NewIntegerRule(schemaBufferSizeRuleKey, false, 10000)
+	handleErr(err)
+	schemaBufferSizeRule.Description = "Maximum number of metrics buffered while the schema creation is retried, default: 10000"
+	config.Add(schemaBufferSizeRule)
+
+	schemaConcurrencyRule, err := cpolicy.NewIntegerRule(schemaConcurrencyRuleKey, false, 4)
+	handleErr(err)
+	schemaConcurrencyRule.Description = "Maximum number of routed clients set up concurrently, and of tables every client creates concurrently during schema setup, default: 4"
+	config.Add(schemaConcurrencyRule)
+
+	selfStatsFileRule, err := cpolicy.NewStringRule(selfStatsFileRuleKey, false, "")
+	handleErr(err)
+	selfStatsFileRule.Description = "Path of a file the stats of selfStatsInterval are also written into in the OpenMetrics text format, e.g. for the textfile collector of the node_exporter, default: empty"
+	config.Add(selfStatsFileRule)
+
+	selfStatsIntervalRule, err := cpolicy.NewIntegerRule(selfStatsIntervalRuleKey, false, 0)
+	handleErr(err)
+	selfStatsIntervalRule.Description = "Interval in seconds of logging the metrics written, the insert errors, the retries and the publish latency percentiles of the publisher, 0 disables it, default: 0"
+	config.Add(selfStatsIntervalRule)
+
+	selfStatsTableRule, err := cpolicy.NewBoolRule(selfStatsTableRuleKey, false, false)
+	handleErr(err)
+	selfStatsTableRule.Description = "If true, also write the stats of selfStatsInterval into the snap_publisher_stats table, default: false"
+	config.Add(selfStatsTableRule)
+
 	serverAddrRule, err := cpolicy.NewStringRule(serverAddrRuleKey, true)
 	handleErr(err)
 	serverAddrRule.Description = "Cassandra server"
 	config.Add(serverAddrRule)
 
+	sharedTagSetsRule, err := cpolicy.NewBoolRule(sharedTagSetsRuleKey, false, false)
+	handleErr(err)
+	sharedTagSetsRule.Description = "If true, store the tags common to all metrics of a publish once in the tagsets table, default: false"
+	config.Add(sharedTagSetsRule)
+
 	useSslOptionsRule, err := cpolicy.NewBoolRule(sslOptionsRuleKey, false, false)
 	handleErr(err)
 	useSslOptionsRule.Description = "Not required, if true, use ssl options to connect to the Cassandra, default: false"
 	config.Add(useSslOptionsRule)
 
+	speculativeAttemptsRule, err := cpolicy.NewIntegerRule(speculativeAttemptsRuleKey, false, 0)
+	handleErr(err)
+	speculativeAttemptsRule.Description = "Maximum number of speculative executions of an insert or batch not completed within speculativeDelay, 0 disables them, default: 0"
+	config.Add(speculativeAttemptsRule)
+
+	speculativeDelayRule, err := cpolicy.NewIntegerRule(speculativeDelayRuleKey, false, 100)
+	handleErr(err)
+	speculativeDelayRule.Description = "Milliseconds waited for an execution before starting a speculative one, default: 100"
+	config.Add(speculativeDelayRule)
+
+	spoolMaxSizeRule, err := cpolicy.NewIntegerRule(spoolMaxSizeRuleKey, false, 100)
+	handleErr(err)
+	spoolMaxSizeRule.Description = "Maximum size in megabytes of the spool of metrics which could not be written, the oldest segments are evicted beyond it, default: 100"
+	config.Add(spoolMaxSizeRule)
+
+	spoolPathRule, err := cpolicy.NewStringRule(spoolPathRuleKey, false, "")
+	handleErr(err)
+	spoolPathRule.Description = "Directory metrics which could not be written are spooled to and replayed from, empty disables the spool"
+	config.Add(spoolPathRule)
+
+	spoolSegmentSizeRule, err := cpolicy.NewIntegerRule(spoolSegmentSizeRuleKey, false, 1)
+	handleErr(err)
+	spoolSegmentSizeRule.Description = "Size in megabytes from which the segment file metrics are spooled to is rotated, the unit of eviction of the spool, default: 1"
+	config.Add(spoolSegmentSizeRule)
+
+	staticColumnsRule, err := cpolicy.NewBoolRule(staticColumnsRuleKey, false, false)
+	handleErr(err)
+	staticColumnsRule.Description = "If true, store the unit and the hostTags of a series once per partition in static columns of the metrics table, default: false"
+	config.Add(staticColumnsRule)
+
+	staticTagsRule, err := cpolicy.NewStringRule(staticTagsRuleKey, false, "")
+	handleErr(err)
+	staticTagsRule.Description = "Comma separated key:value tags added to every metric not carrying them, e.g. env:prod,region:us-east, default: empty"
+	config.Add(staticTagsRule)
+
+	strValIndexRule, err := cpolicy.NewStringRule(strValIndexRuleKey, false, "")
+	handleErr(err)
+	strValIndexRule.Description = "Mode of a SASI index on the strVal column of the metrics table created with the schema, for LIKE searches of string values: PREFIX or CONTAINS, empty creates none, default: empty"
+	config.Add(strValIndexRule)
+
 	tableNameRule, err := cpolicy.NewStringRule(tableNameRuleKey, false, "metrics")
 	handleErr(err)
 	tableNameRule.Description = "Table name, default: metrics"
 	config.Add(tableNameRule)
 
+	tableProfilesRule, err := cpolicy.NewStringRule(tableProfilesRuleKey, false, "")
+	handleErr(err)
+	tableProfilesRule.Description = "Semicolon separated profiles overriding ttl, consistency, compactionWindowUnit and compactionWindowSize per metrics table, e.g. metrics_1h: ttl=94608000 compactionWindowSize=30, default: empty"
+	config.Add(tableProfilesRule)
+
+	tableRoutesRule, err := cpolicy.NewStringRule(tableRoutesRuleKey, false, "")
+	handleErr(err)
+	tableRoutesRule.Description = "Comma separated prefix=table rules writing namespaces with a prefix into their own metrics table, e.g. /intel/psutil/* -> psutil_metrics"
+	config.Add(tableRoutesRule)
+
+	tableTemplateRule, err := cpolicy.NewStringRule(tableTemplateRuleKey, false, "")
+	handleErr(err)
+	tableTemplateRule.Description = "CREATE TABLE statement of the metrics table replacing the built-in layout, with the placeholders {keyspace}, {table}, {timeColumn} and {timeColumnType}, default: empty"
+	config.Add(tableTemplateRule)
+
+	tagBatchSizeRule, err := cpolicy.NewIntegerRule(tagBatchSizeRuleKey, false, 0)
+	handleErr(err)
+	tagBatchSizeRule.Description = "Maximum number of tag rows of a partition written in one unlogged batch after the metrics of a publish, 0 writes them with every metric, default: 0"
+	config.Add(tagBatchSizeRule)
+
 	tagIndexRule, err := cpolicy.NewStringRule(tagIndexRuleKey, false, "")
 	handleErr(err)
 	tagIndexRule.Description = "Name of tags to be indexed separated by a comma"
 	config.Add(tagIndexRule)
 
-	timeoutRule, err := cpolicy.NewIntegerRule(timeoutRuleKey, false, 2)
+	tagRowsWithMetricRule, err := cpolicy.NewBoolRule(tagRowsWithMetricRuleKey, false, false)
+	handleErr(err)
+	tagRowsWithMetricRule.Description = "If true, the tag rows of a metric are sent in one unlogged batch with its metrics row instead of a query each, when batchSize is 1, default: false"
+	config.Add(tagRowsWithMetricRule)
+
+	tagSetCacheSizeRule, err := cpolicy.NewIntegerRule(tagSetCacheSizeRuleKey, false, 10000)
+	handleErr(err)
+	tagSetCacheSizeRule.Description = "Maximum number of shared tag sets remembered as written, 0 writes the tag set of every publish, default: 10000"
+	config.Add(tagSetCacheSizeRule)
+
+	tagSetIntervalRule, err := cpolicy.NewIntegerRule(tagSetIntervalRuleKey, false, 3600)
+	handleErr(err)
+	tagSetIntervalRule.Description = "Seconds after which a shared tag set is written again, 0 writes every tag set once, default: 3600"
+	config.Add(tagSetIntervalRule)
+
+	tagsExcludeRule, err := cpolicy.NewStringRule(tagsExcludeRuleKey, false, "")
+	handleErr(err)
+	tagsExcludeRule.Description = "Comma separated tags not written into the tags columns, a trailing * matches tags by prefix, default: empty"
+	config.Add(tagsExcludeRule)
+
+	tagsIncludeRule, err := cpolicy.NewStringRule(tagsIncludeRuleKey, false, "")
+	handleErr(err)
+	tagsIncludeRule.Description = "Comma separated tags written into the tags columns, empty writes all tags, a trailing * matches tags by prefix, default: empty"
+	config.Add(tagsIncludeRule)
+
+	tagsKeyspaceRule, err := cpolicy.NewStringRule(tagsKeyspaceRuleKey, false, "")
+	handleErr(err)
+	tagsKeyspaceRule.Description = "Keyspace of the tags table, default: the keyspace of the metrics table"
+	config.Add(tagsKeyspaceRule)
+
+	tagsTableEnabledRule, err := cpolicy.NewBoolRule(tagsTableEnabledRuleKey, false, true)
+	handleErr(err)
+	tagsTableEnabledRule.Description = "If false, the tags table is neither created nor written, whatever the tagIndex, default: true"
+	config.Add(tagsTableEnabledRule)
+
+	tagsTTLRule, err := cpolicy.NewIntegerRule(tagsTTLRuleKey, false, -1)
+	handleErr(err)
+	tagsTTLRule.Description = "Seconds after which rows of the tags table expire, 0 disables it, -1 uses ttl, default: -1"
+	config.Add(tagsTTLRule)
+
+	timeColumnRule, err := cpolicy.NewStringRule(timeColumnRuleKey, false, "time")
+	handleErr(err)
+	timeColumnRule.Description = "Name of the time column of the metrics table, default: time"
+	config.Add(timeColumnRule)
+
+	timeColumnTypeRule, err := cpolicy.NewStringRule(timeColumnTypeRuleKey, false, timeColumnTimestamp)
+	handleErr(err)
+	timeColumnTypeRule.Description = "Type of the time column of the metrics table: timestamp or timeuuid, unique for samples of the same millisecond, default: timestamp"
+	config.Add(timeColumnTypeRule)
+
+	timeoutRule, err := cpolicy.NewIntegerRule(timeoutRuleKey, false)
 	handleErr(err)
 	timeoutRule.Description = "Connection timeout in seconds, default: 2"
 	config.Add(timeoutRule)
 
+	tokenAwareRule, err := cpolicy.NewBoolRule(tokenAwareRuleKey, false, false)
+	handleErr(err)
+	tokenAwareRule.Description = "If true, route inserts directly to a replica of their partition, default: false"
+	config.Add(tokenAwareRule)
+
+	ttlRule, err := cpolicy.NewIntegerRule(ttlRuleKey, false, 0)
+	handleErr(err)
+	ttlRule.Description = "Seconds after which rows of the metrics table expire, 0 disables it, default: 0"
+	config.Add(ttlRule)
+
 	usernameRule, err := cpolicy.NewStringRule(usernameRuleKey, false, "")
 	handleErr(err)
 	usernameRule.Description = "Name of a user used to authenticate to Cassandra"
 	config.Add(usernameRule)
 
+	valTypeRule, err := cpolicy.NewStringRule(valTypeRuleKey, false, valTypeColumn)
+	handleErr(err)
+	valTypeRule.Description = "Content of the valType column: column (e.g. doubleVal), name (e.g. double) or none to not write it, default: column"
+	config.Add(valTypeRule)
+
+	varintValRule, err := cpolicy.NewBoolRule(varintValRuleKey, false, false)
+	handleErr(err)
+	varintValRule.Description = "If true, store unsigned 64 bit integers in the varintVal column, keeping their full range, instead of as doubles, default: false"
+	config.Add(varintValRule)
+
+	versionTagRule, err := cpolicy.NewStringRule(versionTagRuleKey, false, "")
+	handleErr(err)
+	versionTagRule.Description = "Name of the tag carrying an application or schema version, stored in the appVer column apart from the plugin version"
+	config.Add(versionTagRule)
+
+	writeConcurrencyRule, err := cpolicy.NewIntegerRule(writeConcurrencyRuleKey, false)
+	handleErr(err)
+	writeConcurrencyRule.Description = "Number of workers writing the metrics of a publish concurrently, default: 1"
+	config.Add(writeConcurrencyRule)
+
+	writeProbeRule, err := cpolicy.NewBoolRule(writeProbeRuleKey, false, false)
+	handleErr(err)
+	writeProbeRule.Description = "If true, a probe row is written into the metrics table and deleted again before the first metrics are written, publishes fail until it succeeds, default: false"
+	config.Add(writeProbeRule)
+
+	writeProfileRule, err := cpolicy.NewStringRule(writeProfileRuleKey, false, "")
+	handleErr(err)
+	writeProfileRule.Description = "Bundle of consistency, retries, batching, write concurrency and timeout: fast, balanced or durable; explicit settings win, default: none"
+	config.Add(writeProfileRule)
+
+	writeTimestampRule, err := cpolicy.NewBoolRule(writeTimestampRuleKey, false, false)
+	handleErr(err)
+	writeTimestampRule.Description = "If true, the write timestamp of metrics rows is the metric timestamp (USING TIMESTAMP), so re-publishes are idempotent, default: false"
+	config.Add(writeTimestampRule)
+
 	cp.Add([]string{""}, config)
 	return cp, nil
 }
 
 // Publish publishes metric data to Cassandra
 func (cas *CassandraPublisher) Publish(contentType string, content []byte, config map[string]ctypes.ConfigValue) error {
+	cas.publishes.acquire()
+	defer cas.publishes.release()
+
 	logger := getLogger(config)
+	timings := newPublishTimings()
+	start := time.Now()
 	var metrics []plugin.MetricType
+	var err error
 
 	switch contentType {
 	case plugin.SnapGOBContentType:
-		dec := gob.NewDecoder(bytes.NewBuffer(content))
-		if err := dec.Decode(&metrics); err != nil {
+		metrics, err = decodeContent(content)
+	case plugin.SnapJSONContentType:
+		metrics, err = decodeJSONContent(content)
+	default:
+		logger.Errorf("unknown content type '%v'", contentType)
+		return fmt.Errorf("Unknown content type '%s'", contentType)
+	}
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"err": err,
+		}).Error("decoding error")
+		return err
+	}
+	timings.addDecode(time.Since(start))
+
+	clients, err := cas.clientsFor(config, logger)
+	switch err {
+	case nil:
+		err = clients.saveMetrics(metrics, timings)
+	case ErrConnecting:
+		err = clients.buffer(metrics, timings)
+	}
+	clients.alert.record(err, time.Now())
+	if timings != nil {
+		logger.WithFields(timings.fields(time.Since(start))).Debug("publish latency breakdown")
+	}
+
+	maxErrorLength, ok := getValueForKey(config, maxErrorLengthRuleKey).(int)
+	checkAssertion(ok, maxErrorLengthRuleKey)
+	return boundError(err, maxErrorLength, logger)
+}
+
+// saveMetrics saves metrics to the clusters they are routed to. If any client
+// is overloaded, an OverloadedError holding the other errors is returned.
+// The latency of the writes is added to timings if it is not nil.
+func (c *configClients) saveMetrics(metrics []plugin.MetricType, timings *publishTimings) error {
+	errs := []string{}
+	var overloaded *OverloadedError
+	for client, mts := range c.groupByCluster(metrics) {
+		err := client.publish(mts, timings)
+		if oe, ok := err.(*OverloadedError); ok && overloaded == nil {
+			overloaded = oe
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if overloaded != nil {
+		if overloaded.Err != nil {
+			errs = append(errs, overloaded.Err.Error())
+		}
+		if len(errs) > 0 {
+			overloaded = &OverloadedError{Queue: overloaded.Queue, Dropped: overloaded.Dropped, Err: errors.New(strings.Join(errs, ";"))}
+		}
+		return overloaded
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ";"))
+	}
+	return nil
+}
+
+// clientsFor returns the clients of a publisher config, so tasks publishing
+// with different configs never share clients. The clients are returned
+// along with initialization errors.
+func (cas *CassandraPublisher) clientsFor(config map[string]ctypes.ConfigValue, logger *log.Entry) (*configClients, error) {
+	cas.mu.Lock()
+	if cas.configs == nil {
+		cas.configs = map[string]*configClients{}
+	}
+	key := configKey(config)
+	clients, ok := cas.configs[key]
+	if !ok {
+		clients = &configClients{alert: getFailureAlert(config), background: getBackgroundConnect(config)}
+		cas.configs[key] = clients
+	}
+	// the clients connect without holding the lock, so a slow cluster
+	// only delays the publishes of its own config
+	cas.mu.Unlock()
+	if clients.background != nil {
+		return clients, clients.connectInBackground(config, logger)
+	}
+	return clients, clients.init(config, logger)
+}
+
+// configKey returns a hash identifying a publisher config.
+func configKey(config map[string]ctypes.ConfigValue) string {
+	keys := make([]string, 0, len(config))
+	for k := range config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := sha1.New()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s=%#v;", k, config[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// init initializes the clients of the publisher config once if possible.
+// Clients whose session cannot be created are created on the next publish,
+// so snap can retry the task instead of the plugin exiting.
+func (c *configClients) init(config map[string]ctypes.ConfigValue, logger *log.Entry) error {
+	c.initMu.Lock()
+	defer c.initMu.Unlock()
+	if c.ready {
+		return nil
+	}
+	co := prepareClientOptions(config)
+	tagIndex, ok := getValueForKey(config, tagIndexRuleKey).(string)
+	checkAssertion(ok, tagIndex)
+
+	if c.clusterClients == nil {
+		logger.WithFields(buildFields()).Info("Cassandra publisher starting")
+		if co.metadataOnly && (tagIndex == "" || !co.tagsTableEnabled) {
+			logger.Warn("metadataOnly is set without tagIndex or with tagsTableEnabled unset, no metrics will be written")
+		}
+		for _, w := range lintOptions(co) {
+			logger.Warn(w)
+		}
+
+		routes, err := parseConfigRoutes(config)
+		if err != nil {
 			logger.WithFields(log.Fields{
 				"err": err,
-			}).Error("decoding error")
+			}).Error("invalid routes")
 			return err
 		}
-	default:
-		logger.Errorf("unknown content type '%v'", contentType)
-		return fmt.Errorf("Unknown content type '%s'", contentType)
+		c.routes = routes.clusters
+		c.routeCache = newRouteCache(co.routeCacheSize)
+		c.clusterClients = map[string]*cassaClient{}
+		c.tableRoutes = routes.tables
+		c.tableCache = newRouteCache(co.routeCacheSize)
+		c.tableClients = map[tableTarget]*cassaClient{}
+		c.profiles = routes.profiles
+	}
+
+	// Initialize the client of the config and of every routed cluster and
+	// table, skipping the ones initialized by a previous attempt. The clients
+	// are initialized concurrently, as every client sets up its schema.
+	routes := configRoutes{clusters: c.routes, tables: c.tableRoutes, profiles: c.profiles}
+	targets := []clientTarget{}
+	for _, t := range routes.targets(co) {
+		if c.clientOf(t.target) == nil {
+			targets = append(targets, t)
+		}
+	}
+	clients := make([]*cassaClient, len(targets))
+	err := runConcurrently(len(targets), co.schemaConcurrency, func(i int) error {
+		var err error
+		clients[i], err = NewCassaClient(tagIndex, targets[i].opts...)
+		return err
+	})
+	// the clients initialized are kept for the next attempt
+	for i, client := range clients {
+		if client != nil {
+			c.setClient(targets[i].target, client)
+		}
+	}
+	if err != nil {
+		return err
 	}
+	c.ready = true
+	return nil
+}
 
-	// Only initialize client once if possible
-	if cas.client == nil {
-		co := prepareClientOptions(config)
+// clientOf returns the client of the target, nil if it is not initialized.
+func (c *configClients) clientOf(target tableTarget) *cassaClient {
+	switch {
+	case target == tableTarget{}:
+		return c.client
+	case target.table == "":
+		return c.clusterClients[target.server]
+	}
+	return c.tableClients[target]
+}
 
-		// Initialize a new client.
-		tagIndex, ok := getValueForKey(config, tagIndexRuleKey).(string)
-		checkAssertion(ok, tagIndex)
-		cas.client = NewCassaClient(co, tagIndex)
+// setClient sets the client of the target.
+func (c *configClients) setClient(target tableTarget, client *cassaClient) {
+	switch {
+	case target == tableTarget{}:
+		c.client = client
+	case target.table == "":
+		c.clusterClients[target.server] = client
+	default:
+		c.tableClients[target] = client
 	}
-	return cas.client.saveMetrics(metrics)
 }
 
-// Close closes the Cassandra client session
-func (cas *CassandraPublisher) Close() {
-	if cas.client != nil {
-		cas.client.session.Close()
+// groupByCluster splits metrics by the client of the cluster and the table
+// they are routed to.
+func (c *configClients) groupByCluster(metrics []plugin.MetricType) map[*cassaClient][]plugin.MetricType {
+	groups := map[*cassaClient][]plugin.MetricType{}
+	for _, m := range metrics {
+		ns := m.Namespace().String()
+		client := c.client
+		server, routed := c.routeCache.match(c.routes, ns)
+		if routed {
+			client = c.clusterClients[server]
+		}
+		if table, ok := c.tableCache.match(c.tableRoutes, ns); ok {
+			client = c.tableClients[tableTarget{server: server, table: table}]
+		}
+		groups[client] = append(groups[client], m)
 	}
+	return groups
+}
+
+// Close closes the Cassandra client sessions
+func (cas *CassandraPublisher) Close() {
+	cas.mu.Lock()
+	defer cas.mu.Unlock()
+	closeSessions()
+	cas.configs = map[string]*configClients{}
 }
 
 func prepareClientOptions(config map[string]ctypes.ConfigValue) clientOptions {
+	config = applyWriteProfile(config)
 	serverAddr, ok := getValueForKey(config, serverAddrRuleKey).(string)
 	checkAssertion(ok, serverAddrRuleKey)
 	serverPort, ok := getValueForKey(config, portRuleKey).(int)
@@ -219,42 +1068,486 @@ func prepareClientOptions(config map[string]ctypes.ConfigValue) clientOptions {
 	checkAssertion(ok, initialHostLookupRuleKey)
 	ignorePeerAddr, ok := getValueForKey(config, ignorePeerAddrRuleKey).(bool)
 	checkAssertion(ok, ignorePeerAddrRuleKey)
+	idleValidation, ok := getValueForKey(config, idleValidationRuleKey).(int)
+	checkAssertion(ok, idleValidationRuleKey)
+	healthCheckInterval, ok := getValueForKey(config, healthCheckIntervalRuleKey).(int)
+	checkAssertion(ok, healthCheckIntervalRuleKey)
+	events, ok := getValueForKey(config, disabledEventsRuleKey).(string)
+	checkAssertion(ok, disabledEventsRuleKey)
+	reconnectInterval, ok := getValueForKey(config, reconnectIntervalRuleKey).(int)
+	checkAssertion(ok, reconnectIntervalRuleKey)
+	poolStatsInterval, ok := getValueForKey(config, poolStatsIntervalRuleKey).(int)
+	checkAssertion(ok, poolStatsIntervalRuleKey)
+
+	disabledEvents, err := parseDisabledEvents(events)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"value":             events,
+			"acceptable values": "status, topology, schema",
+		}).Warn("invalid config value")
+	}
+	compression, ok := getValueForKey(config, compressionRuleKey).(string)
+	checkAssertion(ok, compressionRuleKey)
+	switch compression {
+	case compressionNone, compressionSnappy:
+	default:
+		log.WithFields(log.Fields{
+			"value":             compression,
+			"acceptable values": "none, snappy",
+		}).Warn("invalid config value")
+		compression = compressionNone
+	}
 	keyspaceName, ok := getValueForKey(config, keyspaceNameRuleKey).(string)
 	checkAssertion(ok, keyspaceNameRuleKey)
+	localDC, ok := getValueForKey(config, localDCRuleKey).(string)
+	checkAssertion(ok, localDCRuleKey)
+	tokenAware, ok := getValueForKey(config, tokenAwareRuleKey).(bool)
+	checkAssertion(ok, tokenAwareRuleKey)
 	createKeyspace, ok := getValueForKey(config, createKeyspaceRuleKey).(bool)
 	checkAssertion(ok, createKeyspaceRuleKey)
 	useSslOptions, ok := getValueForKey(config, sslOptionsRuleKey).(bool)
 	checkAssertion(ok, sslOptionsRuleKey)
 	tableName, ok := getValueForKey(config, tableNameRuleKey).(string)
 	checkAssertion(ok, tableNameRuleKey)
+	identifierCase, ok := getValueForKey(config, identifierCaseRuleKey).(string)
+	checkAssertion(ok, identifierCaseRuleKey)
+	switch identifierCase {
+	case identifierLower, identifierPreserve:
+	default:
+		log.WithFields(log.Fields{
+			"value":             identifierCase,
+			"acceptable values": "lower, preserve",
+		}).Warn("invalid config value")
+		identifierCase = identifierLower
+	}
+	timeColumn, ok := getValueForKey(config, timeColumnRuleKey).(string)
+	checkAssertion(ok, timeColumnRuleKey)
+	if timeColumn == "" {
+		log.WithFields(log.Fields{
+			"value":             timeColumn,
+			"acceptable values": "non-empty column names",
+		}).Warn("invalid config value")
+		timeColumn = "time"
+	}
+	timeColumnType, ok := getValueForKey(config, timeColumnTypeRuleKey).(string)
+	checkAssertion(ok, timeColumnTypeRuleKey)
+	switch timeColumnType {
+	case timeColumnTimestamp, timeColumnTimeUUID:
+	default:
+		log.WithFields(log.Fields{
+			"value":             timeColumnType,
+			"acceptable values": "timestamp, timeuuid",
+		}).Warn("invalid config value")
+		timeColumnType = timeColumnTimestamp
+	}
+	writeTimestamp, ok := getValueForKey(config, writeTimestampRuleKey).(bool)
+	checkAssertion(ok, writeTimestampRuleKey)
+	tableTemplate, insertTemplate := getSchemaTemplates(config, keyspaceName, tableName)
+	schemaAgreement, ok := getValueForKey(config, schemaAgreementRuleKey).(int)
+	checkAssertion(ok, schemaAgreementRuleKey)
+	schemaConcurrency, ok := getValueForKey(config, schemaConcurrencyRuleKey).(int)
+	checkAssertion(ok, schemaConcurrencyRuleKey)
+	schemaBufferSize, ok := getValueForKey(config, schemaBufferSizeRuleKey).(int)
+	checkAssertion(ok, schemaBufferSizeRuleKey)
+	if schemaBufferSize < 0 {
+		log.WithFields(log.Fields{
+			"value":             schemaBufferSize,
+			"acceptable values": "non-negative integers",
+		}).Warn("invalid config value")
+		schemaBufferSize = 0
+	}
+	valTypeMode, ok := getValueForKey(config, valTypeRuleKey).(string)
+	checkAssertion(ok, valTypeRuleKey)
+	readOnly, ok := getValueForKey(config, readOnlyRuleKey).(bool)
+	checkAssertion(ok, readOnlyRuleKey)
+	metadataOnly, ok := getValueForKey(config, metadataOnlyRuleKey).(bool)
+	checkAssertion(ok, metadataOnlyRuleKey)
+	tagsTableEnabled, ok := getValueForKey(config, tagsTableEnabledRuleKey).(bool)
+	checkAssertion(ok, tagsTableEnabledRuleKey)
+	versionTag, ok := getValueForKey(config, versionTagRuleKey).(string)
+	checkAssertion(ok, versionTagRuleKey)
+	consistencyName, ok := getValueForKey(config, consistencyRuleKey).(string)
+	checkAssertion(ok, consistencyRuleKey)
+
+	consistency, err := gocql.ParseConsistencyWrapper(consistencyName)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"value":             consistencyName,
+			"acceptable values": "ANY, ONE, TWO, THREE, QUORUM, ALL, LOCAL_QUORUM, EACH_QUORUM, LOCAL_ONE",
+		}).Warn("invalid config value")
+		consistency = gocql.One
+	}
+	consistencyRules, ok := getValueForKey(config, consistencyRoutesRuleKey).(string)
+	checkAssertion(ok, consistencyRoutesRuleKey)
+	consistencyRoutes, err := parseConsistencyRoutes(consistencyRules)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"value":             consistencyRules,
+			"acceptable values": "namespace prefix -> ANY, ONE, TWO, THREE, QUORUM, ALL, LOCAL_QUORUM, EACH_QUORUM, LOCAL_ONE",
+		}).Warn("invalid config value")
+		consistencyRoutes = nil
+	}
+	routeCacheSize, ok := getValueForKey(config, routeCacheSizeRuleKey).(int)
+	checkAssertion(ok, routeCacheSizeRuleKey)
+	sharedTagSets, ok := getValueForKey(config, sharedTagSetsRuleKey).(bool)
+	checkAssertion(ok, sharedTagSetsRuleKey)
+	boolTransitions, ok := getValueForKey(config, boolTransitionsRuleKey).(bool)
+	checkAssertion(ok, boolTransitionsRuleKey)
+	adaptiveBatching, ok := getValueForKey(config, adaptiveBatchingRuleKey).(int)
+	checkAssertion(ok, adaptiveBatchingRuleKey)
+	if adaptiveBatching < 0 {
+		log.WithFields(log.Fields{
+			"value":             adaptiveBatching,
+			"acceptable values": "non-negative integers",
+		}).Warn("invalid config value")
+		adaptiveBatching = 0
+	}
+	batchSize, ok := getValueForKey(config, batchSizeRuleKey).(int)
+	checkAssertion(ok, batchSizeRuleKey)
+	batchByPartition, ok := getValueForKey(config, batchByPartitionRuleKey).(bool)
+	checkAssertion(ok, batchByPartitionRuleKey)
+	buildInfo, ok := getValueForKey(config, buildInfoRuleKey).(bool)
+	checkAssertion(ok, buildInfoRuleKey)
+	selfStatsFile, ok := getValueForKey(config, selfStatsFileRuleKey).(string)
+	checkAssertion(ok, selfStatsFileRuleKey)
+	selfStatsInterval, ok := getValueForKey(config, selfStatsIntervalRuleKey).(int)
+	checkAssertion(ok, selfStatsIntervalRuleKey)
+	selfStatsTable, ok := getValueForKey(config, selfStatsTableRuleKey).(bool)
+	checkAssertion(ok, selfStatsTableRuleKey)
+	checksum, ok := getValueForKey(config, checksumRuleKey).(bool)
+	checkAssertion(ok, checksumRuleKey)
+	coalesceDelay, ok := getValueForKey(config, coalesceDelayRuleKey).(int)
+	checkAssertion(ok, coalesceDelayRuleKey)
+	coalesceMaxMetrics, ok := getValueForKey(config, coalesceMaxMetricsRuleKey).(int)
+	checkAssertion(ok, coalesceMaxMetricsRuleKey)
+	if coalesceMaxMetrics < 1 {
+		log.WithFields(log.Fields{
+			"value":             coalesceMaxMetrics,
+			"acceptable values": "positive integers",
+		}).Warn("invalid config value")
+		coalesceMaxMetrics = 1000
+	}
+	ingestTime, ok := getValueForKey(config, ingestTimeRuleKey).(bool)
+	checkAssertion(ok, ingestTimeRuleKey)
+	maxInFlight, ok := getValueForKey(config, maxInFlightRuleKey).(int)
+	checkAssertion(ok, maxInFlightRuleKey)
+	maxWritesPerSecond, ok := getValueForKey(config, maxWritesPerSecondRuleKey).(int)
+	checkAssertion(ok, maxWritesPerSecondRuleKey)
+	maxMetricAge, ok := getValueForKey(config, maxMetricAgeRuleKey).(int)
+	checkAssertion(ok, maxMetricAgeRuleKey)
+	if maxMetricAge < 0 {
+		log.WithFields(log.Fields{
+			"value":             maxMetricAge,
+			"acceptable values": "non-negative integers",
+		}).Warn("invalid config value")
+		maxMetricAge = 0
+	}
+	outOfOrder, ok := getValueForKey(config, outOfOrderRuleKey).(string)
+	checkAssertion(ok, outOfOrderRuleKey)
+	switch outOfOrder {
+	case "", outOfOrderReport, outOfOrderFlag:
+	default:
+		log.WithFields(log.Fields{
+			"value":             outOfOrder,
+			"acceptable values": "report, flag",
+		}).Warn("invalid config value")
+		outOfOrder = ""
+	}
+	createSchema, ok := getValueForKey(config, createSchemaRuleKey).(bool)
+	checkAssertion(ok, createSchemaRuleKey)
+	createHostView, ok := getValueForKey(config, createHostViewRuleKey).(bool)
+	checkAssertion(ok, createHostViewRuleKey)
+	createTagMapIndex, ok := getValueForKey(config, createTagMapIndexRuleKey).(bool)
+	checkAssertion(ok, createTagMapIndexRuleKey)
+	writeProbe, ok := getValueForKey(config, writeProbeRuleKey).(bool)
+	checkAssertion(ok, writeProbeRuleKey)
+	strValIndex, ok := getValueForKey(config, strValIndexRuleKey).(string)
+	checkAssertion(ok, strValIndexRuleKey)
+	switch strings.ToUpper(strValIndex) {
+	case "", strValIndexPrefix, strValIndexContains:
+		strValIndex = strings.ToUpper(strValIndex)
+	default:
+		log.WithFields(log.Fields{
+			"value":             strValIndex,
+			"acceptable values": "PREFIX, CONTAINS",
+		}).Warn("invalid config value")
+		strValIndex = ""
+	}
+	nsColumns, ok := getValueForKey(config, nsColumnsRuleKey).(int)
+	checkAssertion(ok, nsColumnsRuleKey)
+	if nsColumns < 0 {
+		log.WithFields(log.Fields{
+			"value":             nsColumns,
+			"acceptable values": "non-negative integers",
+		}).Warn("invalid config value")
+		nsColumns = 0
+	}
+	nsRewrite, ok := getValueForKey(config, nsRewriteRuleKey).(string)
+	checkAssertion(ok, nsRewriteRuleKey)
+	nsRewrites, err := parseNsRewrites(nsRewrite)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"value":             nsRewrite,
+			"acceptable values": "strip:prefix, segment:from->to, regex:pattern->replacement",
+			"err":               err,
+		}).Warn("invalid config value")
+		nsRewrites = nil
+	}
+	counterResets, ok := getValueForKey(config, counterResetsRuleKey).(string)
+	checkAssertion(ok, counterResetsRuleKey)
+	switch counterResets {
+	case "", counterResetFlag, counterResetMarker:
+	default:
+		log.WithFields(log.Fields{
+			"value":             counterResets,
+			"acceptable values": "flag, marker",
+		}).Warn("invalid config value")
+		counterResets = ""
+	}
+	counterNamespaces, ok := getValueForKey(config, counterNamespacesRuleKey).(string)
+	checkAssertion(ok, counterNamespacesRuleKey)
+	partitionBucket, ok := getValueForKey(config, partitionBucketRuleKey).(string)
+	checkAssertion(ok, partitionBucketRuleKey)
+	bucketSize, ok := bucketSizes[partitionBucket]
+	if !ok && partitionBucket != "" {
+		log.WithFields(log.Fields{
+			"value":             partitionBucket,
+			"acceptable values": "hour, day, week",
+		}).Warn("invalid config value")
+	}
+	int64Val, ok := getValueForKey(config, int64ValRuleKey).(bool)
+	checkAssertion(ok, int64ValRuleKey)
+	varintVal, ok := getValueForKey(config, varintValRuleKey).(bool)
+	checkAssertion(ok, varintValRuleKey)
+	doublePrecision, ok := getValueForKey(config, doublePrecisionRuleKey).(int)
+	checkAssertion(ok, doublePrecisionRuleKey)
+	if doublePrecision < -1 {
+		log.WithFields(log.Fields{
+			"value":             doublePrecision,
+			"acceptable values": "-1 or non-negative integers",
+		}).Warn("invalid config value")
+		doublePrecision = -1
+	}
+	staticColumns, ok := getValueForKey(config, staticColumnsRuleKey).(bool)
+	checkAssertion(ok, staticColumnsRuleKey)
+	hostTags, ok := getValueForKey(config, hostTagsRuleKey).(string)
+	checkAssertion(ok, hostTagsRuleKey)
+	tagBatchSize, ok := getValueForKey(config, tagBatchSizeRuleKey).(int)
+	checkAssertion(ok, tagBatchSizeRuleKey)
+	tagRowsWithMetric, ok := getValueForKey(config, tagRowsWithMetricRuleKey).(bool)
+	checkAssertion(ok, tagRowsWithMetricRuleKey)
+	tagSetCacheSize, ok := getValueForKey(config, tagSetCacheSizeRuleKey).(int)
+	checkAssertion(ok, tagSetCacheSizeRuleKey)
+	tagSetInterval, ok := getValueForKey(config, tagSetIntervalRuleKey).(int)
+	checkAssertion(ok, tagSetIntervalRuleKey)
+	if tagSetInterval < 0 {
+		log.WithFields(log.Fields{
+			"value":             tagSetInterval,
+			"acceptable values": "non-negative integers",
+		}).Warn("invalid config value")
+		tagSetInterval = 0
+	}
+	if tagBatchSize < 0 {
+		log.WithFields(log.Fields{
+			"value":             tagBatchSize,
+			"acceptable values": "0 or positive integers",
+		}).Warn("invalid config value")
+		tagBatchSize = 0
+	}
+	staticTagsValue, ok := getValueForKey(config, staticTagsRuleKey).(string)
+	checkAssertion(ok, staticTagsRuleKey)
+	staticTags, err := parseStaticTags(staticTagsValue)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"value":             staticTagsValue,
+			"acceptable values": "comma separated key:value pairs",
+		}).Warn("invalid config value")
+		staticTags = nil
+	}
+	tagsInclude, ok := getValueForKey(config, tagsIncludeRuleKey).(string)
+	checkAssertion(ok, tagsIncludeRuleKey)
+	tagsExclude, ok := getValueForKey(config, tagsExcludeRuleKey).(string)
+	checkAssertion(ok, tagsExcludeRuleKey)
+
+	writeConcurrency, ok := getValueForKey(config, writeConcurrencyRuleKey).(int)
+	checkAssertion(ok, writeConcurrencyRuleKey)
+	retryAttempts, ok := getValueForKey(config, retryAttemptsRuleKey).(int)
+	checkAssertion(ok, retryAttemptsRuleKey)
+	retryDelay, ok := getValueForKey(config, retryDelayRuleKey).(int)
+	checkAssertion(ok, retryDelayRuleKey)
+	retryJitter, ok := getValueForKey(config, retryJitterRuleKey).(int)
+	checkAssertion(ok, retryJitterRuleKey)
+	speculativeAttempts, ok := getValueForKey(config, speculativeAttemptsRuleKey).(int)
+	checkAssertion(ok, speculativeAttemptsRuleKey)
+	speculativeDelay, ok := getValueForKey(config, speculativeDelayRuleKey).(int)
+	checkAssertion(ok, speculativeDelayRuleKey)
+	spoolPath, ok := getValueForKey(config, spoolPathRuleKey).(string)
+	checkAssertion(ok, spoolPathRuleKey)
+	spoolMaxSize, ok := getValueForKey(config, spoolMaxSizeRuleKey).(int)
+	checkAssertion(ok, spoolMaxSizeRuleKey)
+	spoolSegmentSize, ok := getValueForKey(config, spoolSegmentSizeRuleKey).(int)
+	checkAssertion(ok, spoolSegmentSizeRuleKey)
+	compaction := getCompactionOptions(config)
+	driver := getDriverOptions(config)
+	replication := getReplicationOptions(config)
+	ttl, ok := getValueForKey(config, ttlRuleKey).(int)
+	checkAssertion(ok, ttlRuleKey)
+	tagsTTL, ok := getValueForKey(config, tagsTTLRuleKey).(int)
+	checkAssertion(ok, tagsTTLRuleKey)
+	if tagsTTL < 0 {
+		tagsTTL = ttl
+	}
+	tagsKeyspace, ok := getValueForKey(config, tagsKeyspaceRuleKey).(string)
+	checkAssertion(ok, tagsKeyspaceRuleKey)
+	if tagsKeyspace == "" {
+		tagsKeyspace = keyspaceName
+	}
+
+	switch valTypeMode {
+	case valTypeColumn, valTypeName, valTypeNone:
+	default:
+		log.WithFields(log.Fields{
+			"value":             valTypeMode,
+			"acceptable values": "column, name, none",
+		}).Warn("invalid config value")
+		valTypeMode = valTypeColumn
+	}
 
 	var sslOptions *sslOptions
 	if useSslOptions {
 		sslOptions = getSslOptions(config)
 	}
+	sigv4 := getSigV4Options(config)
 
 	return clientOptions{
-		server:            serverAddr,
-		port:              serverPort,
-		timeout:           time.Duration(timeout) * time.Second,
-		connectionTimeout: time.Duration(connTimeout) * time.Second,
-		initialHostLookup: initialHostLookup,
-		ignorePeerAddr:    ignorePeerAddr,
-		keyspace:          keyspaceName,
-		createKeyspace:    createKeyspace,
-		ssl:               sslOptions,
-		tableName:         tableName,
+		server:              serverAddr,
+		port:                serverPort,
+		timeout:             time.Duration(timeout) * time.Second,
+		connectionTimeout:   time.Duration(connTimeout) * time.Second,
+		initialHostLookup:   initialHostLookup,
+		ignorePeerAddr:      ignorePeerAddr,
+		idleValidation:      time.Duration(idleValidation) * time.Second,
+		healthCheckInterval: time.Duration(healthCheckInterval) * time.Second,
+		disabledEvents:      disabledEvents,
+		reconnectInterval:   time.Duration(reconnectInterval) * time.Second,
+		poolStatsInterval:   time.Duration(poolStatsInterval) * time.Second,
+		compression:         compression,
+		localDC:             localDC,
+		tokenAware:          tokenAware,
+		driver:              driver,
+		keyspace:            keyspaceName,
+		createKeyspace:      createKeyspace,
+		replication:         replication,
+		ssl:                 sslOptions,
+		sigv4:               sigv4,
+		tableName:           tableName,
+		preserveCase:        identifierCase == identifierPreserve,
+		timeColumn:          timeColumn,
+		timeColumnType:      timeColumnType,
+		writeTimestamp:      writeTimestamp,
+		tableTemplate:       tableTemplate,
+		insertTemplate:      insertTemplate,
+		tagsKeyspace:        tagsKeyspace,
+		ttl:                 ttl,
+		tagsTTL:             tagsTTL,
+		compaction:          compaction,
+		schemaAgreement:     time.Duration(schemaAgreement) * time.Second,
+		schemaConcurrency:   schemaConcurrency,
+		schemaBufferSize:    schemaBufferSize,
+		valTypeMode:         valTypeMode,
+		versionTag:          versionTag,
+		readOnly:            readOnly,
+		metadataOnly:        metadataOnly,
+		tagsTableEnabled:    tagsTableEnabled,
+		consistency:         consistency,
+		sharedTagSets:       sharedTagSets,
+		tagSetCacheSize:     tagSetCacheSize,
+		tagSetInterval:      time.Duration(tagSetInterval) * time.Second,
+		boolTransitions:     boolTransitions,
+		batchSize:           batchSize,
+		adaptiveBatching:    time.Duration(adaptiveBatching) * time.Millisecond,
+		batchByPartition:    batchByPartition,
+		maxInFlight:         maxInFlight,
+		maxWritesPerSecond:  maxWritesPerSecond,
+		consistencyRoutes:   consistencyRoutes,
+		routeCacheSize:      routeCacheSize,
+		buildInfo:           buildInfo,
+		selfStatsInterval:   time.Duration(selfStatsInterval) * time.Second,
+		selfStatsTable:      selfStatsTable,
+		selfStatsFile:       selfStatsFile,
+		checksum:            checksum,
+		coalesceDelay:       time.Duration(coalesceDelay) * time.Millisecond,
+		coalesceMaxMetrics:  coalesceMaxMetrics,
+		ingestTime:          ingestTime,
+		partitionBucket:     bucketSize,
+		maxMetricAge:        time.Duration(maxMetricAge) * time.Second,
+		outOfOrder:          outOfOrder,
+		skipSchema:          !createSchema,
+		createHostView:      createHostView,
+		createTagMapIndex:   createTagMapIndex,
+		strValIndex:         strValIndex,
+		writeProbe:          writeProbe,
+		nsColumns:           nsColumns,
+		nsRewrites:          nsRewrites,
+		counterResets:       counterResets,
+		counterNamespaces:   counterNamespaces,
+		int64Val:            int64Val,
+		varintVal:           varintVal,
+		doublePrecision:     doublePrecision,
+		staticColumns:       staticColumns,
+		hostTags:            parseHostTags(hostTags),
+		tagBatchSize:        tagBatchSize,
+		tagRowsWithMetric:   tagRowsWithMetric,
+		staticTags:          staticTags,
+		tagsInclude:         tagsInclude,
+		tagsExclude:         tagsExclude,
+		writeConcurrency:    writeConcurrency,
+		started:             time.Now(),
+		spoolPath:           spoolPath,
+		spoolMaxSize:        int64(spoolMaxSize) << 20,
+		spoolSegmentSize:    int64(spoolSegmentSize) << 20,
+		retry: retryPolicy{
+			attempts: retryAttempts,
+			delay:    time.Duration(retryDelay) * time.Millisecond,
+			jitter:   time.Duration(retryJitter) * time.Millisecond,
+			speculative: speculativePolicy{
+				attempts: speculativeAttempts,
+				delay:    time.Duration(speculativeDelay) * time.Millisecond,
+			},
+		},
 	}
 }
 
+// defaultValues holds the default values of the config policy, read once
+// for the keys missing from a config.
+var defaultValues struct {
+	once   sync.Once
+	values map[string]ctypes.ConfigValue
+}
+
+// defaultValue returns the default value of the rule of the key, nil if it has none.
+func defaultValue(key string) ctypes.ConfigValue {
+	defaultValues.once.Do(func() {
+		defaultValues.values = ruleDefaults()
+	})
+	return defaultValues.values[key]
+}
+
+// getValueForKey returns the value of the key in the config. Keys missing
+// from the config, e.g. of configs built without the config policy, take
+// the default value of their rule, or nil if the rule has none.
 func getValueForKey(cfg map[string]ctypes.ConfigValue, key string) interface{} {
 	if cfg == nil {
 		log.Error("Configuration of a plugin not found")
 	}
 	configElem := cfg[key]
+	if configElem == nil {
+		configElem = defaultValue(key)
+	}
 
 	if configElem == nil {
 		log.Errorf("Valid configuration not found for a key %s", key)
+		return nil
 	}
 	var value interface{}
 	switch configElem.Type() {
@@ -270,6 +1563,216 @@ func getValueForKey(cfg map[string]ctypes.ConfigValue, key string) interface{} {
 	return value
 }
 
+// getDriverOptions returns the advanced gocql settings of the config.
+func getDriverOptions(cfg map[string]ctypes.ConfigValue) driverOptions {
+	disableSkipMetadata, ok := getValueForKey(cfg, disableSkipMetadataRuleKey).(bool)
+	checkAssertion(ok, disableSkipMetadataRuleKey)
+	maxRoutingKeyInfo, ok := getValueForKey(cfg, maxRoutingKeyInfoRuleKey).(int)
+	checkAssertion(ok, maxRoutingKeyInfoRuleKey)
+	pageSize, ok := getValueForKey(cfg, pageSizeRuleKey).(int)
+	checkAssertion(ok, pageSizeRuleKey)
+	return driverOptions{
+		disableSkipMetadata: disableSkipMetadata,
+		maxRoutingKeyInfo:   maxRoutingKeyInfo,
+		pageSize:            pageSize,
+	}
+}
+
+// getReplicationOptions returns the replication options of the config. Invalid values fall back to the defaults.
+func getReplicationOptions(cfg map[string]ctypes.ConfigValue) replicationOptions {
+	strategy, ok := getValueForKey(cfg, replicationStrategyRuleKey).(string)
+	checkAssertion(ok, replicationStrategyRuleKey)
+	factor, ok := getValueForKey(cfg, replicationFactorRuleKey).(int)
+	checkAssertion(ok, replicationFactorRuleKey)
+	dcList, ok := getValueForKey(cfg, replicationDCsRuleKey).(string)
+	checkAssertion(ok, replicationDCsRuleKey)
+
+	if factor < 1 {
+		log.WithFields(log.Fields{
+			"value":             factor,
+			"acceptable values": "positive integers",
+		}).Warn("invalid config value")
+		factor = 1
+	}
+	dcs, err := parseReplicationDCs(dcList)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"value":             dcList,
+			"acceptable values": "comma separated dc:factor pairs",
+		}).Warn("invalid config value")
+		dcs = nil
+	}
+	switch strategy {
+	case replicationSimple:
+	case replicationTopology:
+		if len(dcs) == 0 {
+			log.WithFields(log.Fields{
+				"value":             dcList,
+				"acceptable values": "at least one dc:factor pair for NetworkTopologyStrategy",
+			}).Warn("invalid config value")
+			strategy = replicationSimple
+		}
+	default:
+		log.WithFields(log.Fields{
+			"value":             strategy,
+			"acceptable values": "SimpleStrategy, NetworkTopologyStrategy",
+		}).Warn("invalid config value")
+		strategy = replicationSimple
+	}
+	return replicationOptions{strategy: strategy, factor: factor, dcs: dcs}
+}
+
+// getCompactionOptions returns the compaction options of the config. Invalid values fall back to the defaults.
+func getCompactionOptions(cfg map[string]ctypes.ConfigValue) compactionOptions {
+	strategy, ok := getValueForKey(cfg, compactionStrategyRuleKey).(string)
+	checkAssertion(ok, compactionStrategyRuleKey)
+	unit, ok := getValueForKey(cfg, compactionUnitRuleKey).(string)
+	checkAssertion(ok, compactionUnitRuleKey)
+	size, ok := getValueForKey(cfg, compactionSizeRuleKey).(int)
+	checkAssertion(ok, compactionSizeRuleKey)
+
+	switch strategy {
+	case "", compactionSizeTiered, compactionLeveled, compactionTimeWindow:
+	default:
+		log.WithFields(log.Fields{
+			"value":             strategy,
+			"acceptable values": "SizeTieredCompactionStrategy, LeveledCompactionStrategy, TimeWindowCompactionStrategy",
+		}).Warn("invalid config value")
+		strategy = ""
+	}
+	unit = strings.ToUpper(unit)
+	switch unit {
+	case "MINUTES", "HOURS", "DAYS":
+	default:
+		log.WithFields(log.Fields{
+			"value":             unit,
+			"acceptable values": "MINUTES, HOURS, DAYS",
+		}).Warn("invalid config value")
+		unit = "DAYS"
+	}
+	if size < 1 {
+		log.WithFields(log.Fields{
+			"value":             size,
+			"acceptable values": "positive integers",
+		}).Warn("invalid config value")
+		size = 1
+	}
+	return compactionOptions{strategy: strategy, windowUnit: unit, windowSize: size}
+}
+
+// getFailureAlert returns the failure alert of the config, nil if it is disabled or invalid.
+func getFailureAlert(cfg map[string]ctypes.ConfigValue) *failureAlert {
+	url, ok := getValueForKey(cfg, alertWebhookRuleKey).(string)
+	checkAssertion(ok, alertWebhookRuleKey)
+	tmpl, ok := getValueForKey(cfg, alertTemplateRuleKey).(string)
+	checkAssertion(ok, alertTemplateRuleKey)
+	threshold, ok := getValueForKey(cfg, alertThresholdRuleKey).(int)
+	checkAssertion(ok, alertThresholdRuleKey)
+
+	if url == "" {
+		return nil
+	}
+	if threshold < 0 {
+		log.WithFields(log.Fields{
+			"value":             threshold,
+			"acceptable values": "non-negative integers",
+		}).Warn("invalid config value")
+		threshold = 300
+	}
+	alert, err := newFailureAlert(url, tmpl, time.Duration(threshold)*time.Second)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"value": tmpl,
+			"err":   err,
+		}).Warn("invalid alert template, alerts disabled")
+		return nil
+	}
+	return alert
+}
+
+// getBackgroundConnect returns the background connect of the config, nil if
+// the clients connect within the publish.
+func getBackgroundConnect(cfg map[string]ctypes.ConfigValue) *backgroundConnect {
+	mode, ok := getValueForKey(cfg, connectModeRuleKey).(string)
+	checkAssertion(ok, connectModeRuleKey)
+	bufferSize, ok := getValueForKey(cfg, connectBufferSizeRuleKey).(int)
+	checkAssertion(ok, connectBufferSizeRuleKey)
+
+	switch mode {
+	case connectBlocking:
+		return nil
+	case connectBackground:
+	default:
+		log.WithFields(log.Fields{
+			"value":             mode,
+			"acceptable values": connectBlocking + ", " + connectBackground,
+		}).Warn("invalid config value")
+		return nil
+	}
+	if bufferSize < 0 {
+		log.WithFields(log.Fields{
+			"value":             bufferSize,
+			"acceptable values": "non-negative integers",
+		}).Warn("invalid config value")
+		bufferSize = 10000
+	}
+	return newBackgroundConnect(bufferSize)
+}
+
+// getSchemaTemplates returns the table and the insert template of the config.
+// Invalid templates are ignored, so the built-in layout is used.
+func getSchemaTemplates(cfg map[string]ctypes.ConfigValue, keyspace, table string) (string, *insertTemplate) {
+	tableTemplate, ok := getValueForKey(cfg, tableTemplateRuleKey).(string)
+	checkAssertion(ok, tableTemplateRuleKey)
+	insertTmpl, ok := getValueForKey(cfg, insertTemplateRuleKey).(string)
+	checkAssertion(ok, insertTemplateRuleKey)
+
+	if tableTemplate != "" {
+		if _, err := tableTemplateCQL(tableTemplate, clientOptions{}); err != nil {
+			log.WithFields(log.Fields{
+				"value":             tableTemplate,
+				"acceptable values": "CQL with the placeholders {keyspace}, {table}, {timeColumn}, {timeColumnType}",
+			}).Warn("invalid config value")
+			tableTemplate = ""
+		}
+	}
+	if insertTmpl == "" {
+		return tableTemplate, nil
+	}
+	insert, err := parseInsertTemplate(insertTmpl, keyspace, table)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"value":             insertTmpl,
+			"acceptable values": "CQL with the placeholders of the README",
+			"err":               err,
+		}).Warn("invalid config value")
+		return tableTemplate, nil
+	}
+	return tableTemplate, insert
+}
+
+// getSigV4Options returns the SigV4 options of the config, nil without an AWS region.
+func getSigV4Options(cfg map[string]ctypes.ConfigValue) *sigv4Options {
+	region, ok := getValueForKey(cfg, awsRegionRuleKey).(string)
+	checkAssertion(ok, awsRegionRuleKey)
+	accessKeyID, ok := getValueForKey(cfg, awsAccessKeyIDRuleKey).(string)
+	checkAssertion(ok, awsAccessKeyIDRuleKey)
+	secretAccessKey, ok := getValueForKey(cfg, awsSecretAccessKeyRuleKey).(string)
+	checkAssertion(ok, awsSecretAccessKeyRuleKey)
+	sessionToken, ok := getValueForKey(cfg, awsSessionTokenRuleKey).(string)
+	checkAssertion(ok, awsSessionTokenRuleKey)
+
+	if region == "" {
+		return nil
+	}
+	return &sigv4Options{
+		region:          region,
+		accessKeyID:     accessKeyID,
+		secretAccessKey: secretAccessKey,
+		sessionToken:    sessionToken,
+	}
+}
+
 func getSslOptions(cfg map[string]ctypes.ConfigValue) *sslOptions {
 	username, ok := getValueForKey(cfg, usernameRuleKey).(string)
 	checkAssertion(ok, usernameRuleKey)
@@ -283,13 +1786,16 @@ func getSslOptions(cfg map[string]ctypes.ConfigValue) *sslOptions {
 	checkAssertion(ok, caPathRuleKey)
 	enableServerCertVerification, ok := getValueForKey(cfg, enableServerCertVerRuleKey).(bool)
 	checkAssertion(ok, enableServerCertVerRuleKey)
+	authorizationID, ok := getValueForKey(cfg, authorizationIDRuleKey).(string)
+	checkAssertion(ok, authorizationIDRuleKey)
 
 	options := sslOptions{
-		username: username,
-		password: password,
-		keyPath:  keyPath,
-		certPath: certPath,
-		caPath:   caPath,
+		username:                     username,
+		password:                     password,
+		authorizationID:              authorizationID,
+		keyPath:                      keyPath,
+		certPath:                     certPath,
+		caPath:                       caPath,
 		enableServerCertVerification: enableServerCertVerification,
 	}
 	return &options
@@ -317,13 +1823,13 @@ func getLogger(config map[string]ctypes.ConfigValue) *log.Entry {
 	})
 
 	// default
-	log.SetLevel(log.WarnLevel)
+	level := log.WarnLevel
 
 	if debug, ok := config["debug"]; ok {
 		switch v := debug.(type) {
 		case ctypes.ConfigValueBool:
 			if v.Value {
-				log.SetLevel(log.DebugLevel)
+				setLogLevel(log.DebugLevel)
 				return logger
 			}
 		default:
@@ -340,13 +1846,13 @@ func getLogger(config map[string]ctypes.ConfigValue) *log.Entry {
 		case ctypes.ConfigValueStr:
 			switch strings.ToLower(v.Value) {
 			case "warn":
-				log.SetLevel(log.WarnLevel)
+				level = log.WarnLevel
 			case "error":
-				log.SetLevel(log.ErrorLevel)
+				level = log.ErrorLevel
 			case "debug":
-				log.SetLevel(log.DebugLevel)
+				level = log.DebugLevel
 			case "info":
-				log.SetLevel(log.InfoLevel)
+				level = log.InfoLevel
 			default:
 				log.WithFields(log.Fields{
 					"value":             strings.ToLower(v.Value),
@@ -361,5 +1867,15 @@ func getLogger(config map[string]ctypes.ConfigValue) *log.Entry {
 			}).Error("invalid config type")
 		}
 	}
+	setLogLevel(level)
 	return logger
 }
+
+// setLogLevel sets the level of the logger unless it is set already, so
+// concurrent publishes of the same config do not write the level read by
+// the log calls of each other.
+func setLogLevel(level log.Level) {
+	if log.GetLevel() != level {
+		log.SetLevel(level)
+	}
+}
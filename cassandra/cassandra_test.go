@@ -21,11 +21,16 @@ limitations under the License.
 package cassandra
 
 import (
+	"bytes"
+	"encoding/gob"
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/gocql/gocql"
 	"github.com/intelsdi-x/snap/control/plugin"
 	"github.com/intelsdi-x/snap/control/plugin/cpolicy"
+	"github.com/intelsdi-x/snap/core"
 	"github.com/intelsdi-x/snap/core/ctypes"
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -48,6 +53,8 @@ func TestCassandraDBPlugin(t *testing.T) {
 		So(meta.Name, ShouldResemble, name)
 		So(meta.Version, ShouldResemble, version)
 		So(meta.Type, ShouldResemble, plugin.PublisherPluginType)
+		So(meta.AcceptedContentTypes, ShouldResemble, []string{plugin.SnapGOBContentType, plugin.SnapJSONContentType})
+		So(meta.ConcurrencyCount, ShouldEqual, maxConcurrentPublishes)
 	})
 
 	Convey("Create CassandraPublisher", t, func() {
@@ -85,6 +92,13 @@ func TestCassandraDBPlugin(t *testing.T) {
 				So(receivedServerAddress, ShouldEqual, serverAddress)
 				So(reflect.TypeOf(receivedServerAddress).String(), ShouldEqual, "string")
 			})
+			Convey("So keys missing from the config should take their defaults", func() {
+				port, ok := getValueForKey(testConfig, portRuleKey).(int)
+				So(ok, ShouldBeTrue)
+				So(port, ShouldEqual, 9042)
+				So(func() { getFailureAlert(testConfig) }, ShouldNotPanic)
+				So(getValueForKey(map[string]ctypes.ConfigValue{}, serverAddrRuleKey), ShouldBeNil)
+			})
 
 			testConfig = make(map[string]ctypes.ConfigValue)
 			testConfig["port"] = ctypes.ConfigValueStr{Value: "9042"}
@@ -201,3 +215,232 @@ func TestSslOptions(t *testing.T) {
 		})
 	})
 }
+
+func TestConsistency(t *testing.T) {
+	Convey("Prepare client options with a consistency level", t, func() {
+		configPolicy, err := NewCassandraPublisher().GetConfigPolicy()
+		So(err, ShouldBeNil)
+
+		testConfig := make(map[string]ctypes.ConfigValue)
+		testConfig[serverAddrRuleKey] = ctypes.ConfigValueStr{Value: serverAddress}
+		_, errs := configPolicy.Get([]string{""}).Process(testConfig)
+		So(errs.HasErrors(), ShouldBeFalse)
+
+		Convey("So the default consistency should be ONE", func() {
+			So(prepareClientOptions(testConfig).consistency, ShouldEqual, gocql.One)
+		})
+		Convey("So a given consistency should be applied to the cluster", func() {
+			testConfig[consistencyRuleKey] = ctypes.ConfigValueStr{Value: "local_quorum"}
+			co := prepareClientOptions(testConfig)
+			So(co.consistency, ShouldEqual, gocql.LocalQuorum)
+			So(createCluster(co).Consistency, ShouldEqual, gocql.LocalQuorum)
+		})
+		Convey("So an invalid consistency should fall back to ONE", func() {
+			testConfig[consistencyRuleKey] = ctypes.ConfigValueStr{Value: "most"}
+			So(prepareClientOptions(testConfig).consistency, ShouldEqual, gocql.One)
+		})
+	})
+}
+
+func TestBuildFields(t *testing.T) {
+	Convey("Get the build metadata", t, func() {
+		fields := buildFields()
+		So(fields["version"], ShouldEqual, version)
+		So(fields["buildVersion"], ShouldEqual, buildVersion)
+		So(fields["gitCommit"], ShouldEqual, gitCommit)
+	})
+}
+
+func TestTagsKeyspace(t *testing.T) {
+	Convey("Prepare client options with a keyspace for the tags table", t, func() {
+		configPolicy, err := NewCassandraPublisher().GetConfigPolicy()
+		So(err, ShouldBeNil)
+
+		testConfig := make(map[string]ctypes.ConfigValue)
+		testConfig[serverAddrRuleKey] = ctypes.ConfigValueStr{Value: serverAddress}
+		testConfig[keyspaceNameRuleKey] = ctypes.ConfigValueStr{Value: "metrics_ks"}
+		_, errs := configPolicy.Get([]string{""}).Process(testConfig)
+		So(errs.HasErrors(), ShouldBeFalse)
+
+		Convey("So the tags table should default to the keyspace of the metrics table", func() {
+			So(prepareClientOptions(testConfig).tagsKeyspace, ShouldEqual, "metrics_ks")
+		})
+		Convey("So a given keyspace should be used for the tags table", func() {
+			testConfig[tagsKeyspaceRuleKey] = ctypes.ConfigValueStr{Value: "tags_ks"}
+			co := prepareClientOptions(testConfig)
+			So(co.keyspace, ShouldEqual, "metrics_ks")
+			So(co.tagsKeyspace, ShouldEqual, "tags_ks")
+		})
+	})
+}
+
+func TestPublishUnreachableCluster(t *testing.T) {
+	Convey("Publish to an unreachable Cassandra cluster", t, func() {
+		configPolicy, err := NewCassandraPublisher().GetConfigPolicy()
+		So(err, ShouldBeNil)
+
+		config := make(map[string]ctypes.ConfigValue)
+		config[serverAddrRuleKey] = ctypes.ConfigValueStr{Value: "127.0.0.1:1"}
+		config[connectionTimeoutRuleKey] = ctypes.ConfigValueInt{Value: 1}
+		_, errs := configPolicy.Get([]string{""}).Process(config)
+		So(errs.HasErrors(), ShouldBeFalse)
+
+		var buf bytes.Buffer
+		metrics := []plugin.MetricType{
+			*plugin.NewMetricType(core.NewNamespace("foo"), time.Now(), nil, "", 1.0),
+		}
+		So(gob.NewEncoder(&buf).Encode(metrics), ShouldBeNil)
+
+		Convey("So publishing should return an error and retry on the next publish", func() {
+			pub := NewCassandraPublisher()
+			So(pub.Publish(plugin.SnapGOBContentType, buf.Bytes(), config), ShouldNotBeNil)
+			So(pub.Publish(plugin.SnapGOBContentType, buf.Bytes(), config), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestConfigKey(t *testing.T) {
+	Convey("Identify publisher configs", t, func() {
+		a := map[string]ctypes.ConfigValue{
+			serverAddrRuleKey:   ctypes.ConfigValueStr{Value: "10.0.0.1"},
+			keyspaceNameRuleKey: ctypes.ConfigValueStr{Value: "snap"},
+		}
+		b := map[string]ctypes.ConfigValue{
+			keyspaceNameRuleKey: ctypes.ConfigValueStr{Value: "snap"},
+			serverAddrRuleKey:   ctypes.ConfigValueStr{Value: "10.0.0.1"},
+		}
+		So(configKey(a), ShouldEqual, configKey(b))
+
+		b[keyspaceNameRuleKey] = ctypes.ConfigValueStr{Value: "other"}
+		So(configKey(a), ShouldNotEqual, configKey(b))
+	})
+}
+
+func TestSessionKey(t *testing.T) {
+	Convey("Identify shared sessions", t, func() {
+		co := clientOptions{server: "10.0.0.1", keyspace: "snap"}
+		other := co
+		other.keyspace = "other"
+		So(sessionKey(other), ShouldEqual, sessionKey(co))
+
+		other.server = "10.0.0.2"
+		So(sessionKey(other), ShouldNotEqual, sessionKey(co))
+
+		other = co
+		other.ssl = &sslOptions{username: "user"}
+		So(sessionKey(other), ShouldNotEqual, sessionKey(co))
+	})
+}
+
+func TestTTL(t *testing.T) {
+	Convey("Prepare client options with row TTLs", t, func() {
+		configPolicy, err := NewCassandraPublisher().GetConfigPolicy()
+		So(err, ShouldBeNil)
+
+		testConfig := make(map[string]ctypes.ConfigValue)
+		testConfig[serverAddrRuleKey] = ctypes.ConfigValueStr{Value: serverAddress}
+		_, errs := configPolicy.Get([]string{""}).Process(testConfig)
+		So(errs.HasErrors(), ShouldBeFalse)
+
+		Convey("So rows should not expire by default", func() {
+			co := prepareClientOptions(testConfig)
+			So(co.ttl, ShouldEqual, 0)
+			So(co.tagsTTL, ShouldEqual, 0)
+		})
+		Convey("So the tags table should use the TTL of the metrics table unless set", func() {
+			testConfig[ttlRuleKey] = ctypes.ConfigValueInt{Value: 3600}
+			So(prepareClientOptions(testConfig).tagsTTL, ShouldEqual, 3600)
+
+			testConfig[tagsTTLRuleKey] = ctypes.ConfigValueInt{Value: 60}
+			co := prepareClientOptions(testConfig)
+			So(co.ttl, ShouldEqual, 3600)
+			So(co.tagsTTL, ShouldEqual, 60)
+		})
+	})
+}
+
+func TestDriverOptions(t *testing.T) {
+	Convey("Prepare client options with advanced driver settings", t, func() {
+		configPolicy, err := NewCassandraPublisher().GetConfigPolicy()
+		So(err, ShouldBeNil)
+
+		testConfig := make(map[string]ctypes.ConfigValue)
+		testConfig[serverAddrRuleKey] = ctypes.ConfigValueStr{Value: serverAddress}
+		testConfig[disableSkipMetadataRuleKey] = ctypes.ConfigValueBool{Value: true}
+		testConfig[pageSizeRuleKey] = ctypes.ConfigValueInt{Value: 100}
+		_, errs := configPolicy.Get([]string{""}).Process(testConfig)
+		So(errs.HasErrors(), ShouldBeFalse)
+
+		cluster := createCluster(prepareClientOptions(testConfig))
+		So(cluster.DisableSkipMetadata, ShouldBeTrue)
+		So(cluster.PageSize, ShouldEqual, 100)
+		So(cluster.MaxRoutingKeyInfo, ShouldEqual, 1000)
+	})
+}
+
+func TestCompression(t *testing.T) {
+	Convey("Prepare client options with compression", t, func() {
+		configPolicy, err := NewCassandraPublisher().GetConfigPolicy()
+		So(err, ShouldBeNil)
+
+		testConfig := make(map[string]ctypes.ConfigValue)
+		testConfig[serverAddrRuleKey] = ctypes.ConfigValueStr{Value: serverAddress}
+		_, errs := configPolicy.Get([]string{""}).Process(testConfig)
+		So(errs.HasErrors(), ShouldBeFalse)
+
+		Convey("So traffic should not be compressed by default", func() {
+			co := prepareClientOptions(testConfig)
+			So(co.compression, ShouldEqual, compressionNone)
+			So(createCluster(co).Compressor, ShouldBeNil)
+		})
+		Convey("So snappy should set the compressor of the cluster", func() {
+			testConfig[compressionRuleKey] = ctypes.ConfigValueStr{Value: "snappy"}
+			co := prepareClientOptions(testConfig)
+			So(createCluster(co).Compressor, ShouldHaveSameTypeAs, gocql.SnappyCompressor{})
+
+			none := co
+			none.compression = compressionNone
+			So(sessionKey(co), ShouldNotEqual, sessionKey(none))
+		})
+		Convey("So unknown compressions should fall back to none", func() {
+			testConfig[compressionRuleKey] = ctypes.ConfigValueStr{Value: "lz4"}
+			So(prepareClientOptions(testConfig).compression, ShouldEqual, compressionNone)
+		})
+	})
+}
+
+func TestTimeColumn(t *testing.T) {
+	Convey("Prepare client options with a time column", t, func() {
+		configPolicy, err := NewCassandraPublisher().GetConfigPolicy()
+		So(err, ShouldBeNil)
+
+		testConfig := make(map[string]ctypes.ConfigValue)
+		testConfig[serverAddrRuleKey] = ctypes.ConfigValueStr{Value: serverAddress}
+		_, errs := configPolicy.Get([]string{""}).Process(testConfig)
+		So(errs.HasErrors(), ShouldBeFalse)
+
+		Convey("So the time column should be a timestamp by default", func() {
+			co := prepareClientOptions(testConfig)
+			So(co.timeColumn, ShouldEqual, "time")
+			So(co.timeColumnType, ShouldEqual, timeColumnTimestamp)
+		})
+		Convey("So a timeuuid column should get unique values from the metric time", func() {
+			testConfig[timeColumnRuleKey] = ctypes.ConfigValueStr{Value: "ts"}
+			testConfig[timeColumnTypeRuleKey] = ctypes.ConfigValueStr{Value: "timeuuid"}
+			co := prepareClientOptions(testConfig)
+			So(metricsTableCQL(co), ShouldContainSubstring, "ts timeuuid")
+
+			cc := &cassaClient{timeColumn: co.timeColumn, timeUUID: true}
+			now := time.Now()
+			p, err := newPoint(*plugin.NewMetricType(core.NewNamespace("foo"), now, nil, "", 1))
+			So(err, ShouldBeNil)
+			first, second := cc.timeValue(p).(gocql.UUID), cc.timeValue(p).(gocql.UUID)
+			So(first, ShouldNotEqual, second)
+			So(first.Time().UnixNano()/1e6, ShouldEqual, now.UnixNano()/1e6)
+		})
+		Convey("So unknown types should fall back to timestamp", func() {
+			testConfig[timeColumnTypeRuleKey] = ctypes.ConfigValueStr{Value: "date"}
+			So(prepareClientOptions(testConfig).timeColumnType, ShouldEqual, timeColumnTimestamp)
+		})
+	})
+}
@@ -0,0 +1,64 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+)
+
+// checksum returns the hex encoded SHA-256 checksum of a metric written into
+// the checksum column. It covers the namespace, the timestamp in milliseconds,
+// which is the precision of the time column, the value and the tags written
+// sorted by key, separated by NUL bytes, so auditors can recompute it from a row.
+func checksum(p *point, tags map[string]string) string {
+	h := sha256.New()
+	write := func(s string) {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+
+	write(p.ns)
+	write(strconv.FormatInt(p.m.Timestamp().UnixNano()/1e6, 10))
+	switch v := p.value.(type) {
+	case float64:
+		write(strconv.FormatFloat(v, 'g', -1, 64))
+	case int64:
+		write(strconv.FormatInt(v, 10))
+	case uint64:
+		write(strconv.FormatUint(v, 10))
+	case bool:
+		write(strconv.FormatBool(v))
+	case string:
+		write(v)
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		write(k + "=" + tags[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,63 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestChecksum(t *testing.T) {
+	Convey("Compute the checksum of a metric", t, func() {
+		ts := time.Unix(1459220692, 123456789)
+		m := *plugin.NewMetricType(core.NewNamespace("foo", "bar"), ts, map[string]string{"b": "2", "a": "1"}, "", 1.5)
+		p, err := newPoint(m)
+		So(err, ShouldBeNil)
+
+		Convey("So it should be the documented SHA-256 of the fields", func() {
+			sum := sha256.Sum256([]byte("/foo/bar\x001459220692123\x001.5\x00a=1\x00b=2\x00"))
+			So(checksum(p, m.Tags()), ShouldEqual, hex.EncodeToString(sum[:]))
+		})
+		Convey("So it should change with the value", func() {
+			m.Data_ = 2
+			q, err := newPoint(m)
+			So(err, ShouldBeNil)
+			So(checksum(q, m.Tags()), ShouldNotEqual, checksum(p, m.Tags()))
+		})
+		Convey("So it should ignore the precision lost in the time column", func() {
+			m.Timestamp_ = ts.Add(time.Microsecond)
+			q, err := newPoint(m)
+			So(err, ShouldBeNil)
+			So(checksum(q, m.Tags()), ShouldEqual, checksum(p, m.Tags()))
+		})
+		Convey("So it should only cover the tags kept by the tag filter", func() {
+			tags := newTagFilter("", "b").apply(m.Tags())
+			sum := sha256.Sum256([]byte("/foo/bar\x001459220692123\x001.5\x00a=1\x00"))
+			So(checksum(p, tags), ShouldEqual, hex.EncodeToString(sum[:]))
+		})
+	})
+}
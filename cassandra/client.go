@@ -20,8 +20,12 @@ limitations under the License.
 package cassandra
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -35,25 +39,260 @@ import (
 var (
 	cassaLog           = log.WithField("_module", "snap-cassandra-clinet")
 	ErrInvalidDataType = errors.New("Invalid data type value found - %v")
+	ErrReadOnly        = errors.New("Cassandra client is in read-only mode, writes are not allowed")
+	ErrSchemaPending   = errors.New("Cassandra client schema is not created yet")
 
-	createKeyspaceCQL = "CREATE KEYSPACE IF NOT EXISTS %s WITH REPLICATION = {'class': 'SimpleStrategy', 'replication_factor': 1};"
-	createTableCQL    = "CREATE TABLE IF NOT EXISTS %s.%s (ns  text, ver int, host text, time timestamp, valType text, doubleVal double, strVal text, boolVal boolean, tags map<text,text>, PRIMARY KEY ((ns, ver, host), time)) WITH CLUSTERING ORDER BY (time DESC);"
+	createKeyspaceCQL = "CREATE KEYSPACE IF NOT EXISTS %s WITH REPLICATION = %s;"
+	createTableCQL    = "CREATE TABLE IF NOT EXISTS %[1]s.%[2]s (ns  text, ver int, host text, %[3]s %[4]s, valType text, doubleVal double, strVal text, boolVal boolean, tags map<text,text>, %[5]sPRIMARY KEY ((%[6]s), %[3]s)) WITH CLUSTERING ORDER BY (%[3]s DESC);"
 	createTagTableCQL = "CREATE TABLE IF NOT EXISTS %s.tags (key  text, val text, time timestamp, ns text, ver int, host text, valType text, doubleVal double, strVal text, boolVal boolean, tags map<text,text>, PRIMARY KEY ((key, val), time, ns, ver, host)) WITH CLUSTERING ORDER BY (time DESC);"
-	insertMetricsCQL  = `INSERT INTO %s.%s (ns, ver, host, time, valtype, %s, tags) VALUES (?, ?, ?, ? ,?, ?, ?)`
-	insertTagsCQL     = `INSERT INTO %s.tags (key, val, time, ns, ver, host, valtype, %s, tags) VALUES (?, ?, ?, ? ,?, ?, ?, ?, ?)`
+	addColumnCQL      = "ALTER TABLE %s.%s ADD %s;"
+	insertCQLTemplate = `INSERT INTO %s.%s (%s) VALUES (%s)`
+	insertTTLCQL      = ` USING TTL ?`
+
+	insertTimestampCQL    = ` USING TIMESTAMP ?`
+	insertAndTimestampCQL = ` AND TIMESTAMP ?`
+
+	// valTypeNames maps value columns onto the user-friendly valType values
+	valTypeNames = map[string]string{
+		"doubleVal": "double",
+		"int64Val":  "int64",
+		"varintVal": "varint",
+		"strVal":    "string",
+		"boolVal":   "bool",
+	}
 )
 
-// NewCassaClient creates a new instance of a cassandra client.
-func NewCassaClient(co clientOptions, tagIndex string) *cassaClient {
-	return &cassaClient{session: getInstance(co), keyspace: co.keyspace, tableName: co.tableName, tagsIndex: tagIndex}
+// Modes controlling what is written into the valType column.
+const (
+	// valTypeColumn stores the name of the column holding the value, e.g. doubleVal
+	valTypeColumn = "column"
+	// valTypeName stores the name of the value type, e.g. double
+	valTypeName = "name"
+	// valTypeNone does not write the valType column at all
+	valTypeNone = "none"
+)
+
+// Types of the time column of the metrics table.
+const (
+	timeColumnTimestamp = "timestamp"
+	// timeColumnTimeUUID keeps samples of the same millisecond apart
+	timeColumnTimeUUID = "timeuuid"
+)
+
+// Compressions of the traffic to the cluster.
+const (
+	compressionNone   = "none"
+	compressionSnappy = "snappy"
+)
+
+// NewCassaClient creates a new instance of a cassandra client indexing the
+// tags of tagIndex, configured by the options applied to the defaults.
+func NewCassaClient(tagIndex string, opts ...ClientOption) (*cassaClient, error) {
+	co, err := newClientOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	session, err := getSharedSession(co)
+	if err != nil {
+		return nil, err
+	}
+	return newCassaClient(session, co, tagIndex), nil
+}
+
+func newCassaClient(session *gocql.Session, co clientOptions, tagIndex string) *cassaClient {
+	cc := &cassaClient{
+		session:           session,
+		options:           co,
+		health:            newHealthCheck(co.healthCheckInterval),
+		keyspace:          co.keyspace,
+		tagsKeyspace:      co.tagsKeyspace,
+		ttl:               co.ttl,
+		tagsTTL:           co.tagsTTL,
+		tagFilter:         newTagFilter(co.tagsInclude, co.tagsExclude),
+		lints:             newPublishLint(co.ttl, tagIndex),
+		probe:             newWriteProbe(co.writeProbe && !co.metadataOnly),
+		staticTags:        co.staticTags,
+		nsRewrites:        co.nsRewrites,
+		tableName:         co.tableName,
+		names:             newCQLNames(co),
+		timeColumn:        co.timeColumn,
+		timeUUID:          co.timeColumnType == timeColumnTimeUUID,
+		writeTimestamp:    co.writeTimestamp,
+		insertTemplate:    co.insertTemplate,
+		tagsIndex:         tagIndex,
+		valTypeMode:       co.valTypeMode,
+		versionTag:        co.versionTag,
+		checksum:          co.checksum,
+		ingestTime:        co.ingestTime,
+		partitionBucket:   co.partitionBucket,
+		maxMetricAge:      co.maxMetricAge,
+		outOfOrderFlag:    co.outOfOrder == outOfOrderFlag,
+		int64Val:          co.int64Val,
+		varintVal:         co.varintVal,
+		doublePrecision:   co.doublePrecision,
+		nsColumnCount:     co.nsColumns,
+		staticColumns:     co.staticColumns,
+		hostTags:          co.hostTags,
+		statics:           newStaticTracker(),
+		staticStmt:        staticCQL(co),
+		tagBatchSize:      co.tagBatchSize,
+		tagRowsWithMetric: co.tagRowsWithMetric,
+		batchSize:         co.batchSize,
+		batchByPartition:  co.batchByPartition,
+		inFlight:          newInFlightLimit(co.maxInFlight),
+		writeLimit:        newRateLimit(co.maxWritesPerSecond),
+		adaptive:          newAdaptiveBatching(co.adaptiveBatching, co.batchSize, co.writeConcurrency),
+		consistencyRoutes: co.consistencyRoutes,
+		consistencyCache:  newRouteCache(co.routeCacheSize),
+		concurrency:       co.writeConcurrency,
+		retry:             co.retry,
+		idle:              newIdleTracker(co.idleValidation),
+		statements:        newStatementCache(),
+		readOnly:          co.readOnly,
+		sharedTagSets:     co.sharedTagSets && !co.metadataOnly,
+		metadataOnly:      co.metadataOnly,
+		tagsTableDisabled: !co.tagsTableEnabled,
+		tagSets:           newTagSetCache(co.tagSetCacheSize, co.tagSetInterval),
+		boolTransitions:   co.boolTransitions,
+		transitions:       newTransitionTracker(),
+		transitionStmt:    fmt.Sprintf(insertTransitionCQL, cqlIdentifier(co.keyspace, co.preserveCase)),
+		drops:             newDropCounters(),
+		schema:            newSchemaState(co.schemaBufferSize),
+	}
+	if co.counterResets != "" {
+		cc.resets = newResetTracker(co.counterNamespaces)
+		cc.resetFlag = co.counterResets == counterResetFlag
+		cc.resetMarkers = co.counterResets == counterResetMarker
+	}
+	if co.outOfOrder != "" {
+		cc.order = newOrderTracker()
+	}
+	if co.coalesceDelay > 0 {
+		cc.coalescer = newCoalescer(co.coalesceDelay, co.coalesceMaxMetrics, func(mts []plugin.MetricType) error {
+			return cc.saveMetrics(mts, nil)
+		})
+	}
+	if co.selfStatsInterval > 0 && !co.readOnly {
+		cc.stats = newPublisherStats()
+		cc.retry.stats = cc.stats
+		go cc.reportStats(co.selfStatsInterval, co.selfStatsTable, co.selfStatsFile)
+	}
+
+	// read-only clients never touch the schema, nor clients of a bootstrapped one
+	if co.readOnly || co.skipSchema {
+		cc.schema.setReady()
+	} else {
+		cc.setupSchema(co)
+	}
+
+	if co.spoolPath != "" && !co.readOnly {
+		sp, err := openSpool(spoolDir(co.spoolPath, co), co.spoolMaxSize, co.spoolSegmentSize)
+		if err != nil {
+			cassaLog.WithFields(log.Fields{
+				"err": err,
+			}).Error("Cassandra client spool creation error, failed metrics are not spooled")
+		} else {
+			cc.spool = sp
+			go cc.replaySpool()
+		}
+	}
+	return cc
 }
 
 // cassaClient contains a long running Cassandra CQL session
 type cassaClient struct {
+	// session is replaced when it is rebuilt, use currentSession
 	session   *gocql.Session
-	tagsIndex string
-	keyspace  string
-	tableName string
+	sessionMu sync.RWMutex
+	// options are the options the session is rebuilt with
+	options clientOptions
+	// health probes the session before publishing, nil if disabled
+	health       *healthCheck
+	tagsIndex    string
+	keyspace     string
+	tagsKeyspace string
+	tableName    string
+	// names are the keyspace and table names as written into statements
+	names           cqlNames
+	timeColumn      string
+	timeUUID        bool
+	writeTimestamp  bool
+	insertTemplate  *insertTemplate
+	ttl             int
+	tagsTTL         int
+	valTypeMode     string
+	versionTag      string
+	checksum        bool
+	ingestTime      bool
+	partitionBucket time.Duration
+	maxMetricAge    time.Duration
+	order           *orderTracker
+	outOfOrderFlag  bool
+	// resets detects counter resets, nil if disabled, resetFlag marks the
+	// rows after a reset and resetMarkers writes a marker row before them
+	resets       *resetTracker
+	resetFlag    bool
+	resetMarkers bool
+	// stats are the self-metrics of the client, nil if not reported
+	stats *publisherStats
+	// coalescer merges concurrent publishes, nil if writes are not coalesced
+	coalescer *coalescer
+	int64Val  bool
+	varintVal bool
+	// doublePrecision is the number of decimal places doubles are rounded to, -1 keeps them
+	doublePrecision int
+	staticColumns   bool
+	hostTags        []string
+	statics         *staticTracker
+	staticStmt      string
+	batchSize       int
+	// nsColumnCount is the number of namespace elements written into columns of their own
+	nsColumnCount int
+	// batchByPartition writes one batch per partition instead of batches spanning partitions
+	batchByPartition bool
+	// inFlight bounds the inserts executing at once
+	inFlight inFlightLimit
+	// writeLimit bounds the rows written per second
+	writeLimit *rateLimit
+	// adaptive adjusts the batch size and concurrency, nil if they are fixed
+	adaptive *adaptiveBatching
+	// consistencyRoutes override the consistency of the metrics rows of namespaces
+	consistencyRoutes []route
+	consistencyCache  *routeCache
+	tagBatchSize      int
+	tagRowsWithMetric bool
+	// tagFilter selects the tags written into the tags columns, nil keeps all
+	tagFilter *tagFilter
+	// lints check the settings against the first publishes
+	lints *publishLint
+	// probe verifies the writes before the first metrics are written, nil if disabled
+	probe *writeProbe
+	// staticTags are added to the tags of every metric
+	staticTags map[string]string
+	// nsRewrites transform the namespaces written into the ns column
+	nsRewrites  nsRewrites
+	concurrency int
+	retry       retryPolicy
+	spool       *spool
+	idle        *idleTracker
+	statements  *statementCache
+	readOnly    bool
+	// metadataOnly writes only the tags table rows
+	metadataOnly    bool
+	sharedTagSets   bool
+	tagSets         *tagSetCache
+	boolTransitions bool
+	transitions     *transitionTracker
+	transitionStmt  string
+	drops           *dropCounters
+	schema          *schemaState
+
+	// tagsTableDisabled skips the writes into the tags table
+	tagsTableDisabled bool
+
+	// query executes the statements of per-metric writes instead of the
+	// session if set, replaced in tests
+	query func(stmt string, values []interface{}) error
 }
 
 type clientOptions struct {
@@ -62,183 +301,788 @@ type clientOptions struct {
 
 	timeout           time.Duration
 	connectionTimeout time.Duration
+	consistency       gocql.Consistency
 	initialHostLookup bool
 	ignorePeerAddr    bool
+	// idleValidation is the idle period after which a connection is validated
+	idleValidation time.Duration
+	// healthCheckInterval is the interval of probing the session before
+	// publishing and rebuilding it if unhealthy, 0 if disabled
+	healthCheckInterval time.Duration
+	// disabledEvents are the cluster events not registered for, the state of
+	// down hosts is then only polled every reconnectInterval
+	disabledEvents    disabledEvents
+	reconnectInterval time.Duration
+	// poolStatsInterval is the interval of logging pool stats, 0 if disabled
+	poolStatsInterval time.Duration
+	// compression is the compression of the traffic to the cluster
+	compression string
+	// localDC is the data center whose hosts are preferred
+	localDC string
+	// tokenAware routes queries to a replica of their partition
+	tokenAware bool
+	// driver are advanced gocql settings
+	driver driverOptions
+	// batchSize is the maximum number of inserts sent in one unlogged batch
+	batchSize int
+	// adaptiveBatching is the write latency the batch size and the write
+	// concurrency are adjusted to, up to their configured values
+	adaptiveBatching time.Duration
+	// batchByPartition groups the inserts of a batch into one batch per partition
+	batchByPartition bool
+	// maxInFlight is the maximum number of inserts executing at once, 0 is unbounded
+	maxInFlight int
+	// maxWritesPerSecond is the maximum number of rows written per second, 0 is unbounded
+	maxWritesPerSecond int
+	// consistencyRoutes map namespace prefixes onto the consistency their
+	// metrics rows are written at, routeCacheSize bounds the cached matches
+	consistencyRoutes []route
+	routeCacheSize    int
+	// tagBatchSize is the maximum number of tag rows of a partition sent in
+	// one unlogged batch, 0 writes tag rows with their metric
+	tagBatchSize int
+	// tagRowsWithMetric sends the tag rows of a metric in one unlogged batch
+	// with its metrics row, instead of a query each
+	tagRowsWithMetric bool
+	// staticTags are added to the tags of every metric not carrying them
+	staticTags map[string]string
+	// nsRewrites transform the namespaces written into the ns column
+	nsRewrites nsRewrites
+	// tagsInclude and tagsExclude select the tags written into the tags columns
+	tagsInclude string
+	tagsExclude string
+	// writeConcurrency is the number of workers writing the metrics of a publish
+	writeConcurrency int
+	// retry is the policy of retrying failed inserts
+	retry retryPolicy
+	// spoolPath is the directory metrics failing to be written are spooled to
+	spoolPath string
+	// spoolMaxSize is the maximum size of the spool in bytes
+	spoolMaxSize int64
+	// spoolSegmentSize is the size in bytes from which a segment of the spool is rotated
+	spoolSegmentSize int64
 
 	createKeyspace bool
+	replication    replicationOptions
 	keyspace       string
 	tableName      string
+	// preserveCase quotes keyspace and table names, so their case is kept
+	preserveCase      bool
+	schemaAgreement   time.Duration
+	schemaConcurrency int
+	schemaBufferSize  int
+	valTypeMode       string
+	// tagsKeyspace is the keyspace of the tags table
+	tagsKeyspace string
+	// ttl and tagsTTL are the seconds after which rows of the metrics and the tags table expire
+	ttl     int
+	tagsTTL int
+	// compaction configures the compaction of the time series tables
+	compaction compactionOptions
+	// tableCompaction overrides compaction for the metrics table, nil if not set
+	tableCompaction *compactionOptions
+	// versionTag is the tag whose value is written into the appVer column
+	versionTag string
+	// timeColumn is the name and timeColumnType the type of the time column of the metrics table
+	timeColumn     string
+	timeColumnType string
+	// writeTimestamp sets the write timestamp of the metrics rows to the metric timestamps
+	writeTimestamp bool
+	// tableTemplate and insertTemplate replace the built-in layout of the metrics table
+	tableTemplate  string
+	insertTemplate *insertTemplate
+	// checksum writes a checksum of every metric into the checksum column
+	checksum bool
+	// coalesceDelay is how long metrics wait to be merged with the ones of
+	// concurrent publishes, 0 if writes are not coalesced
+	coalesceDelay time.Duration
+	// coalesceMaxMetrics is the number of pending metrics written without waiting longer
+	coalesceMaxMetrics int
+	// ingestTime writes the time the coordinator received a metric into the ingestTime column
+	ingestTime bool
+	// partitionBucket is the time span of the partitions of a series, 0 keeps one partition per series
+	partitionBucket time.Duration
+	// maxMetricAge is the age beyond which metrics are dropped, 0 writes all metrics
+	maxMetricAge time.Duration
+	// outOfOrder reports samples older than the latest one of their series
+	// and, in flag mode, marks their rows
+	outOfOrder string
+	// counterResets detects resets of the counters of counterNamespaces and,
+	// in flag mode, marks their rows or, in marker mode, writes marker rows
+	counterResets     string
+	counterNamespaces string
+	// int64Val writes integers into the int64Val column instead of doubleVal
+	int64Val bool
+	// varintVal writes unsigned 64 bit integers into the varintVal column
+	varintVal bool
+	// doublePrecision is the number of decimal places doubles are rounded to, -1 keeps them
+	doublePrecision int
+	// skipSchema leaves the schema to the bootstrap, the client neither
+	// creates nor alters it
+	skipSchema bool
+	// createHostView creates a materialized view of the metrics table keyed
+	// by host and time
+	createHostView bool
+	// createTagMapIndex creates a secondary index on the entries of the tags
+	// map of the metrics table
+	createTagMapIndex bool
+	// writeProbe writes and deletes a probe row before the first metrics
+	writeProbe bool
+	// strValIndex is the mode of a SASI index on the strVal column of the
+	// metrics table, empty for none
+	strValIndex string
+	// nsColumns writes the first nsColumns namespace elements into the ns0..
+	// columns and the remaining ones into the nsRest column of the metrics table
+	nsColumns int
+	// staticColumns writes the unit and the hostTags once per partition into static columns
+	staticColumns bool
+	hostTags      []string
+
+	// readOnly disables all DDL and writes, for tools reading data back
+	readOnly bool
+	// metadataOnly only maintains the tags table, no samples are written
+	metadataOnly bool
+	// tagsTableEnabled is unset to neither create nor write the tags table
+	tagsTableEnabled bool
+	// sharedTagSets writes the tags common to a publish once into the tagsets table
+	sharedTagSets bool
+	// tagSetCacheSize is the maximum number of tag sets remembered as written
+	tagSetCacheSize int
+	// tagSetInterval is the time after which a tag set is written again, 0 if never
+	tagSetInterval time.Duration
+	// boolTransitions records state changes of boolean metrics in the transitions table
+	boolTransitions bool
+	// buildInfo records the build metadata of the publisher in the builds table
+	buildInfo bool
+	// selfStatsInterval is the interval of reporting the stats of the
+	// publisher, 0 if disabled, selfStatsTable also writes them into a table
+	// and selfStatsFile into an OpenMetrics file
+	selfStatsInterval time.Duration
+	selfStatsTable    bool
+	selfStatsFile     string
+	// started is the time the publisher was configured
+	started time.Time
 
 	ssl *sslOptions
+	// sigv4 authenticates against Amazon Keyspaces, nil for other clusters
+	sigv4 *sigv4Options
+	// clusterHook changes the cluster config before sessions are created, nil if not set
+	clusterHook func(*gocql.ClusterConfig)
+}
+
+// driverOptions contains lesser-used gocql settings for tuning the driver
+type driverOptions struct {
+	disableSkipMetadata bool
+	maxRoutingKeyInfo   int
+	pageSize            int
 }
 
 // sslOptions contains configuration for encrypted communication between the app and the server
 type sslOptions struct {
 	username                     string
 	password                     string
+	authorizationID              string
 	keyPath                      string
 	certPath                     string
 	caPath                       string
 	enableServerCertVerification bool
 }
 
-var instance *gocql.Session
-var once sync.Once
+var sessions = map[string]*gocql.Session{}
+var sessionsMu sync.Mutex
 
-// getInstance returns the singleton of *gocql.Session. It is configured with ssl options if any are given.
-// the session is not closed if the publisher is running.
-func getInstance(co clientOptions) *gocql.Session {
-	once.Do(func() {
-		instance = getSession(co)
-	})
-	return instance
+// getSharedSession returns the *gocql.Session for the cluster options, shared by all
+// clients with the same options. It is configured with ssl options if any are given.
+// the session is not closed if the publisher is running. If the session cannot be created,
+// the creation is tried again on the next call.
+func getSharedSession(co clientOptions) (*gocql.Session, error) {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	key := sessionKey(co)
+	// hooks cannot be compared, closures of one function literal differ
+	// only in their captured values, so sessions of a hook are not shared
+	if co.clusterHook == nil {
+		if session, ok := sessions[key]; ok {
+			sessionRefs[session]++
+			return session, nil
+		}
+	}
+	session, err := getSession(co)
+	if err != nil {
+		return nil, err
+	}
+	if co.clusterHook != nil {
+		key = fmt.Sprintf("%s|%p", key, session)
+	}
+	sessions[key] = session
+	sessionRefs[session]++
+	return session, nil
+}
+
+// closeSessions closes all shared sessions.
+func closeSessions() {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	for key, session := range sessions {
+		session.Close()
+		delete(sessions, key)
+	}
+	sessionRefs = map[*gocql.Session]int{}
+	replacedSessions = map[*gocql.Session]*gocql.Session{}
+}
+
+// sessionKey returns a hash of the options createCluster configures a
+// session with, but the cluster hook.
+func sessionKey(co clientOptions) string {
+	ssl := sslOptions{}
+	if co.ssl != nil {
+		ssl = *co.ssl
+	}
+	sigv4 := sigv4Options{}
+	if co.sigv4 != nil {
+		sigv4 = *co.sigv4
+	}
+	h := sha1.New()
+	fmt.Fprintf(h, "%s|%d|%v|%v|%v|%v|%v|%v|%v|%+v|%v|%v|%s|%s|%v|%+v|%+v|%+v",
+		co.server, co.port, co.timeout, co.connectionTimeout, co.consistency,
+		co.initialHostLookup, co.ignorePeerAddr, co.schemaAgreement,
+		co.idleValidation, co.disabledEvents, co.reconnectInterval,
+		co.poolStatsInterval, co.compression, co.localDC, co.tokenAware, co.driver, ssl, sigv4)
+	return hex.EncodeToString(h.Sum(nil))
 }
 
-func (cc *cassaClient) saveMetrics(mts []plugin.MetricType) error {
+// saveMetrics writes the metrics, adding the latency of their writes to
+// timings if it is not nil.
+func (cc *cassaClient) saveMetrics(mts []plugin.MetricType, timings *publishTimings) error {
+	if cc.readOnly {
+		return ErrReadOnly
+	}
+
+	mts = cc.addStaticTags(mts)
+	cc.lint(mts)
+
+	// metrics are buffered until the schema is created
+	mts, pending, evicted := cc.schema.admit(mts, cc.drops)
+	if pending {
+		buffered, err := cc.schema.status()
+		cassaLog.WithFields(log.Fields{
+			"status":   "schema pending",
+			"buffered": buffered,
+			"err":      err,
+		}).Warn("Cassandra client is waiting for the schema to be created")
+		if evicted > 0 {
+			return &OverloadedError{Queue: dropSchemaPending, Dropped: evicted}
+		}
+		return nil
+	}
+
+	cc.checkHealth()
+	cc.validateIdleConnections()
+	if err := cc.probeWrites(); err != nil {
+		return err
+	}
+
 	errs := []string{}
-	var err error
-	for _, m := range mts {
-		// insert data into metrics table
-		err = worker(cc.session, cc.keyspace, cc.tableName, m)
-		if err != nil {
-			errs = append(errs, err.Error())
+	var ts *tagSet
+	if cc.sharedTagSets {
+		var err error
+		if ts, err = cc.writeTagSet(mts); err != nil {
+			// metrics are still written, with their full tags
+			cassaLog.WithFields(log.Fields{
+				"err": err,
+			}).Error("Cassandra client tag set insertion error")
 		}
+	}
 
-		// inserts data into tags table if tagIndex config exists
-		vtags := getValidTagIndex(m.Tags(), cc.tagsIndex)
-		err = tagWorker(cc.session, cc.keyspace, m, vtags)
-		if err != nil {
-			errs = append(errs, err.Error())
+	late := cc.order.total()
+	start := time.Now()
+	res := cc.writeConcurrently(mts, ts, timings)
+	cc.stats.publish(len(mts)-len(res.failed)-res.dropped, len(res.failed), time.Since(start))
+	if late = cc.order.total() - late; late > 0 {
+		cassaLog.WithFields(log.Fields{
+			"outOfOrder": late,
+			"total":      cc.order.total(),
+		}).Warn("Cassandra client received metrics older than the latest ones of their series, check the clocks of the collectors")
+	}
+	errs = append(errs, res.errs...)
+	// failed metrics are not reported once they are spooled for a replay
+	var overloaded *OverloadedError
+	if len(res.failed) > 0 && (cc.spool == nil || cc.spoolMetrics(res.failed) != nil) {
+		errs = append(errs, res.insertErrs...)
+		if cc.spool != nil {
+			overloaded = &OverloadedError{Queue: dropSpoolFull, Dropped: len(res.failed)}
 		}
 	}
+	if res.dropped > 0 {
+		cassaLog.WithFields(log.Fields{
+			"dropped": res.dropped,
+			"totals":  cc.drops.snapshot(),
+		}).Warn("Cassandra client dropped metrics")
+	}
+	if overloaded != nil {
+		overloaded.Err = errors.New(strings.Join(errs, ";"))
+		return overloaded
+	}
 	if len(errs) > 0 {
-		err = fmt.Errorf(strings.Join(errs, ";"))
+		return errors.New(strings.Join(errs, ";"))
 	}
-	return err
+	return nil
 }
 
-func executeMetricsQuery(keyspace, tableName, insertColumn string, s *gocql.Session, m plugin.MetricType, value interface{}) error {
-	queryStr := fmt.Sprintf(insertMetricsCQL, keyspace, tableName, insertColumn)
-	query := s.Query(queryStr,
-		m.Namespace().String(),
-		m.Version(),
-		m.Tags()[core.STD_TAG_PLUGIN_RUNNING_ON],
-		m.Timestamp(),
-		insertColumn,
-		value,
-		m.Tags())
+// WriteResult reports the outcome of writing a single metric.
+type WriteResult struct {
+	Namespace string
+	Success   bool
+	Err       error
+	Latency   time.Duration
+}
 
-	if err := query.Exec(); err != nil {
-		return err
-	}
-	return nil
+// SaveMetricsWithResults writes metrics like Publish does, but reports the
+// result of every metric on the returned channel instead of a joined error,
+// so embedding applications can implement their own retry and alerting.
+// The channel is buffered for all metrics and closed once they are written.
+func (cc *cassaClient) SaveMetricsWithResults(mts []plugin.MetricType) <-chan WriteResult {
+	results := make(chan WriteResult, len(mts))
+	mts = cc.addStaticTags(mts)
+	go func() {
+		defer close(results)
+		for _, m := range mts {
+			start := time.Now()
+			var err error
+			switch {
+			case cc.readOnly:
+				err = ErrReadOnly
+			case !cc.schema.isReady():
+				err = ErrSchemaPending
+			default:
+				wb := newWriteBatch(cc.currentSession(), 1, cc.retry)
+				wb.inFlight = cc.inFlight
+				wb.limit = cc.writeLimit
+				wb.query = cc.query
+				err = cc.saveMetric(m, nil, wb)
+			}
+			results <- WriteResult{
+				Namespace: m.Namespace().String(),
+				Success:   err == nil,
+				Err:       err,
+				Latency:   time.Since(start),
+			}
+		}
+	}()
+	return results
 }
 
-func executeTagsQuery(keyspace, insertColumn, tag string, s *gocql.Session, m plugin.MetricType, value interface{}) error {
-	queryStr := fmt.Sprintf(insertTagsCQL, keyspace, insertColumn)
-	query := s.Query(queryStr,
-		tag,
-		m.Tags()[tag],
-		time.Now(),
-		m.Namespace().String(),
-		m.Version(),
-		m.Tags()[core.STD_TAG_PLUGIN_RUNNING_ON],
-		insertColumn,
-		value,
-		m.Tags())
-
-	if err := query.Exec(); err != nil {
-		return err
-	}
-	return nil
+// dropError is returned for a metric the publisher deliberately did not write.
+type dropError struct {
+	reason string
+	err    error
 }
 
-// works insert data into Cassandra DB metrics table only when the data is valid
-func worker(s *gocql.Session, keyspace, tableName string, m plugin.MetricType) error {
-	value, err := convert(m.Data())
+func (e dropError) Error() string {
+	return e.err.Error()
+}
+
+// insertError is returned for a metric which could not be inserted into the metrics table.
+type insertError struct {
+	err error
+}
+
+func (e insertError) Error() string {
+	return e.err.Error()
+}
+
+// saveMetric inserts a metric into the metrics table and, for indexed tags, into the tags table.
+// If a shared tag set is given, the metrics table row references it instead of repeating its tags.
+// Inserts are added to wb.
+func (cc *cassaClient) saveMetric(m plugin.MetricType, ts *tagSet, wb *writeBatch) error {
+	start := time.Now()
+	// metrics with unsupported data types are never written
+	p, err := newPoint(normalizeMetric(m))
 	if err != nil {
-		cassaLog.WithFields(log.Fields{
-			"err": err,
-		}).Error("Cassandra client invalid data type")
-		return err
+		cc.drops.inc(dropInvalidType)
+		return dropError{reason: dropInvalidType, err: err}
+	}
+	p.ns = cc.nsRewrites.apply(p.ns)
+	// stray old metrics would land outside of the compaction windows
+	if cc.maxMetricAge > 0 && time.Since(m.Timestamp()) > cc.maxMetricAge {
+		cc.drops.inc(dropTooOld)
+		return dropError{reason: dropTooOld, err: fmt.Errorf("metric %s of %v is older than %v", p.ns, m.Timestamp(), cc.maxMetricAge)}
+	}
+	p.outOfOrder = cc.order.outOfOrder(seriesKey(p.ns, p.m.Version(), p.host), p.m.Timestamp())
+	if cc.int64Val {
+		p.preferInt64()
 	}
+	// keeps all samples of an unsigned series in one column, whatever their magnitude
+	if cc.varintVal {
+		p.preferVarint()
+	}
+	if cc.doublePrecision >= 0 {
+		p.roundDouble(cc.doublePrecision)
+	}
+	wb.timings.addConvert(time.Since(start))
+	// only the tags table is maintained in metadata-only mode
+	if cc.metadataOnly {
+		if !cc.tagsTableDisabled {
+			cc.tagWorker(wb, p, getValidTagIndex(p.m.Tags(), cc.tagsIndex))
+		}
+		return nil
+	}
+	p.counterReset = cc.resets.reset(p)
 
-	switch value.(type) {
-	case float64:
-		err := executeMetricsQuery(keyspace, tableName, "doubleVal", s, m, value)
-		if err != nil {
-			cassaLog.WithFields(log.Fields{
-				"err": err,
-			}).Error("Cassandra client insertion error ")
+	var errs []string
+	tags := p.m.Tags()
+	// host tags are written into the static columns instead of every row
+	if cc.staticColumns {
+		if tags, err = cc.saveStatics(wb, p, tags); err != nil {
+			errs = append(errs, err.Error())
 		}
-	case string:
-		err := executeMetricsQuery(keyspace, tableName, "strVal", s, m, value)
-		if err != nil {
-			cassaLog.WithFields(log.Fields{
-				"err": err,
-			}).Error("Cassandra client insertion error ")
+	}
+	tags = cc.tagFilter.apply(tags)
+	if ts != nil {
+		tags = ts.compact(tags)
+	}
+	// the marker precedes the sample, so rates are reconstructed across the reset
+	if p.counterReset && cc.resetMarkers {
+		if err := cc.worker(wb, resetMarker(p), tags); err != nil {
+			errs = append(errs, err.Error())
 		}
-	case bool:
-		err := executeMetricsQuery(keyspace, tableName, "boolVal", s, m, value)
-		if err != nil {
+	}
+	// the metric row and its tag rows are sent in one batch
+	if cc.tagRowsWithMetric {
+		wb.startGroup()
+	}
+	// insert data into metrics table
+	err = cc.worker(wb, p, tags)
+	_, failed := err.(insertError)
+	if err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	// inserts state changes of boolean metrics into transitions table
+	if b, ok := p.value.(bool); ok && cc.boolTransitions {
+		if err := cc.saveTransition(wb, p, b); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	// inserts data into tags table if tagIndex config exists
+	if !cc.tagsTableDisabled {
+		vtags := getValidTagIndex(p.m.Tags(), cc.tagsIndex)
+		cc.tagWorker(wb, p, vtags)
+	}
+	if cc.tagRowsWithMetric {
+		if err := wb.flushGroup(); err != nil {
 			cassaLog.WithFields(log.Fields{
 				"err": err,
 			}).Error("Cassandra client insertion error ")
+			failed = true
+			errs = append(errs, err.Error())
 		}
-	default:
-		return fmt.Errorf(ErrInvalidDataType.Error(), value)
+	}
+	if failed {
+		return insertError{errors.New(strings.Join(errs, ";"))}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ";"))
 	}
 	return nil
 }
 
-// tagWorker insert data into Cassandra DB tags only when the tags array is not empty.
-func tagWorker(s *gocql.Session, keyspace string, m plugin.MetricType, tags []string) error {
-	if len(tags) == 0 {
-		return nil
-	}
+// point holds what all inserts of a metric share. It is built once per
+// metric, so the data is converted and the namespace formatted only once.
+type point struct {
+	m    plugin.MetricType
+	ns   string
+	host string
+	// column is the column holding the value: doubleVal, int64Val, varintVal, strVal or boolVal
+	column string
+	value  interface{}
+	// outOfOrder is set when a newer sample of the series was seen before
+	outOfOrder bool
+	// counterReset is set when the value is lower than the one of the
+	// previous sample of the counter
+	counterReset bool
+}
 
+// newPoint converts the data of the metric, it fails for unsupported data types.
+func newPoint(m plugin.MetricType) (*point, error) {
 	value, err := convert(m.Data())
 	if err != nil {
-		cassaLog.WithFields(log.Fields{
-			"err": err,
-		}).Error("Cassandra client invalid data type")
-		return err
+		return nil, err
+	}
+	p := &point{
+		m:     m,
+		ns:    m.Namespace().String(),
+		host:  m.Tags()[core.STD_TAG_PLUGIN_RUNNING_ON],
+		value: value,
 	}
-
 	switch value.(type) {
 	case float64:
-		for _, v := range tags {
-			err := executeTagsQuery(keyspace, "doubleVal", v, s, m, value)
-			if err != nil {
-				cassaLog.WithFields(log.Fields{
-					"err": err,
-				}).Error("Cassandra client insertion error ")
-			}
-		}
+		p.column = "doubleVal"
 	case string:
-		for _, v := range tags {
-			err := executeTagsQuery(keyspace, "strVal", v, s, m, value)
-			if err != nil {
-				cassaLog.WithFields(log.Fields{
-					"err": err,
-				}).Error("Cassandra client insertion error ")
-			}
-		}
+		p.column = "strVal"
 	case bool:
-		for _, v := range tags {
-			err := executeTagsQuery(keyspace, "boolVal", v, s, m, value)
-			if err != nil {
-				cassaLog.WithFields(log.Fields{
-					"err": err,
-				}).Error("Cassandra client insertion error ")
-			}
+		p.column = "boolVal"
+	}
+	return p, nil
+}
+
+// column is a column of an insert statement with the value bound to it.
+type column struct {
+	name  string
+	value interface{}
+}
+
+// cqlFunc is a column value computed by Cassandra, written into the insert
+// statement instead of being bound.
+type cqlFunc string
+
+// ingestTimeCQL is the time the coordinator received an insert.
+const ingestTimeCQL cqlFunc = "toTimestamp(now())"
+
+// insertCQL returns the statement inserting the columns into keyspace.table.
+func insertCQL(keyspace, table string, cols []column) string {
+	names := make([]string, len(cols))
+	marks := make([]string, len(cols))
+	for i, c := range cols {
+		names[i] = c.name
+		marks[i] = "?"
+		if f, ok := c.value.(cqlFunc); ok {
+			marks[i] = string(f)
 		}
-	default:
-		return fmt.Errorf(ErrInvalidDataType.Error(), value)
+	}
+	return fmt.Sprintf(insertCQLTemplate, keyspace, table, strings.Join(names, ", "), strings.Join(marks, ", "))
+}
+
+// columnValues returns the values to bind to the statement inserting the columns.
+func columnValues(cols []column) []interface{} {
+	// leaves room for the TTL and the timestamp bound after the columns
+	values := make([]interface{}, 0, len(cols)+2)
+	for _, c := range cols {
+		if _, ok := c.value.(cqlFunc); !ok {
+			values = append(values, c.value)
+		}
+	}
+	return values
+}
+
+// valueColumns appends the columns shared by the metrics and the tags table
+// holding the metric data to cols.
+func (cc *cassaClient) valueColumns(cols []column, p *point) []column {
+	cols = append(cols,
+		column{"ns", p.ns},
+		column{"ver", p.m.Version()},
+		column{"host", p.host})
+	if cc.versionTag != "" {
+		cols = append(cols, column{"appVer", p.m.Tags()[cc.versionTag]})
+	}
+	if cc.valTypeMode != valTypeNone {
+		cols = append(cols, column{"valtype", valTypeValue(p.column, cc.valTypeMode)})
+	}
+	return append(cols, column{p.column, p.value})
+}
+
+func (cc *cassaClient) executeMetricsQuery(wb *writeBatch, p *point, tags map[string]string) error {
+	if cc.insertTemplate != nil {
+		return cc.executeTemplateQuery(wb, p, tags)
+	}
+	cols := append(cc.valueColumns(wb.columns(), p),
+		column{cc.timeColumn, cc.timeValue(p)},
+		column{"tags", tags})
+	if cc.checksum {
+		// tags dropped by the tag filter are not stored, so they are not covered
+		cols = append(cols, column{"checksum", checksum(p, cc.tagFilter.apply(p.m.Tags()))})
+	}
+	if cc.ingestTime {
+		cols = append(cols, column{"ingestTime", ingestTimeCQL})
+	}
+	if cc.partitionBucket > 0 {
+		cols = append(cols, column{"bucket", bucketOf(p.m.Timestamp(), cc.partitionBucket)})
+	}
+	if cc.outOfOrderFlag {
+		cols = append(cols, column{"outOfOrder", p.outOfOrder})
+	}
+	if cc.resetFlag {
+		cols = append(cols, column{"counterReset", p.counterReset})
+	}
+	cols = cc.nsColumns(cols, p)
+	key := statementKey{cc.names.keyspace, cc.names.table, p.column, cc.ttl > 0, cc.writeTimestamp}
+	queryStr, values := cc.bind(wb, key, cols, cc.ttl)
+	if key.timestamp {
+		values = append(values, writeTime(p.m.Timestamp()))
+	}
+	return cc.execMetrics(wb, p, queryStr, values...)
+}
+
+// execMetrics adds the insert of the metrics row of the point to wb, or
+// executes it on its own if its namespace is routed to a consistency.
+func (cc *cassaClient) execMetrics(wb *writeBatch, p *point, stmt string, values ...interface{}) error {
+	if consistency, ok := cc.consistencyOf(p.ns); ok {
+		return wb.execWith(consistency, stmt, values...)
+	}
+	return wb.execIn(cc.metricsPartition(wb, p), stmt, values...)
+}
+
+// metricsPartition identifies the partition of the metrics table the point
+// is written to, if the inserts of wb are grouped by partition.
+func (cc *cassaClient) metricsPartition(wb *writeBatch, p *point) string {
+	if !wb.byPartition {
+		return ""
+	}
+	partition := cc.tableName + "|" + seriesKey(p.ns, p.m.Version(), p.host)
+	if cc.partitionBucket > 0 {
+		partition += "|" + bucketOf(p.m.Timestamp(), cc.partitionBucket).String()
+	}
+	return partition
+}
+
+// writeTime returns the CQL write timestamp of t, in microseconds. Writes of
+// the same metric get the same timestamp, so re-publishes are idempotent and
+// Cassandra resolves duplicates deterministically.
+func writeTime(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Microsecond)
+}
+
+// timeValue returns the value of the time column of the metrics table.
+func (cc *cassaClient) timeValue(p *point) interface{} {
+	if cc.timeUUID {
+		// the random clock sequence keeps the samples of a millisecond unique
+		return gocql.UUIDFromTime(p.m.Timestamp())
+	}
+	return p.m.Timestamp()
+}
+
+func (cc *cassaClient) executeTagsQuery(wb *writeBatch, tag string, p *point, now time.Time) error {
+	val := p.m.Tags()[tag]
+	cols := append(wb.columns(),
+		column{"key", tag},
+		column{"val", val},
+		column{"time", now})
+	cols = append(cc.valueColumns(cols, p), column{"tags", cc.tagFilter.apply(p.m.Tags())})
+	queryStr, values := cc.bind(wb, statementKey{cc.names.tagsKeyspace, "tags", p.column, cc.tagsTTL > 0, false}, cols, cc.tagsTTL)
+	if wb.tags != nil {
+		wb.tags.add(tag, val, queryStr, values)
+		return nil
+	}
+	return wb.execIn("tags|"+tag+"|"+val, queryStr, values...)
+}
+
+// bind returns the insert statement of the columns and the values bound to it.
+// Rows of statements with a TTL expire after ttl seconds.
+func (cc *cassaClient) bind(wb *writeBatch, key statementKey, cols []column, ttl int) (string, []interface{}) {
+	queryStr := cc.statements.get(key, cols)
+	values := columnValues(cols)
+	if key.ttl {
+		values = append(values, ttl)
+	}
+	wb.release(cols)
+	return queryStr, values
+}
+
+// works insert data into Cassandra DB metrics table only when the data is valid,
+// tags are the tags stored with the metric.
+func (cc *cassaClient) worker(wb *writeBatch, p *point, tags map[string]string) error {
+	if err := cc.executeMetricsQuery(wb, p, tags); err != nil {
+		cassaLog.WithFields(log.Fields{
+			"err": err,
+		}).Error("Cassandra client insertion error ")
+		return insertError{err}
 	}
 	return nil
 }
 
+// tagWorker insert data into Cassandra DB tags only when the tags array is not empty.
+func (cc *cassaClient) tagWorker(wb *writeBatch, p *point, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+
+	now := time.Now()
+	for _, v := range tags {
+		err := cc.executeTagsQuery(wb, v, p, now)
+		if err != nil {
+			cassaLog.WithFields(log.Fields{
+				"err": err,
+			}).Error("Cassandra client insertion error ")
+		}
+	}
+}
+
+// valTypeValue returns the valType column value for data bound to insertColumn.
+func valTypeValue(insertColumn, valTypeMode string) string {
+	if valTypeMode == valTypeName {
+		return valTypeNames[insertColumn]
+	}
+	return insertColumn
+}
+
+// preferInt64 moves integer data into the int64Val column, where it keeps
+// the precision lost as double above 2^53.
+func (p *point) preferInt64() {
+	if v, ok := toInt64(p.m.Data()); ok {
+		p.column = "int64Val"
+		p.value = v
+	}
+}
+
+// preferVarint moves unsigned 64 bit integer data into the varintVal column,
+// which holds their full range.
+func (p *point) preferVarint() {
+	switch v := p.m.Data().(type) {
+	case uint64:
+		p.column = "varintVal"
+		p.value = v
+	case uint:
+		p.column = "varintVal"
+		p.value = uint64(v)
+	}
+}
+
+// roundDouble rounds a double value to the decimal places, so excess
+// precision of collectors does not defeat the compression of the column.
+// Infinities and NaN are kept.
+func (p *point) roundDouble(places int) {
+	v, ok := p.value.(float64)
+	if !ok || p.column != "doubleVal" || math.IsInf(v, 0) || math.IsNaN(v) {
+		return
+	}
+	if rounded, err := strconv.ParseFloat(strconv.FormatFloat(v, 'f', places, 64), 64); err == nil {
+		p.value = rounded
+	}
+}
+
+// toInt64 returns integer data as int64. It fails for other data and for
+// unsigned integers beyond the int64 range.
+func toInt64(i interface{}) (int64, bool) {
+	switch v := i.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint:
+		if uint64(v) <= math.MaxInt64 {
+			return int64(v), true
+		}
+	case uint64:
+		if v <= math.MaxInt64 {
+			return int64(v), true
+		}
+	}
+	return 0, false
+}
+
 // converts the value into float64 and filters out the
 // invalid data
 func convert(i interface{}) (interface{}, error) {
@@ -282,26 +1126,78 @@ func convert(i interface{}) (interface{}, error) {
 
 func createCluster(config clientOptions) *gocql.ClusterConfig {
 	cluster := gocql.NewCluster(config.server)
-	cluster.Consistency = gocql.One
+	cluster.Port = config.port
+	cluster.Consistency = config.consistency
 	cluster.ProtoVersion = 4
 
 	cluster.Timeout = config.timeout
 	cluster.ConnectTimeout = config.connectionTimeout
 
+	cluster.MaxWaitSchemaAgreement = config.schemaAgreement
+
 	cluster.DisableInitialHostLookup = !config.initialHostLookup
 	cluster.IgnorePeerAddr = config.ignorePeerAddr
 
+	// keepalives keep intermediate firewalls from dropping idle connections
+	cluster.SocketKeepalive = config.idleValidation
+
+	cluster.Events.DisableNodeStatusEvents = config.disabledEvents.status
+	cluster.Events.DisableTopologyEvents = config.disabledEvents.topology
+	cluster.Events.DisableSchemaEvents = config.disabledEvents.schema
+	cluster.ReconnectInterval = config.reconnectInterval
+
+	if config.compression == compressionSnappy {
+		cluster.Compressor = gocql.SnappyCompressor{}
+	}
+	cluster.PoolConfig.HostSelectionPolicy = hostSelectionPolicy(config)
+
+	cluster.DisableSkipMetadata = config.driver.disableSkipMetadata
+	cluster.MaxRoutingKeyInfo = config.driver.maxRoutingKeyInfo
+	cluster.PageSize = config.driver.pageSize
+
 	if config.ssl != nil {
 		cluster = addSslOptions(cluster, config.ssl)
 	}
+	if config.sigv4 != nil {
+		cluster = addSigV4(cluster, *config.sigv4)
+	}
+	if config.clusterHook != nil {
+		config.clusterHook(cluster)
+	}
 
 	return cluster
 }
 
-func getSession(co clientOptions) *gocql.Session {
+// hostSelectionPolicy returns the host selection policy of the cluster,
+// nil leaves the round-robin default of gocql.
+func hostSelectionPolicy(config clientOptions) gocql.HostSelectionPolicy {
+	var policy gocql.HostSelectionPolicy
+	if config.localDC != "" {
+		policy = newDCAwareHostPolicy(config.localDC)
+	}
+	if config.tokenAware {
+		// hosts not owning the partition, e.g. without routing information,
+		// are picked by the fallback policy
+		if policy == nil {
+			policy = gocql.RoundRobinHostPolicy()
+		}
+		policy = gocql.TokenAwareHostPolicy(policy)
+	}
+	return policy
+}
+
+func getSession(co clientOptions) (*gocql.Session, error) {
 	cluster := createCluster(co)
-	session := initializeSession(cluster, co)
-	return session
+	var stats *poolStats
+	if co.poolStatsInterval > 0 {
+		stats = newPoolStats(cluster.PoolConfig.HostSelectionPolicy)
+		cluster.PoolConfig.HostSelectionPolicy = stats
+	}
+	session, err := initializeSession(cluster, co)
+	if err == nil && stats != nil {
+		go stats.logEvery(session, co.server, co.poolStatsInterval)
+	}
+	return session, err
 }
 
 func addSslOptions(cluster *gocql.ClusterConfig, options *sslOptions) *gocql.ClusterConfig {
@@ -310,6 +1206,14 @@ func addSslOptions(cluster *gocql.ClusterConfig, options *sslOptions) *gocql.Clu
 		cluster.Authenticator = gocql.PasswordAuthenticator{
 			Username: options.username,
 			Password: options.password}
+
+		// Proxy authentication with DSE Unified Authentication.
+		if options.authorizationID != "" {
+			cluster.Authenticator = dseProxyAuthenticator{
+				username:        options.username,
+				password:        options.password,
+				authorizationID: options.authorizationID}
+		}
 	}
 
 	sslOpts := &gocql.SslOptions{
@@ -331,26 +1235,226 @@ func addSslOptions(cluster *gocql.ClusterConfig, options *sslOptions) *gocql.Clu
 	return cluster
 }
 
-func initializeSession(cluster *gocql.ClusterConfig, co clientOptions) *gocql.Session {
+func initializeSession(cluster *gocql.ClusterConfig, co clientOptions) (*gocql.Session, error) {
 	session, err := cluster.CreateSession()
 	if err != nil {
-		log.Fatal(err.Error())
+		cassaLog.WithFields(log.Fields{
+			"err": err,
+		}).Error("Cassandra client session creation error")
+		return nil, err
 	}
+	return session, nil
+}
 
+// createSchema creates the keyspace, if configured, and all tables the client writes to.
+func createSchema(session *gocql.Session, co clientOptions) error {
+	names := newCQLNames(co)
 	if co.createKeyspace {
-		if err := session.Query(fmt.Sprintf(createKeyspaceCQL, co.keyspace)).Exec(); err != nil {
-			log.Fatal(err.Error())
+		if err := session.Query(fmt.Sprintf(createKeyspaceCQL, names.keyspace, co.replication.cql())).Exec(); err != nil {
+			return err
+		}
+		if co.tagsTableEnabled && co.tagsKeyspace != co.keyspace {
+			if err := session.Query(fmt.Sprintf(createKeyspaceCQL, names.tagsKeyspace, co.replication.cql())).Exec(); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, ks := range keyspaces(co) {
+		if err := validateReplication(session, schemaIdentifier(ks, co.preserveCase), co.localDC); err != nil {
+			return err
+		}
+	}
+
+	// table options are generated for the version of the cluster
+	version, err := queryServerVersion(session)
+	if err != nil {
+		cassaLog.WithFields(log.Fields{
+			"err": err,
+		}).Warn("Cassandra version unknown, the tables are created for current versions")
+	}
+	compaction := co.compaction
+	if co.tableCompaction != nil {
+		compaction = *co.tableCompaction
+	}
+	if !version.timeWindowCompaction() && (compaction.strategy == compactionTimeWindow || co.compaction.strategy == compactionTimeWindow) {
+		cassaLog.WithFields(log.Fields{
+			"version": version,
+		}).Warn("Cassandra version has no TimeWindowCompactionStrategy, the DateTieredCompactionStrategy is used")
+	}
+	compaction = compaction.forServer(version)
+	seriesCompaction := co.compaction.forServer(version)
+	metricsTable := withCompaction(metricsTableCQL(co), compaction)
+	if co.tableTemplate != "" {
+		stmt, err := tableTemplateCQL(co.tableTemplate, co)
+		if err != nil {
+			return err
+		}
+		metricsTable = stmt
+	}
+	// tables of samples are not needed in metadata-only mode
+	stmts := []string{}
+	if !co.metadataOnly {
+		stmts = append(stmts, metricsTable)
+	}
+	if co.tagsTableEnabled {
+		stmts = append(stmts, withCompaction(fmt.Sprintf(createTagTableCQL, names.tagsKeyspace), seriesCompaction))
+	}
+	if co.sharedTagSets && !co.metadataOnly {
+		stmts = append(stmts, fmt.Sprintf(createTagSetTableCQL, names.keyspace))
+	}
+	if co.boolTransitions && !co.metadataOnly {
+		stmts = append(stmts, withCompaction(fmt.Sprintf(createTransitionTableCQL, names.keyspace), seriesCompaction))
+	}
+	if co.buildInfo {
+		stmts = append(stmts, fmt.Sprintf(createBuildTableCQL, names.keyspace))
+	}
+	if co.selfStatsInterval > 0 && co.selfStatsTable {
+		stmts = append(stmts, fmt.Sprintf(createSelfStatsTableCQL, names.keyspace))
+	}
+	if err := createTables(session, stmts, co.schemaConcurrency); err != nil {
+		return err
+	}
+	if co.tableTemplate == "" && !co.metadataOnly {
+		if err := checkPartitionKey(session, co); err != nil {
+			return err
+		}
+	}
+
+	// optional columns are added to tables created without them
+	extra := []string{}
+	if co.versionTag != "" {
+		extra = append(extra, "appVer text")
+	}
+	if co.int64Val {
+		extra = append(extra, "int64Val bigint")
+	}
+	if co.varintVal {
+		extra = append(extra, "varintVal varint")
+	}
+	if co.tagsTableEnabled {
+		if err := addMissingColumns(session, co.tagsKeyspace, "tags", extra, co.preserveCase); err != nil {
+			return err
 		}
 	}
+	// only rows of the metrics table carry a checksum
+	if co.checksum {
+		extra = append(extra, "checksum text")
+	}
+	if co.ingestTime {
+		extra = append(extra, "ingestTime timestamp")
+	}
+	if co.outOfOrder == outOfOrderFlag {
+		extra = append(extra, "outOfOrder boolean")
+	}
+	if co.counterResets == counterResetFlag {
+		extra = append(extra, "counterReset boolean")
+	}
+	if co.staticColumns {
+		extra = append(extra, "unit text static", "hostTags map<text,text> static")
+	}
+	for _, name := range nsColumnNames(co.nsColumns) {
+		extra = append(extra, name+" text")
+	}
+	// tables of a template keep their layout
+	if co.tableTemplate != "" || co.metadataOnly {
+		extra = nil
+	}
+	if err := addMissingColumns(session, co.keyspace, co.tableName, extra, co.preserveCase); err != nil {
+		return err
+	}
+	if err := createIndexes(session, co, version); err != nil {
+		return err
+	}
+	if err := createViews(session, co, version); err != nil {
+		return err
+	}
+
+	if co.buildInfo {
+		return writeBuildInfo(session, names.keyspace, co.started)
+	}
+	return nil
+}
 
-	if err := session.Query(fmt.Sprintf(createTableCQL, co.keyspace, co.tableName)).Exec(); err != nil {
-		log.Fatal(err.Error())
+// metricsTableCQL returns the CREATE TABLE statement of the built-in metrics table.
+func metricsTableCQL(co clientOptions) string {
+	bucketColumn := ""
+	if co.partitionBucket > 0 {
+		bucketColumn = "bucket timestamp, "
 	}
+	names := newCQLNames(co)
+	return fmt.Sprintf(createTableCQL, names.keyspace, names.table, co.timeColumn, co.timeColumnType,
+		bucketColumn, partitionKey(co.partitionBucket))
+}
 
-	if err := session.Query(fmt.Sprintf(createTagTableCQL, co.keyspace)).Exec(); err != nil {
-		log.Fatal(err.Error())
+// keyspaces returns the keyspaces the client writes to.
+func keyspaces(co clientOptions) []string {
+	if co.tagsTableEnabled && co.tagsKeyspace != co.keyspace {
+		return []string{co.keyspace, co.tagsKeyspace}
+	}
+	return []string{co.keyspace}
+}
+
+// addMissingColumns adds the columns, given as "name type", which are missing
+// from the table. preserve is set if the table names preserve their case.
+func addMissingColumns(session *gocql.Session, keyspace, table string, cols []string, preserve bool) error {
+	if len(cols) == 0 {
+		return nil
+	}
+	km, err := session.KeyspaceMetadata(schemaIdentifier(keyspace, preserve))
+	if err != nil {
+		return err
+	}
+	tm, ok := km.Tables[schemaIdentifier(table, preserve)]
+	if !ok {
+		return fmt.Errorf("table %s.%s not found", keyspace, table)
+	}
+	for _, col := range cols {
+		name := strings.Fields(col)[0]
+		if _, ok := tm.Columns[strings.ToLower(name)]; ok {
+			continue
+		}
+		stmt := fmt.Sprintf(addColumnCQL, cqlIdentifier(keyspace, preserve), cqlIdentifier(table, preserve), col)
+		if err := session.Query(stmt).Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createTables executes the given DDL statements concurrently, running at most
+// parallelism of them at once. gocql awaits schema agreement after every schema
+// change, so all tables are usable when it returns. The first error is returned.
+func createTables(session *gocql.Session, stmts []string, parallelism int) error {
+	return runConcurrently(len(stmts), parallelism, func(i int) error {
+		return session.Query(stmts[i]).Exec()
+	})
+}
+
+// runConcurrently calls fn for 0 to n-1, running at most parallelism calls at
+// once, and returns the first error once all calls returned.
+func runConcurrently(n, parallelism int, fn func(i int) error) error {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	sem := make(chan struct{}, parallelism)
+	errs := make(chan error, n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if err := fn(i); err != nil {
+				errs <- err
+			}
+		}(i)
 	}
-	return session
+	wg.Wait()
+	close(errs)
+	return <-errs
 }
 
 // getValidTagIndex checks if there are tags to be indexed for a giving metric.
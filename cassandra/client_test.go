@@ -0,0 +1,359 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"errors"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestValTypeValue(t *testing.T) {
+	Convey("Get valType column values", t, func() {
+		Convey("So column mode should store the value column name", func() {
+			So(valTypeValue("doubleVal", valTypeColumn), ShouldEqual, "doubleVal")
+			So(valTypeValue("strVal", valTypeColumn), ShouldEqual, "strVal")
+		})
+		Convey("So name mode should store the value type name", func() {
+			So(valTypeValue("doubleVal", valTypeName), ShouldEqual, "double")
+			So(valTypeValue("strVal", valTypeName), ShouldEqual, "string")
+			So(valTypeValue("boolVal", valTypeName), ShouldEqual, "bool")
+		})
+	})
+}
+
+func TestReadOnlyClient(t *testing.T) {
+	Convey("Create a read-only client", t, func() {
+		cc := &cassaClient{readOnly: true, drops: newDropCounters()}
+		Convey("So saving metrics should be refused", func() {
+			So(cc.saveMetrics(nil, nil), ShouldEqual, ErrReadOnly)
+		})
+	})
+}
+
+func TestSkipSchema(t *testing.T) {
+	Convey("Create a client of a bootstrapped schema", t, func() {
+		co := defaultClientOptions()
+		co.skipSchema = true
+		cc := newCassaClient(nil, co, "")
+		Convey("So the schema should be ready without creating it", func() {
+			So(cc.schema.isReady(), ShouldBeTrue)
+		})
+	})
+}
+
+func TestSaveMetricsWithResults(t *testing.T) {
+	Convey("Save metrics with per metric results", t, func() {
+		metrics := []plugin.MetricType{
+			*plugin.NewMetricType(core.NewNamespace("foo", "bar"), time.Now(), nil, "", map[string]string{"invalid": "type"}),
+			*plugin.NewMetricType(core.NewNamespace("foo", "baz"), time.Now(), nil, "", []int{1}),
+		}
+
+		Convey("So every metric should get a result", func() {
+			cc := &cassaClient{drops: newDropCounters(), schema: newSchemaState(0)}
+			cc.schema.setReady()
+			results := []WriteResult{}
+			for r := range cc.SaveMetricsWithResults(metrics) {
+				results = append(results, r)
+			}
+			So(results, ShouldHaveLength, 2)
+			So(results[0].Namespace, ShouldEqual, "/foo/bar")
+			So(results[0].Success, ShouldBeFalse)
+			So(results[0].Err, ShouldHaveSameTypeAs, dropError{})
+			So(results[1].Namespace, ShouldEqual, "/foo/baz")
+			So(cc.drops.snapshot()[dropInvalidType], ShouldEqual, 2)
+		})
+		Convey("So a written metric should be reported as successful", func() {
+			var stmts []string
+			cc := &cassaClient{names: cqlNames{keyspace: "snap", tagsKeyspace: "snap", table: "metrics"},
+				valTypeMode: valTypeNone, statements: newStatementCache(), drops: newDropCounters(),
+				schema: newSchemaState(0), tagsTableDisabled: true,
+				query: func(stmt string, values []interface{}) error {
+					stmts = append(stmts, stmt)
+					return nil
+				}}
+			cc.schema.setReady()
+			written := *plugin.NewMetricType(core.NewNamespace("foo", "qux"), time.Now(), nil, "", 1.5)
+			results := []WriteResult{}
+			for r := range cc.SaveMetricsWithResults(append(metrics, written)) {
+				results = append(results, r)
+			}
+			So(results, ShouldHaveLength, 3)
+			So(results[2].Namespace, ShouldEqual, "/foo/qux")
+			So(results[2].Success, ShouldBeTrue)
+			So(results[2].Err, ShouldBeNil)
+			So(results[0].Success, ShouldBeFalse)
+			So(stmts, ShouldHaveLength, 1)
+			So(stmts[0], ShouldStartWith, "INSERT INTO snap.metrics")
+		})
+		Convey("So a client with a pending schema should report every metric as pending", func() {
+			cc := &cassaClient{drops: newDropCounters(), schema: newSchemaState(0)}
+			for r := range cc.SaveMetricsWithResults(metrics) {
+				So(r.Success, ShouldBeFalse)
+				So(r.Err, ShouldEqual, ErrSchemaPending)
+			}
+		})
+		Convey("So a read-only client should report every metric as refused", func() {
+			cc := &cassaClient{readOnly: true, drops: newDropCounters()}
+			for r := range cc.SaveMetricsWithResults(metrics) {
+				So(r.Success, ShouldBeFalse)
+				So(r.Err, ShouldEqual, ErrReadOnly)
+			}
+		})
+	})
+}
+
+func TestInsertColumns(t *testing.T) {
+	Convey("Build insert statements", t, func() {
+		m := *plugin.NewMetricType(core.NewNamespace("foo", "bar"), time.Now(), map[string]string{"app_version": "1.2"}, "", 1.0)
+		Convey("So the statement should list the columns and their values", func() {
+			cols := []column{{"ns", "/foo"}, {"ver", 1}}
+			So(insertCQL("snap", "metrics", cols), ShouldEqual, "INSERT INTO snap.metrics (ns, ver) VALUES (?, ?)")
+			So(columnValues(cols), ShouldResemble, []interface{}{"/foo", 1})
+		})
+		Convey("So columns computed by Cassandra should not be bound", func() {
+			cols := []column{{"ns", "/foo"}, {"ingestTime", ingestTimeCQL}}
+			So(insertCQL("snap", "metrics", cols), ShouldEqual, "INSERT INTO snap.metrics (ns, ingestTime) VALUES (?, toTimestamp(now()))")
+			So(columnValues(cols), ShouldResemble, []interface{}{"/foo"})
+		})
+		Convey("So the appVer column should only be written with a version tag", func() {
+			cc := &cassaClient{valTypeMode: valTypeNone}
+			p, err := newPoint(m)
+			So(err, ShouldBeNil)
+			cols := cc.valueColumns(nil, p)
+			So(cols, ShouldNotContain, column{"appVer", ""})
+			So(len(cols), ShouldEqual, 4)
+
+			cc.versionTag = "app_version"
+			cols = cc.valueColumns(nil, p)
+			So(cols, ShouldContain, column{"appVer", "1.2"})
+		})
+		Convey("So the columns of a metric should be computed once", func() {
+			p, err := newPoint(m)
+			So(err, ShouldBeNil)
+			So(p.ns, ShouldEqual, "/foo/bar")
+			So(p.column, ShouldEqual, "doubleVal")
+			So(p.value, ShouldEqual, 1.0)
+
+			m.Data_ = int64(7)
+			p, err = newPoint(m)
+			So(err, ShouldBeNil)
+			So(p.value, ShouldEqual, 7.0)
+
+			m.Data_ = []int{1}
+			_, err = newPoint(m)
+			So(err, ShouldNotBeNil)
+		})
+		Convey("So the column buffer of a batch should be reused", func() {
+			wb := &writeBatch{}
+			cols := append(wb.columns(), column{"ns", "/foo"}, column{"ver", 1})
+			wb.release(cols)
+			So(len(wb.columns()), ShouldEqual, 0)
+			So(cap(wb.columns()), ShouldBeGreaterThanOrEqualTo, 2)
+		})
+	})
+}
+
+func TestInt64Val(t *testing.T) {
+	Convey("Store integers in the int64Val column", t, func() {
+		m := *plugin.NewMetricType(core.NewNamespace("foo", "bar"), time.Now(), nil, "", int64(1<<53+1))
+		p, err := newPoint(m)
+		So(err, ShouldBeNil)
+		So(p.column, ShouldEqual, "doubleVal")
+
+		Convey("So integers should keep their precision", func() {
+			p.preferInt64()
+			So(p.column, ShouldEqual, "int64Val")
+			So(p.value, ShouldEqual, int64(1<<53+1))
+			So(valTypeValue(p.column, valTypeName), ShouldEqual, "int64")
+		})
+		Convey("So floats should stay in the doubleVal column", func() {
+			m.Data_ = 1.5
+			p, err := newPoint(m)
+			So(err, ShouldBeNil)
+			p.preferInt64()
+			So(p.column, ShouldEqual, "doubleVal")
+		})
+		Convey("So unsigned integers beyond the bigint range should stay doubles", func() {
+			_, ok := toInt64(uint64(math.MaxUint64))
+			So(ok, ShouldBeFalse)
+			v, ok := toInt64(uint32(7))
+			So(ok, ShouldBeTrue)
+			So(v, ShouldEqual, 7)
+		})
+	})
+}
+
+func TestVarintVal(t *testing.T) {
+	Convey("Store unsigned 64 bit integers in the varintVal column", t, func() {
+		m := *plugin.NewMetricType(core.NewNamespace("foo", "bar"), time.Now(), nil, "", uint64(math.MaxUint64))
+		p, err := newPoint(m)
+		So(err, ShouldBeNil)
+
+		Convey("So they should keep their full range", func() {
+			p.preferInt64()
+			p.preferVarint()
+			So(p.column, ShouldEqual, "varintVal")
+			So(p.value, ShouldEqual, uint64(math.MaxUint64))
+			So(valTypeValue(p.column, valTypeName), ShouldEqual, "varint")
+		})
+		Convey("So small values should stay in the same column", func() {
+			m.Data_ = uint64(1)
+			p, err := newPoint(m)
+			So(err, ShouldBeNil)
+			p.preferInt64()
+			p.preferVarint()
+			So(p.column, ShouldEqual, "varintVal")
+		})
+		Convey("So signed integers should not be moved", func() {
+			m.Data_ = int64(-1)
+			p, err := newPoint(m)
+			So(err, ShouldBeNil)
+			p.preferVarint()
+			So(p.column, ShouldEqual, "doubleVal")
+		})
+	})
+}
+
+func TestDoublePrecision(t *testing.T) {
+	Convey("Round doubles to decimal places", t, func() {
+		m := *plugin.NewMetricType(core.NewNamespace("foo", "bar"), time.Now(), nil, "", 0.1+0.2)
+
+		Convey("So excess precision should be dropped", func() {
+			p, err := newPoint(m)
+			So(err, ShouldBeNil)
+			p.roundDouble(2)
+			So(p.value, ShouldEqual, 0.3)
+		})
+		Convey("So negative values should be rounded as well", func() {
+			m.Data_ = -1.23456
+			p, err := newPoint(m)
+			So(err, ShouldBeNil)
+			p.roundDouble(3)
+			So(p.value, ShouldEqual, -1.235)
+		})
+		Convey("So infinities and other columns should be kept", func() {
+			m.Data_ = math.Inf(-1)
+			p, err := newPoint(m)
+			So(err, ShouldBeNil)
+			p.roundDouble(1)
+			So(math.IsInf(p.value.(float64), -1), ShouldBeTrue)
+			m.Data_ = int64(1<<53 + 1)
+			p, err = newPoint(m)
+			So(err, ShouldBeNil)
+			p.preferInt64()
+			p.roundDouble(1)
+			So(p.value, ShouldEqual, int64(1<<53+1))
+		})
+	})
+}
+
+func TestWriteTime(t *testing.T) {
+	Convey("Write timestamps are the metric timestamps in microseconds", t, func() {
+		ts := time.Unix(1500000000, 123456789)
+		So(writeTime(ts), ShouldEqual, int64(1500000000123456))
+		So(writeTime(ts), ShouldEqual, writeTime(ts.Add(200)))
+	})
+}
+
+func TestMetadataOnly(t *testing.T) {
+	Convey("Write metrics in metadata-only mode", t, func() {
+		cc := &cassaClient{tagsIndex: "experiment", tagsKeyspace: "snap", names: cqlNames{keyspace: "snap", tagsKeyspace: "snap", table: "metrics"},
+			valTypeMode: valTypeNone, statements: newStatementCache(), drops: newDropCounters(), metadataOnly: true}
+		tb := newTagBatches(10)
+		wb := &writeBatch{size: 10, tags: tb}
+
+		m := *plugin.NewMetricType(core.NewNamespace("foo", "bar"), time.Now(), map[string]string{"experiment": "1"}, "", 1.0)
+		So(cc.saveMetric(m, nil, wb), ShouldBeNil)
+
+		Convey("So only the tags table rows should be written", func() {
+			So(tb.rows[tagPartition{"experiment", "1"}], ShouldHaveLength, 1)
+			So(wb.batch, ShouldBeNil)
+		})
+	})
+}
+
+func TestTagsTableDisabled(t *testing.T) {
+	Convey("Write metrics with the tags table disabled", t, func() {
+		cc := &cassaClient{tagsIndex: "experiment", tagsKeyspace: "snap", names: cqlNames{keyspace: "snap", tagsKeyspace: "snap", table: "metrics"},
+			valTypeMode: valTypeNone, statements: newStatementCache(), drops: newDropCounters(), metadataOnly: true, tagsTableDisabled: true}
+		tb := newTagBatches(10)
+		wb := &writeBatch{size: 10, tags: tb}
+
+		m := *plugin.NewMetricType(core.NewNamespace("foo", "bar"), time.Now(), map[string]string{"experiment": "1"}, "", 1.0)
+		So(cc.saveMetric(m, nil, wb), ShouldBeNil)
+
+		Convey("So no tags table rows should be written", func() {
+			So(tb.rows, ShouldBeEmpty)
+		})
+	})
+
+	Convey("Keyspaces of a disabled tags table are not used", t, func() {
+		co := clientOptions{keyspace: "snap", tagsKeyspace: "snap_tags", tagsTableEnabled: true}
+		So(keyspaces(co), ShouldResemble, []string{"snap", "snap_tags"})
+		co.tagsTableEnabled = false
+		So(keyspaces(co), ShouldResemble, []string{"snap"})
+	})
+}
+
+func TestRunConcurrently(t *testing.T) {
+	Convey("Run the statements of the schema setup concurrently", t, func() {
+		var running, peak, calls int32
+		run := func(i int) error {
+			atomic.AddInt32(&calls, 1)
+			n := atomic.AddInt32(&running, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			if i == 3 {
+				return errors.New("table 3 failed")
+			}
+			return nil
+		}
+
+		Convey("So at most parallelism calls should run at once", func() {
+			err := runConcurrently(10, 3, run)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "table 3 failed")
+			So(atomic.LoadInt32(&calls), ShouldEqual, 10)
+			So(atomic.LoadInt32(&peak), ShouldBeBetweenOrEqual, 2, 3)
+		})
+		Convey("So a parallelism below 1 should run the calls one at a time", func() {
+			So(runConcurrently(3, 0, run), ShouldBeNil)
+			So(atomic.LoadInt32(&peak), ShouldEqual, 1)
+		})
+		Convey("So nothing should run without statements", func() {
+			So(runConcurrently(0, 4, run), ShouldBeNil)
+			So(atomic.LoadInt32(&calls), ShouldEqual, 0)
+		})
+	})
+}
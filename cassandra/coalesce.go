@@ -0,0 +1,109 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"sync"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+)
+
+// coalescer merges the metrics of concurrent publishes to the same client,
+// e.g. of several tasks with the same config, so they are written in full
+// batches instead of many small ones. Publishes wait for the write of their
+// metrics, which reports its error to all publishes it merged.
+type coalescer struct {
+	// delay is the longest time metrics wait for others to be merged with
+	delay time.Duration
+	// max is the number of pending metrics written without waiting longer
+	max   int
+	write func([]plugin.MetricType) error
+
+	mu      sync.Mutex
+	pending []plugin.MetricType
+	waiters []chan error
+	timer   *time.Timer
+
+	// writeMu keeps one write at a time, metrics published meanwhile are merged
+	writeMu sync.Mutex
+}
+
+func newCoalescer(delay time.Duration, max int, write func([]plugin.MetricType) error) *coalescer {
+	if max < 1 {
+		max = 1
+	}
+	return &coalescer{delay: delay, max: max, write: write}
+}
+
+// publish saves the metrics, merged with the ones of concurrent publishes if
+// writes are coalesced. The writes of merged publishes are not timed, as
+// they are shared by the publishes.
+func (cc *cassaClient) publish(mts []plugin.MetricType, timings *publishTimings) error {
+	if cc.coalescer != nil {
+		return cc.coalescer.add(mts)
+	}
+	return cc.saveMetrics(mts, timings)
+}
+
+// add adds the metrics to the pending ones and returns the error of their write.
+func (c *coalescer) add(mts []plugin.MetricType) error {
+	done := make(chan error, 1)
+	c.mu.Lock()
+	c.pending = append(c.pending, mts...)
+	c.waiters = append(c.waiters, done)
+	if len(c.pending) >= c.max {
+		if c.timer != nil {
+			c.timer.Stop()
+			c.timer = nil
+		}
+		c.mu.Unlock()
+		go c.flush()
+	} else {
+		if c.timer == nil {
+			c.timer = time.AfterFunc(c.delay, c.flush)
+		}
+		c.mu.Unlock()
+	}
+	return <-done
+}
+
+// flush writes the pending metrics and reports the result to their publishes.
+func (c *coalescer) flush() {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.mu.Lock()
+	mts, waiters := c.pending, c.waiters
+	c.pending, c.waiters = nil, nil
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.mu.Unlock()
+	if len(waiters) == 0 {
+		return
+	}
+
+	err := c.write(mts)
+	for _, done := range waiters {
+		done <- err
+	}
+}
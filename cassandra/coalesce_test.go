@@ -0,0 +1,105 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCoalescer(t *testing.T) {
+	metric := func() plugin.MetricType {
+		return *plugin.NewMetricType(core.NewNamespace("foo"), time.Now(), nil, "", 1.0)
+	}
+
+	Convey("Given a coalescer", t, func() {
+		var mu sync.Mutex
+		var writes [][]plugin.MetricType
+		var err error
+		c := newCoalescer(20*time.Millisecond, 4, func(mts []plugin.MetricType) error {
+			mu.Lock()
+			defer mu.Unlock()
+			writes = append(writes, mts)
+			return err
+		})
+
+		Convey("Concurrent publishes are written together", func() {
+			errs := make(chan error, 3)
+			for i := 0; i < 3; i++ {
+				go func() {
+					errs <- c.add([]plugin.MetricType{metric()})
+				}()
+			}
+			for i := 0; i < 3; i++ {
+				So(<-errs, ShouldBeNil)
+			}
+			So(writes, ShouldHaveLength, 1)
+			So(writes[0], ShouldHaveLength, 3)
+		})
+
+		Convey("Publishes reaching the maximum are written without delay", func() {
+			start := time.Now()
+			So(c.add([]plugin.MetricType{metric(), metric(), metric(), metric()}), ShouldBeNil)
+			So(time.Since(start), ShouldBeLessThan, 20*time.Millisecond)
+			So(writes, ShouldHaveLength, 1)
+		})
+
+		Convey("The error of a write is returned to its publishes", func() {
+			err = errors.New("unavailable")
+			So(c.add([]plugin.MetricType{metric()}), ShouldEqual, err)
+		})
+	})
+}
+
+func TestCoalescerDefaults(t *testing.T) {
+	Convey("Given a coalescer with the default settings", t, func() {
+		co := defaultClientOptions()
+		co.coalesceDelay = 20 * time.Millisecond
+		var mu sync.Mutex
+		var writes [][]plugin.MetricType
+		c := newCoalescer(co.coalesceDelay, co.coalesceMaxMetrics, func(mts []plugin.MetricType) error {
+			mu.Lock()
+			defer mu.Unlock()
+			writes = append(writes, mts)
+			return nil
+		})
+
+		Convey("Concurrent publishes of a metric each are written together", func() {
+			errs := make(chan error, 5)
+			for i := 0; i < 5; i++ {
+				go func() {
+					errs <- c.add([]plugin.MetricType{*plugin.NewMetricType(core.NewNamespace("foo"), time.Now(), nil, "", 1.0)})
+				}()
+			}
+			for i := 0; i < 5; i++ {
+				So(<-errs, ShouldBeNil)
+			}
+			So(writes, ShouldHaveLength, 1)
+			So(writes[0], ShouldHaveLength, 5)
+		})
+	})
+}
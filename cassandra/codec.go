@@ -0,0 +1,114 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+)
+
+// maxPooledBufferSize is the capacity up to which encode buffers are kept for reuse,
+// larger buffers of an exceptional payload are left to the garbage collector
+const maxPooledBufferSize = 4 << 20
+
+var (
+	readerPool = sync.Pool{New: func() interface{} { return new(bytes.Reader) }}
+	bufferPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+)
+
+// decodeContent decodes the GOB encoded metrics of a publish. The reader over
+// the content is reused between publishes. A gob decoder is bound to the type
+// information of the stream it reads, so every payload needs its own decoder.
+func decodeContent(content []byte) ([]plugin.MetricType, error) {
+	r := readerPool.Get().(*bytes.Reader)
+	r.Reset(content)
+	defer func() {
+		r.Reset(nil)
+		readerPool.Put(r)
+	}()
+	return decodeMetrics(r)
+}
+
+// decodeJSONContent decodes the JSON encoded metrics of a publish. Integer
+// data keeps its precision, so it is stored like integers of GOB content.
+func decodeJSONContent(content []byte) ([]plugin.MetricType, error) {
+	var mts []plugin.MetricType
+	d := json.NewDecoder(bytes.NewReader(content))
+	d.UseNumber()
+	if err := d.Decode(&mts); err != nil {
+		return nil, err
+	}
+	for i := range mts {
+		if n, ok := mts[i].Data_.(json.Number); ok {
+			mts[i].Data_ = jsonNumber(n)
+		}
+	}
+	return mts, nil
+}
+
+// jsonNumber converts a JSON number into an int64, into a uint64 beyond the
+// int64 range, or into a float64 if it is no integer.
+func jsonNumber(n json.Number) interface{} {
+	if v, err := n.Int64(); err == nil {
+		return v
+	}
+	if v, err := strconv.ParseUint(n.String(), 10, 64); err == nil {
+		return v
+	}
+	if v, err := n.Float64(); err == nil {
+		return v
+	}
+	return n.String()
+}
+
+// decodeMetrics reads GOB encoded metrics from r.
+func decodeMetrics(r io.Reader) ([]plugin.MetricType, error) {
+	var mts []plugin.MetricType
+	if err := gob.NewDecoder(r).Decode(&mts); err != nil {
+		return nil, err
+	}
+	return mts, nil
+}
+
+// encodeMetrics GOB encodes the metrics into a buffer of the pool. The buffer
+// has to be handed back with releaseBuffer once its content was written.
+func encodeMetrics(mts []plugin.MetricType) (*bytes.Buffer, error) {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	if err := gob.NewEncoder(buf).Encode(mts); err != nil {
+		releaseBuffer(buf)
+		return nil, err
+	}
+	return buf, nil
+}
+
+// releaseBuffer puts the buffer back into the pool.
+func releaseBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	bufferPool.Put(buf)
+}
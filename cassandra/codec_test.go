@@ -0,0 +1,98 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCodec(t *testing.T) {
+	Convey("Encode metrics", t, func() {
+		mts := []plugin.MetricType{
+			*plugin.NewMetricType(core.NewNamespace("foo", "bar"), time.Now(), map[string]string{"key": "val"}, "", 1.0),
+		}
+		buf, err := encodeMetrics(mts)
+		So(err, ShouldBeNil)
+		content := append([]byte(nil), buf.Bytes()...)
+		releaseBuffer(buf)
+
+		Convey("So every payload should be decoded with the pooled readers", func() {
+			for i := 0; i < 3; i++ {
+				decoded, err := decodeContent(content)
+				So(err, ShouldBeNil)
+				So(decoded, ShouldHaveLength, 1)
+				So(decoded[0].Namespace().String(), ShouldEqual, "/foo/bar")
+				So(decoded[0].Data(), ShouldEqual, 1.0)
+			}
+		})
+		Convey("So invalid content should fail to decode", func() {
+			_, err := decodeContent([]byte("foo"))
+			So(err, ShouldNotBeNil)
+		})
+		Convey("So a reused buffer should only hold the new payload", func() {
+			buf, err := encodeMetrics(mts)
+			So(err, ShouldBeNil)
+			defer releaseBuffer(buf)
+			So(buf.Bytes(), ShouldResemble, content)
+		})
+	})
+}
+
+func TestJSONCodec(t *testing.T) {
+	Convey("Decode JSON encoded metrics", t, func() {
+		mts := []plugin.MetricType{
+			*plugin.NewMetricType(core.NewNamespace("foo", "bar"), time.Now(), map[string]string{"key": "val"}, "", 1),
+		}
+		content, err := json.Marshal(mts)
+		So(err, ShouldBeNil)
+
+		decoded, err := decodeJSONContent(content)
+		So(err, ShouldBeNil)
+		So(decoded, ShouldHaveLength, 1)
+		So(decoded[0].Namespace().String(), ShouldEqual, "/foo/bar")
+		So(decoded[0].Tags(), ShouldResemble, map[string]string{"key": "val"})
+
+		Convey("So integers should be decoded as int64", func() {
+			So(decoded[0].Data(), ShouldEqual, int64(1))
+		})
+		Convey("So large integers should keep their precision", func() {
+			content := []byte(`[{"namespace":[{"Value":"foo"}],"data":9007199254740993},` +
+				`{"namespace":[{"Value":"foo"}],"data":18446744073709551615},` +
+				`{"namespace":[{"Value":"foo"}],"data":1.5}]`)
+			decoded, err := decodeJSONContent(content)
+			So(err, ShouldBeNil)
+			So(decoded, ShouldHaveLength, 3)
+			So(decoded[0].Data(), ShouldEqual, int64(9007199254740993))
+			So(decoded[1].Data(), ShouldEqual, uint64(18446744073709551615))
+			So(decoded[2].Data(), ShouldEqual, 1.5)
+		})
+		Convey("So invalid content should fail to decode", func() {
+			_, err := decodeJSONContent([]byte("{"))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,74 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Compaction strategies of the time series tables.
+const (
+	compactionSizeTiered = "SizeTieredCompactionStrategy"
+	compactionLeveled    = "LeveledCompactionStrategy"
+	compactionTimeWindow = "TimeWindowCompactionStrategy"
+	// compactionDateTiered replaces compactionTimeWindow on servers without it
+	compactionDateTiered = "DateTieredCompactionStrategy"
+)
+
+// compactionOptions configure the compaction of the time series tables
+// created by the publisher. An empty strategy keeps the Cassandra default.
+type compactionOptions struct {
+	strategy   string
+	windowUnit string
+	windowSize int
+}
+
+// cql returns the compaction option of a CREATE TABLE statement.
+func (c compactionOptions) cql() string {
+	switch c.strategy {
+	case "":
+		return ""
+	case compactionTimeWindow:
+		return fmt.Sprintf(" AND compaction = {'class': '%s', 'compaction_window_unit': '%s', 'compaction_window_size': %d}",
+			c.strategy, c.windowUnit, c.windowSize)
+	case compactionDateTiered:
+		return fmt.Sprintf(" AND compaction = {'class': '%s', 'base_time_seconds': %d}", c.strategy, c.windowSeconds())
+	default:
+		return fmt.Sprintf(" AND compaction = {'class': '%s'}", c.strategy)
+	}
+}
+
+// windowSeconds returns the length of the compaction window in seconds.
+func (c compactionOptions) windowSeconds() int {
+	switch c.windowUnit {
+	case "MINUTES":
+		return c.windowSize * 60
+	case "HOURS":
+		return c.windowSize * 3600
+	}
+	return c.windowSize * 86400
+}
+
+// withCompaction adds the compaction option to a CREATE TABLE statement
+// ending with its WITH clause.
+func withCompaction(stmt string, c compactionOptions) string {
+	return strings.TrimSuffix(stmt, ";") + c.cql() + ";"
+}
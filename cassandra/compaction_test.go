@@ -0,0 +1,46 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWithCompaction(t *testing.T) {
+	Convey("Add compaction options to created tables", t, func() {
+		stmt := "CREATE TABLE IF NOT EXISTS snap.t (a int PRIMARY KEY) WITH CLUSTERING ORDER BY (time DESC);"
+
+		Convey("So the Cassandra default should be kept without a strategy", func() {
+			So(withCompaction(stmt, compactionOptions{}), ShouldEqual, stmt)
+		})
+		Convey("So the time window compaction should get its window", func() {
+			c := compactionOptions{strategy: compactionTimeWindow, windowUnit: "HOURS", windowSize: 6}
+			So(withCompaction(stmt, c), ShouldEqual, "CREATE TABLE IF NOT EXISTS snap.t (a int PRIMARY KEY) WITH CLUSTERING ORDER BY (time DESC)"+
+				" AND compaction = {'class': 'TimeWindowCompactionStrategy', 'compaction_window_unit': 'HOURS', 'compaction_window_size': 6};")
+		})
+		Convey("So other strategies should only set the class", func() {
+			c := compactionOptions{strategy: compactionLeveled}
+			So(withCompaction(stmt, c), ShouldEndWith, " AND compaction = {'class': 'LeveledCompactionStrategy'};")
+		})
+	})
+}
@@ -0,0 +1,179 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/ctypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// These tests are meant to be run with the race detector as well:
+// go test -race -tags small ./cassandra
+
+func TestConcurrentPublish(t *testing.T) {
+	Convey("Publish concurrently to an unreachable cluster connecting in the background", t, func() {
+		config := make(map[string]ctypes.ConfigValue)
+		config[serverAddrRuleKey] = ctypes.ConfigValueStr{Value: "127.0.0.1:1"}
+		config[connectionTimeoutRuleKey] = ctypes.ConfigValueInt{Value: 1}
+		config[connectModeRuleKey] = ctypes.ConfigValueStr{Value: connectBackground}
+		config[connectBufferSizeRuleKey] = ctypes.ConfigValueInt{Value: 1000}
+		configPolicy, err := NewCassandraPublisher().GetConfigPolicy()
+		So(err, ShouldBeNil)
+		_, errs := configPolicy.Get([]string{""}).Process(config)
+		So(errs.HasErrors(), ShouldBeFalse)
+
+		var buf bytes.Buffer
+		metrics := []plugin.MetricType{
+			*plugin.NewMetricType(core.NewNamespace("foo"), time.Now(), nil, "", 1.0),
+		}
+		So(gob.NewEncoder(&buf).Encode(metrics), ShouldBeNil)
+
+		pub := NewCassandraPublisher()
+		var wg sync.WaitGroup
+		for i := 0; i < 2*maxConcurrentPublishes; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				// the publishes fail once the connect failed, only the
+				// races are of interest here
+				pub.Publish(plugin.SnapGOBContentType, buf.Bytes(), config)
+			}()
+		}
+		wg.Wait()
+
+		Convey("So the publishes should share the clients of the config", func() {
+			pub.mu.Lock()
+			defer pub.mu.Unlock()
+			So(pub.configs, ShouldHaveLength, 1)
+		})
+	})
+
+	Convey("Publishes beyond the limit wait for a slot", t, func() {
+		pub := NewCassandraPublisher()
+		for i := 0; i < maxConcurrentPublishes; i++ {
+			pub.publishes.acquire()
+		}
+		done := make(chan error)
+		go func() {
+			done <- pub.Publish("unknown", nil, map[string]ctypes.ConfigValue{})
+		}()
+
+		select {
+		case <-done:
+			So("publish did not wait", ShouldBeEmpty)
+		case <-time.After(50 * time.Millisecond):
+		}
+		pub.publishes.release()
+		So(<-done, ShouldNotBeNil)
+	})
+}
+
+func TestConcurrentConnects(t *testing.T) {
+	Convey("Connect the clients of two configs concurrently", t, func() {
+		config := func(server string) map[string]ctypes.ConfigValue {
+			return map[string]ctypes.ConfigValue{
+				serverAddrRuleKey: ctypes.ConfigValueStr{Value: server},
+				portRuleKey:       ctypes.ConfigValueInt{Value: 1},
+			}
+		}
+		slow, other := config("127.0.0.2"), config("127.0.0.1")
+		pub := NewCassandraPublisher()
+		// the clients of the slow config are being initialized
+		connecting := &configClients{}
+		connecting.initMu.Lock()
+		pub.configs = map[string]*configClients{configKey(slow): connecting}
+		slowDone := make(chan error, 1)
+		go func() {
+			_, err := pub.clientsFor(slow, cassaLog)
+			slowDone <- err
+		}()
+
+		Convey("So the other config should not wait for the slow one", func() {
+			done := make(chan error, 1)
+			go func() {
+				_, err := pub.clientsFor(other, cassaLog)
+				done <- err
+			}()
+			var err error
+			waited := false
+			select {
+			case err = <-done:
+			case <-time.After(5 * time.Second):
+				waited = true
+			}
+			connecting.initMu.Unlock()
+			So(waited, ShouldBeFalse)
+			So(err, ShouldNotBeNil)
+			So(<-slowDone, ShouldNotBeNil)
+			if waited {
+				<-done
+			}
+		})
+	})
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	Convey("Write metrics of concurrent publishes with one client", t, func() {
+		cc := &cassaClient{tagsIndex: "experiment", tagsKeyspace: "snap", names: cqlNames{keyspace: "snap", tagsKeyspace: "snap", table: "metrics"},
+			valTypeMode: valTypeNone, statements: newStatementCache(), drops: newDropCounters(),
+			order: newOrderTracker(), resets: newResetTracker(""), resetFlag: true,
+			boolTransitions: true, transitions: newTransitionTracker(), statics: newStaticTracker()}
+		tb := newTagBatches(10)
+
+		const publishes, perPublish = 8, 50
+		var wg sync.WaitGroup
+		errs := make(chan error, publishes*perPublish)
+		for i := 0; i < publishes; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				wb := &writeBatch{size: perPublish, byPartition: true, tags: tb}
+				for j := 0; j < perPublish; j++ {
+					m := *plugin.NewMetricType(core.NewNamespace("foo", fmt.Sprint(j%5)), time.Now(),
+						map[string]string{"experiment": fmt.Sprint(i)}, "", float64(j))
+					errs <- cc.saveMetric(m, nil, wb)
+				}
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+
+		Convey("So every metric should be written", func() {
+			for err := range errs {
+				So(err, ShouldBeNil)
+			}
+			rows := 0
+			for _, r := range tb.rows {
+				rows += len(r)
+			}
+			So(rows, ShouldEqual, publishes*perPublish)
+		})
+	})
+}
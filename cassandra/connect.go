@@ -0,0 +1,145 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core/ctypes"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// connectBlocking connects the clients within the first publish
+	connectBlocking = "blocking"
+	// connectBackground connects the clients in the background and buffers
+	// the metrics published meanwhile
+	connectBackground = "background"
+)
+
+var (
+	// ErrConnecting is returned while the clients of a config connect in the background.
+	ErrConnecting = errors.New("Cassandra clients are connecting")
+	// ErrConnectBufferFull is returned for metrics published while connecting
+	// once the connect buffer is full.
+	ErrConnectBufferFull = errors.New("Cassandra connect buffer is full")
+)
+
+// backgroundConnect connects the clients of a config without blocking the
+// publishes, so a slow cluster does not delay the start of snap tasks.
+// Metrics published while connecting are buffered and written once the
+// clients are connected. A failed connect is returned by the next publish,
+// which starts connecting again.
+type backgroundConnect struct {
+	mu         sync.Mutex
+	connecting bool
+	err        error
+	// buffer holds the metrics published while connecting, at most max
+	buffer []plugin.MetricType
+	max    int
+}
+
+func newBackgroundConnect(max int) *backgroundConnect {
+	return &backgroundConnect{max: max}
+}
+
+// connectInBackground starts initializing the clients unless they are
+// initialized or initializing. It returns ErrConnecting while the clients
+// connect and the error of the last connect if it failed.
+func (c *configClients) connectInBackground(config map[string]ctypes.ConfigValue, logger *log.Entry) error {
+	bc := c.background
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	if bc.connecting {
+		return ErrConnecting
+	}
+	// c is only initialized while connecting, so ready is safe to read
+	if c.ready {
+		return nil
+	}
+
+	err := bc.err
+	bc.err = nil
+	bc.connecting = true
+	go c.connect(config, logger)
+	if err != nil {
+		return fmt.Errorf("connecting to Cassandra failed: %v", err)
+	}
+	return ErrConnecting
+}
+
+// connect initializes the clients and writes the metrics buffered meanwhile.
+func (c *configClients) connect(config map[string]ctypes.ConfigValue, logger *log.Entry) {
+	err := c.init(config, logger)
+
+	bc := c.background
+	bc.mu.Lock()
+	bc.connecting = false
+	bc.err = err
+	// the buffered metrics are kept for the next connect if this one failed
+	var buffered []plugin.MetricType
+	if err == nil {
+		buffered, bc.buffer = bc.buffer, nil
+	}
+	pending := len(bc.buffer)
+	bc.mu.Unlock()
+
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"err":      err,
+			"buffered": pending,
+		}).Error("connecting to Cassandra failed")
+		return
+	}
+	logger.WithFields(log.Fields{
+		"buffered": len(buffered),
+	}).Info("connected to Cassandra")
+	if len(buffered) == 0 {
+		return
+	}
+	if err := c.saveMetrics(buffered, nil); err != nil {
+		logger.WithFields(log.Fields{
+			"err": err,
+		}).Error("writing the metrics buffered while connecting failed")
+	}
+}
+
+// buffer keeps metrics published while connecting, failing once the buffer
+// is full. Metrics of a publish the clients connected after are written,
+// as the buffer was written already. The latency of the writes is added to
+// timings if it is not nil.
+func (c *configClients) buffer(mts []plugin.MetricType, timings *publishTimings) error {
+	bc := c.background
+	bc.mu.Lock()
+	// c is only initialized while connecting, so ready is safe to read
+	if !bc.connecting && c.ready {
+		bc.mu.Unlock()
+		return c.saveMetrics(mts, timings)
+	}
+	defer bc.mu.Unlock()
+	if len(bc.buffer)+len(mts) > bc.max {
+		return ErrConnectBufferFull
+	}
+	bc.buffer = append(bc.buffer, mts...)
+	return nil
+}
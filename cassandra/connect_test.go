@@ -0,0 +1,134 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/ctypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBackgroundConnect(t *testing.T) {
+	Convey("Publish to an unreachable cluster connecting in the background", t, func() {
+		configPolicy, err := NewCassandraPublisher().GetConfigPolicy()
+		So(err, ShouldBeNil)
+
+		config := make(map[string]ctypes.ConfigValue)
+		config[serverAddrRuleKey] = ctypes.ConfigValueStr{Value: "127.0.0.1:1"}
+		config[connectionTimeoutRuleKey] = ctypes.ConfigValueInt{Value: 1}
+		config[connectModeRuleKey] = ctypes.ConfigValueStr{Value: connectBackground}
+		config[connectBufferSizeRuleKey] = ctypes.ConfigValueInt{Value: 1}
+		_, errs := configPolicy.Get([]string{""}).Process(config)
+		So(errs.HasErrors(), ShouldBeFalse)
+
+		var buf bytes.Buffer
+		metrics := []plugin.MetricType{
+			*plugin.NewMetricType(core.NewNamespace("foo"), time.Now(), nil, "", 1.0),
+		}
+		So(gob.NewEncoder(&buf).Encode(metrics), ShouldBeNil)
+
+		pub := NewCassandraPublisher()
+		So(pub.Publish(plugin.SnapGOBContentType, buf.Bytes(), config), ShouldBeNil)
+		bc := pub.configs[configKey(config)].background
+		So(bc, ShouldNotBeNil)
+
+		Convey("So metrics published while connecting should be buffered", func() {
+			connecting := func() bool {
+				bc.mu.Lock()
+				defer bc.mu.Unlock()
+				return bc.connecting
+			}
+			if connecting() {
+				err := pub.Publish(plugin.SnapGOBContentType, buf.Bytes(), config)
+				if connecting() {
+					So(err, ShouldEqual, ErrConnectBufferFull)
+				}
+			}
+
+			Convey("So the failed connect should be returned by the next publish", func() {
+				for i := 0; i < 100 && connecting(); i++ {
+					time.Sleep(50 * time.Millisecond)
+				}
+				So(connecting(), ShouldBeFalse)
+				err := pub.Publish(plugin.SnapGOBContentType, buf.Bytes(), config)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldStartWith, "connecting to Cassandra failed")
+			})
+		})
+	})
+
+	Convey("Buffer metrics of a publish the clients connected after", t, func() {
+		mts := []plugin.MetricType{
+			*plugin.NewMetricType(core.NewNamespace("foo"), time.Now(), nil, "", 1.0),
+		}
+		c := &configClients{background: newBackgroundConnect(10)}
+		c.background.connecting = true
+
+		Convey("So metrics should be buffered while connecting", func() {
+			So(c.buffer(mts, nil), ShouldBeNil)
+			So(c.background.buffer, ShouldHaveLength, 1)
+		})
+		Convey("So metrics should be written once connected, as the buffer was drained", func() {
+			// the connect finished between the publish getting ErrConnecting and buffering
+			c.client = &cassaClient{readOnly: true, drops: newDropCounters()}
+			c.ready = true
+			c.background.connecting = false
+			err := c.buffer(mts, nil)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, ErrReadOnly.Error())
+			So(c.background.buffer, ShouldBeEmpty)
+		})
+		Convey("So metrics should be kept for the next connect if it failed", func() {
+			c.background.connecting = false
+			So(c.buffer(mts, nil), ShouldBeNil)
+			So(c.background.buffer, ShouldHaveLength, 1)
+		})
+	})
+
+	Convey("Prepare the connect mode of a config", t, func() {
+		configPolicy, err := NewCassandraPublisher().GetConfigPolicy()
+		So(err, ShouldBeNil)
+
+		config := make(map[string]ctypes.ConfigValue)
+		config[serverAddrRuleKey] = ctypes.ConfigValueStr{Value: serverAddress}
+		_, errs := configPolicy.Get([]string{""}).Process(config)
+		So(errs.HasErrors(), ShouldBeFalse)
+
+		Convey("So clients should connect within the publish by default", func() {
+			So(getBackgroundConnect(config), ShouldBeNil)
+		})
+		Convey("So an invalid mode should fall back to blocking", func() {
+			config[connectModeRuleKey] = ctypes.ConfigValueStr{Value: "lazy"}
+			So(getBackgroundConnect(config), ShouldBeNil)
+		})
+		Convey("So the background mode should buffer the configured number of metrics", func() {
+			config[connectModeRuleKey] = ctypes.ConfigValueStr{Value: connectBackground}
+			config[connectBufferSizeRuleKey] = ctypes.ConfigValueInt{Value: 5}
+			So(getBackgroundConnect(config).max, ShouldEqual, 5)
+		})
+	})
+}
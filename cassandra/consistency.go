@@ -0,0 +1,57 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"fmt"
+
+	"github.com/gocql/gocql"
+)
+
+// parseConsistencyRoutes parses routing rules whose targets are consistency
+// levels, e.g. "/sla/* -> QUORUM, /intel=ONE". The targets are kept in their
+// canonical spelling, so matching a route does not parse them again.
+func parseConsistencyRoutes(rules string) ([]route, error) {
+	routes, err := parseRoutes(rules)
+	if err != nil {
+		return nil, err
+	}
+	for i, r := range routes {
+		consistency, err := gocql.ParseConsistencyWrapper(r.target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid consistency '%s' of routing rule for '%s'", r.target, r.prefix)
+		}
+		routes[i].target = consistency.String()
+	}
+	return routes, nil
+}
+
+// consistencyOf returns the consistency the metrics row of the namespace is
+// written at, if a consistency route matches the namespace.
+func (cc *cassaClient) consistencyOf(ns string) (gocql.Consistency, bool) {
+	if len(cc.consistencyRoutes) == 0 {
+		return 0, false
+	}
+	target, ok := cc.consistencyCache.match(cc.consistencyRoutes, ns)
+	if !ok {
+		return 0, false
+	}
+	return gocql.ParseConsistency(target), true
+}
@@ -0,0 +1,73 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+
+	"github.com/gocql/gocql"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestConsistencyRoutes(t *testing.T) {
+	Convey("Given consistency routes", t, func() {
+		routes, err := parseConsistencyRoutes("/sla/* -> quorum, /intel=ONE, /intel/psutil/load -> LOCAL_QUORUM")
+		So(err, ShouldBeNil)
+		So(routes, ShouldHaveLength, 3)
+		So(routes[0], ShouldResemble, route{prefix: "/sla", target: "QUORUM"})
+
+		cc := &cassaClient{consistencyRoutes: routes, consistencyCache: newRouteCache(10)}
+
+		Convey("Namespaces are written at the consistency of their longest matching route", func() {
+			consistency, ok := cc.consistencyOf("/sla/latency/p99")
+			So(ok, ShouldBeTrue)
+			So(consistency, ShouldEqual, gocql.Quorum)
+			consistency, ok = cc.consistencyOf("/intel/psutil/load/load1")
+			So(ok, ShouldBeTrue)
+			So(consistency, ShouldEqual, gocql.LocalQuorum)
+			consistency, ok = cc.consistencyOf("/intel/psutil/cpu")
+			So(ok, ShouldBeTrue)
+			So(consistency, ShouldEqual, gocql.One)
+		})
+
+		Convey("Other namespaces keep the configured consistency", func() {
+			_, ok := cc.consistencyOf("/slack/messages")
+			So(ok, ShouldBeFalse)
+		})
+	})
+
+	Convey("Given no consistency routes", t, func() {
+		cc := &cassaClient{}
+
+		Convey("No namespace is overridden", func() {
+			_, ok := cc.consistencyOf("/sla/latency")
+			So(ok, ShouldBeFalse)
+		})
+	})
+
+	Convey("Given an invalid consistency", t, func() {
+		_, err := parseConsistencyRoutes("/sla=SOME")
+
+		Convey("The routes are rejected", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
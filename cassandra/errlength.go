@@ -0,0 +1,63 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// boundError returns err with its message cut to max bytes and summarized,
+// so snap does not keep long lists of joined errors in the task state. The
+// full message of a bounded error is logged. An OverloadedError keeps its
+// type and its own message, only the errors it holds are bounded. A max of
+// 0 or less keeps all errors as they are.
+func boundError(err error, max int, logger *log.Entry) error {
+	if err == nil || max <= 0 || len(err.Error()) <= max {
+		return err
+	}
+	logger.WithFields(log.Fields{
+		"err": err,
+	}).Error("publish error, the error returned to snap is shortened")
+
+	if oe, ok := err.(*OverloadedError); ok && oe.Err != nil {
+		bounded := *oe
+		bounded.Err = errors.New(summarizeError(oe.Err.Error(), max))
+		return &bounded
+	}
+	return errors.New(summarizeError(err.Error(), max))
+}
+
+// summarizeError returns the first max bytes of msg, cut at a character
+// boundary, and the number of errors joined into msg.
+func summarizeError(msg string, max int) string {
+	if len(msg) <= max {
+		return msg
+	}
+	for max > 0 && !utf8.RuneStart(msg[max]) {
+		max--
+	}
+	return fmt.Sprintf("%s... (%d errors, %d bytes, see the publisher log for all)",
+		msg[:max], strings.Count(msg, ";")+1, len(msg))
+}
@@ -0,0 +1,68 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBoundError(t *testing.T) {
+	logger := log.WithField("test", "boundError")
+	long := errors.New(strings.TrimSuffix(strings.Repeat("insert failed;", 100), ";"))
+
+	Convey("Given errors shorter than the maximum", t, func() {
+		err := errors.New("insert failed")
+		So(boundError(err, 100, logger), ShouldEqual, err)
+		So(boundError(nil, 100, logger), ShouldBeNil)
+	})
+
+	Convey("Given errors and no maximum", t, func() {
+		So(boundError(long, 0, logger), ShouldEqual, long)
+	})
+
+	Convey("Given errors longer than the maximum", t, func() {
+		err := boundError(long, 20, logger)
+
+		Convey("They are cut and summarized", func() {
+			So(err.Error(), ShouldEqual, "insert failed;insert... (100 errors, 1399 bytes, see the publisher log for all)")
+		})
+	})
+
+	Convey("Given an overloaded error holding long errors", t, func() {
+		err := boundError(&OverloadedError{Queue: dropSpoolFull, Dropped: 3, Err: long}, 20, logger)
+
+		Convey("It keeps its type and its own message", func() {
+			oe, ok := err.(*OverloadedError)
+			So(ok, ShouldBeTrue)
+			So(oe.Dropped, ShouldEqual, 3)
+			So(oe.Error(), ShouldStartWith, "Cassandra publisher temporarily overloaded, spoolFull queue full, 3 metrics dropped;insert failed;insert...")
+		})
+	})
+
+	Convey("Given a message with multi-byte characters", t, func() {
+		So(summarizeError("äää", 3), ShouldStartWith, "ä...")
+	})
+}
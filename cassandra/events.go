@@ -0,0 +1,58 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Cluster events the publisher can stop subscribing to.
+const (
+	eventStatus   = "status"
+	eventTopology = "topology"
+	eventSchema   = "schema"
+)
+
+// disabledEvents are the cluster events the session does not register for.
+type disabledEvents struct {
+	status   bool
+	topology bool
+	schema   bool
+}
+
+// parseDisabledEvents parses a comma separated list of cluster events.
+func parseDisabledEvents(events string) (disabledEvents, error) {
+	de := disabledEvents{}
+	for _, e := range strings.Split(events, ",") {
+		switch strings.TrimSpace(strings.ToLower(e)) {
+		case "":
+		case eventStatus:
+			de.status = true
+		case eventTopology:
+			de.topology = true
+		case eventSchema:
+			de.schema = true
+		default:
+			return disabledEvents{}, fmt.Errorf("unknown cluster event %q", e)
+		}
+	}
+	return de, nil
+}
@@ -0,0 +1,51 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseDisabledEvents(t *testing.T) {
+	Convey("Parse disabled cluster events", t, func() {
+		Convey("So no events should be disabled by default", func() {
+			de, err := parseDisabledEvents("")
+			So(err, ShouldBeNil)
+			So(de, ShouldResemble, disabledEvents{})
+		})
+		Convey("So listed events should be disabled", func() {
+			de, err := parseDisabledEvents("Schema, status")
+			So(err, ShouldBeNil)
+			So(de, ShouldResemble, disabledEvents{status: true, schema: true})
+
+			cluster := createCluster(clientOptions{server: "localhost", disabledEvents: de})
+			So(cluster.Events.DisableSchemaEvents, ShouldBeTrue)
+			So(cluster.Events.DisableNodeStatusEvents, ShouldBeTrue)
+			So(cluster.Events.DisableTopologyEvents, ShouldBeFalse)
+		})
+		Convey("So unknown events should be refused", func() {
+			_, err := parseDisabledEvents("status,nodes")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,157 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+// Dialects of external table definitions.
+const (
+	dialectSpark  = "spark"
+	dialectPresto = "presto"
+)
+
+// ErrTableTemplate is returned for external tables of a config with a table
+// template, whose columns are only known to Cassandra.
+var ErrTableTemplate = errors.New("external tables cannot be generated for a tableTemplate")
+
+// externalTable is a table the publisher writes, with its columns in the
+// order of the CREATE TABLE statement and the columns added later.
+type externalTable struct {
+	keyspace string
+	name     string
+	columns  []string
+}
+
+// ExternalTables returns the definitions of the tables the publisher writes
+// for the config in the dialect, spark for Spark SQL tables of the Spark
+// Cassandra connector or presto for Presto views over its Cassandra
+// connector, so analytics teams can query the metrics without
+// reverse-engineering the layout.
+func ExternalTables(config map[string]ctypes.ConfigValue, dialect string) (string, error) {
+	co, err := configClientOptions(config)
+	if err != nil {
+		return "", err
+	}
+	if co.tableTemplate != "" && !co.metadataOnly {
+		return "", ErrTableTemplate
+	}
+	switch dialect {
+	case dialectSpark:
+		return sparkTables(externalTables(co)), nil
+	case dialectPresto:
+		return prestoTables(co, externalTables(co)), nil
+	}
+	return "", fmt.Errorf("unknown dialect '%s', expected %s or %s", dialect, dialectSpark, dialectPresto)
+}
+
+// externalTables returns the tables written for the options.
+func externalTables(co clientOptions) []externalTable {
+	var extra []string
+	if co.versionTag != "" {
+		extra = append(extra, "appVer")
+	}
+	if co.int64Val {
+		extra = append(extra, "int64Val")
+	}
+	if co.varintVal {
+		extra = append(extra, "varintVal")
+	}
+	values := []string{"valType", "doubleVal", "strVal", "boolVal", "tags"}
+
+	var tables []externalTable
+	if !co.metadataOnly {
+		cols := []string{"ns", "ver", "host"}
+		if co.partitionBucket > 0 {
+			cols = append(cols, "bucket")
+		}
+		cols = append(append(append(cols, co.timeColumn), values...), extra...)
+		if co.checksum {
+			cols = append(cols, "checksum")
+		}
+		if co.ingestTime {
+			cols = append(cols, "ingestTime")
+		}
+		if co.outOfOrder == outOfOrderFlag {
+			cols = append(cols, "outOfOrder")
+		}
+		if co.counterResets == counterResetFlag {
+			cols = append(cols, "counterReset")
+		}
+		if co.staticColumns {
+			cols = append(cols, "unit", "hostTags")
+		}
+		cols = append(cols, nsColumnNames(co.nsColumns)...)
+		tables = append(tables, externalTable{co.keyspace, co.tableName, cols})
+	}
+	if co.tagsTableEnabled {
+		tagCols := append([]string{"key", "val", "time", "ns", "ver", "host"}, values...)
+		tables = append(tables, externalTable{co.tagsKeyspace, "tags", append(tagCols, extra...)})
+	}
+	if co.sharedTagSets && !co.metadataOnly {
+		tables = append(tables, externalTable{co.keyspace, "tagsets", []string{"id", "tags"}})
+	}
+	if co.boolTransitions && !co.metadataOnly {
+		tables = append(tables, externalTable{co.keyspace, "transitions", []string{"ns", "ver", "host", "time", "boolVal"}})
+	}
+	for i := range tables {
+		tables[i].keyspace = schemaIdentifier(tables[i].keyspace, co.preserveCase)
+		tables[i].name = schemaIdentifier(tables[i].name, co.preserveCase)
+		for j, col := range tables[i].columns {
+			// unquoted column names are folded to lowercase by Cassandra
+			tables[i].columns[j] = strings.ToLower(col)
+		}
+	}
+	return tables
+}
+
+// sparkTables returns Spark SQL tables of the Spark Cassandra connector,
+// which reads the columns from the Cassandra schema.
+func sparkTables(tables []externalTable) string {
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "-- Spark SQL tables of the Spark Cassandra connector, set spark.cassandra.connection.host")
+	for _, t := range tables {
+		fmt.Fprintf(&buf, "\n-- columns: %s\n", strings.Join(t.columns, ", "))
+		fmt.Fprintf(&buf, "CREATE TABLE IF NOT EXISTS %s_%s\n  USING org.apache.spark.sql.cassandra\n  OPTIONS (keyspace \"%s\", table \"%s\");\n",
+			t.keyspace, t.name, t.keyspace, t.name)
+	}
+	return buf.String()
+}
+
+// prestoTables returns the catalog of the Presto Cassandra connector and
+// views naming the columns of the tables.
+func prestoTables(co clientOptions, tables []externalTable) string {
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "-- etc/catalog/cassandra.properties:")
+	fmt.Fprintln(&buf, "--   connector.name=cassandra")
+	fmt.Fprintf(&buf, "--   cassandra.contact-points=%s\n", co.server)
+	fmt.Fprintf(&buf, "--   cassandra.native-protocol-port=%d\n", co.port)
+	for _, t := range tables {
+		fmt.Fprintf(&buf, "\nCREATE OR REPLACE VIEW %s_%s AS\n  SELECT %s\n  FROM cassandra.\"%s\".\"%s\";\n",
+			t.keyspace, t.name, strings.Join(t.columns, ", "), t.keyspace, t.name)
+	}
+	return buf.String()
+}
@@ -0,0 +1,70 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestExternalTables(t *testing.T) {
+	Convey("Generate external tables for a publisher config", t, func() {
+		Convey("So Spark tables should name the keyspace and the tables", func() {
+			config, err := LoadConfig(strings.NewReader(`{"server": "127.0.0.1", "checksum": true}`))
+			So(err, ShouldBeNil)
+			out, err := ExternalTables(config, "spark")
+			So(err, ShouldBeNil)
+			So(out, ShouldContainSubstring, `OPTIONS (keyspace "snap", table "metrics")`)
+			So(out, ShouldContainSubstring, `OPTIONS (keyspace "snap", table "tags")`)
+			So(out, ShouldContainSubstring, "valtype, doubleval, strval, boolval, tags, checksum")
+		})
+		Convey("So Presto views should select the columns of the tables", func() {
+			config, err := LoadConfig(strings.NewReader(`{"server": "127.0.0.1", "boolTransitions": true}`))
+			So(err, ShouldBeNil)
+			out, err := ExternalTables(config, "presto")
+			So(err, ShouldBeNil)
+			So(out, ShouldContainSubstring, "cassandra.contact-points=127.0.0.1")
+			So(out, ShouldContainSubstring, "SELECT ns, ver, host, time, boolval\n  FROM cassandra.\"snap\".\"transitions\"")
+		})
+		Convey("So metadataOnly should only define the tags table", func() {
+			config, err := LoadConfig(strings.NewReader(`{"server": "127.0.0.1", "metadataOnly": true}`))
+			So(err, ShouldBeNil)
+			out, err := ExternalTables(config, "spark")
+			So(err, ShouldBeNil)
+			So(out, ShouldNotContainSubstring, `table "metrics"`)
+			So(out, ShouldContainSubstring, `table "tags"`)
+		})
+		Convey("So a table template should be refused", func() {
+			config, err := LoadConfig(strings.NewReader(`{"server": "127.0.0.1", "tableTemplate": "CREATE TABLE IF NOT EXISTS {{.Keyspace}}.{{.Table}} (ns text PRIMARY KEY)"}`))
+			So(err, ShouldBeNil)
+			_, err = ExternalTables(config, "spark")
+			So(err, ShouldEqual, ErrTableTemplate)
+		})
+		Convey("So an unknown dialect should be refused", func() {
+			config, err := LoadConfig(strings.NewReader(`{"server": "127.0.0.1"}`))
+			So(err, ShouldBeNil)
+			_, err = ExternalTables(config, "hive")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
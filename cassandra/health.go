@@ -0,0 +1,199 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+	log "github.com/sirupsen/logrus"
+)
+
+// replacedSessions maps sessions rebuilt after they died onto their
+// replacement, so every client of a shared session switches to the new one.
+// A replaced session is forgotten once no client uses it anymore.
+var replacedSessions = map[*gocql.Session]*gocql.Session{}
+
+// sessionRefs counts the clients using each session.
+var sessionRefs = map[*gocql.Session]int{}
+
+// healthCheck tracks when the session of a client was probed last, so it is
+// probed at most once per interval before publishing.
+type healthCheck struct {
+	mu       sync.Mutex
+	interval time.Duration
+	last     time.Time
+}
+
+func newHealthCheck(interval time.Duration) *healthCheck {
+	if interval <= 0 {
+		return nil
+	}
+	return &healthCheck{interval: interval}
+}
+
+// due returns true and records the probe if the session was not probed
+// within the interval. A nil healthCheck is never due.
+func (h *healthCheck) due(now time.Time) bool {
+	if h == nil {
+		return false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.last.IsZero() && now.Sub(h.last) < h.interval {
+		return false
+	}
+	h.last = now
+	return true
+}
+
+// currentSession returns the session of the client, switching to the
+// replacement of a session rebuilt by another client sharing it.
+func (cc *cassaClient) currentSession() *gocql.Session {
+	cc.sessionMu.RLock()
+	session := cc.session
+	cc.sessionMu.RUnlock()
+	if session == nil || !session.Closed() {
+		return session
+	}
+
+	sessionsMu.Lock()
+	replacement := replacementOf(session)
+	sessionsMu.Unlock()
+	if replacement == session {
+		return session
+	}
+	cc.setSession(session, replacement)
+	return replacement
+}
+
+// setSession replaces the stale session of the client, unless it was
+// replaced concurrently.
+func (cc *cassaClient) setSession(stale, session *gocql.Session) {
+	cc.sessionMu.Lock()
+	defer cc.sessionMu.Unlock()
+	if cc.session != stale {
+		return
+	}
+	cc.session = session
+	sessionsMu.Lock()
+	releaseSession(stale)
+	sessionRefs[session]++
+	sessionsMu.Unlock()
+}
+
+// releaseSession drops a client of the session and forgets the replacement
+// of a replaced session no client uses anymore. sessionsMu must be held.
+func releaseSession(session *gocql.Session) {
+	if sessionRefs[session] > 1 {
+		sessionRefs[session]--
+		return
+	}
+	delete(sessionRefs, session)
+	delete(replacedSessions, session)
+}
+
+// replacementOf returns the latest replacement of the session, the session
+// itself if it was not replaced. sessionsMu must be held.
+func replacementOf(session *gocql.Session) *gocql.Session {
+	for {
+		replacement, ok := replacedSessions[session]
+		if !ok {
+			return session
+		}
+		session = replacement
+	}
+}
+
+// checkHealth probes the session of the client before publishing, at most
+// once per health check interval, and rebuilds it if the session was closed
+// or none of its hosts is available. A session which cannot be rebuilt is
+// kept, so the publish fails and the next publish tries again.
+func (cc *cassaClient) checkHealth() {
+	if !cc.health.due(time.Now()) {
+		return
+	}
+	session := cc.currentSession()
+	if !session.Closed() {
+		err := session.Query(validateConnectionCQL).Exec()
+		if err != gocql.ErrNoConnections {
+			if err != nil {
+				cassaLog.WithFields(log.Fields{
+					"err": err,
+				}).Warn("Cassandra client health probe error")
+			}
+			return
+		}
+	}
+
+	replacement, err := rebuildSession(cc.options, session)
+	if err != nil {
+		cassaLog.WithFields(log.Fields{
+			"err": err,
+		}).Error("Cassandra client session is unhealthy and could not be rebuilt")
+		return
+	}
+	cc.setSession(session, replacement)
+	cassaLog.WithFields(log.Fields{
+		"server": cc.options.server,
+	}).Warn("Cassandra client session was unhealthy and has been rebuilt")
+}
+
+// rebuildSession creates a new session replacing the stale one, also as the
+// shared session of the options if the stale one was shared, and closes the
+// stale session. A session replaced before returns its replacement. The new
+// session is connected without holding sessionsMu, so clients of other
+// sessions are not blocked meanwhile.
+func rebuildSession(co clientOptions, stale *gocql.Session) (*gocql.Session, error) {
+	sessionsMu.Lock()
+	replacement := replacementOf(stale)
+	sessionsMu.Unlock()
+	if replacement != stale {
+		return replacement, nil
+	}
+	session, err := getSession(co)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	// another client rebuilt the session meanwhile
+	if replacement := replacementOf(stale); replacement != stale {
+		session.Close()
+		return replacement, nil
+	}
+	for key, shared := range sessions {
+		if shared == stale {
+			sessions[key] = session
+		}
+	}
+	// sessions replaced by the stale one are replaced by the new one directly,
+	// so forgetting the stale one does not break their chain
+	for replaced, replacement := range replacedSessions {
+		if replacement == stale {
+			replacedSessions[replaced] = session
+		}
+	}
+	replacedSessions[stale] = session
+	stale.Close()
+	return session, nil
+}
@@ -0,0 +1,159 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHealthCheck(t *testing.T) {
+	Convey("Given a health check interval", t, func() {
+		h := newHealthCheck(30 * time.Second)
+		now := time.Now()
+
+		Convey("The first publish probes the session", func() {
+			So(h.due(now), ShouldBeTrue)
+
+			Convey("Publishes within the interval do not", func() {
+				So(h.due(now.Add(10*time.Second)), ShouldBeFalse)
+				So(h.due(now.Add(30*time.Second)), ShouldBeTrue)
+			})
+		})
+	})
+
+	Convey("Given no health check interval", t, func() {
+		h := newHealthCheck(0)
+
+		Convey("The session is never probed", func() {
+			So(h, ShouldBeNil)
+			So(h.due(time.Now()), ShouldBeFalse)
+		})
+	})
+}
+
+func TestCurrentSession(t *testing.T) {
+	Convey("Given a client whose shared session was rebuilt twice", t, func() {
+		stale, rebuilt, current := &gocql.Session{}, &gocql.Session{}, &gocql.Session{}
+		stale.Close()
+		rebuilt.Close()
+		sessionsMu.Lock()
+		replacedSessions[stale] = rebuilt
+		replacedSessions[rebuilt] = current
+		sessionsMu.Unlock()
+		defer func() {
+			sessionsMu.Lock()
+			delete(replacedSessions, stale)
+			delete(replacedSessions, rebuilt)
+			sessionsMu.Unlock()
+		}()
+		cc := &cassaClient{session: stale}
+
+		Convey("The client switches to the latest session", func() {
+			So(cc.currentSession(), ShouldEqual, current)
+			So(cc.session, ShouldEqual, current)
+		})
+	})
+
+	Convey("Given a client whose session was closed", t, func() {
+		closed := &gocql.Session{}
+		closed.Close()
+		cc := &cassaClient{session: closed}
+
+		Convey("The client keeps the closed session", func() {
+			So(cc.currentSession(), ShouldEqual, closed)
+		})
+	})
+}
+
+func TestReplacedSessions(t *testing.T) {
+	Convey("Given two clients of a shared session which was rebuilt", t, func() {
+		stale, current := &gocql.Session{}, &gocql.Session{}
+		stale.Close()
+		sessionsMu.Lock()
+		replacedSessions[stale] = current
+		sessionRefs[stale] = 2
+		sessionsMu.Unlock()
+		defer func() {
+			sessionsMu.Lock()
+			delete(replacedSessions, stale)
+			delete(sessionRefs, stale)
+			delete(sessionRefs, current)
+			sessionsMu.Unlock()
+		}()
+		first, second := &cassaClient{session: stale}, &cassaClient{session: stale}
+
+		Convey("The replacement is kept until the last client switched", func() {
+			So(first.currentSession(), ShouldEqual, current)
+			sessionsMu.Lock()
+			_, replaced := replacedSessions[stale]
+			sessionsMu.Unlock()
+			So(replaced, ShouldBeTrue)
+
+			So(second.currentSession(), ShouldEqual, current)
+			sessionsMu.Lock()
+			_, replaced = replacedSessions[stale]
+			refs := sessionRefs[current]
+			sessionsMu.Unlock()
+			So(replaced, ShouldBeFalse)
+			So(refs, ShouldEqual, 2)
+		})
+	})
+
+	Convey("Given a session being rebuilt", t, func() {
+		stale := &gocql.Session{}
+		stale.Close()
+		connecting, resume := make(chan struct{}), make(chan struct{})
+		co := defaultClientOptions()
+		co.server, co.port = "127.0.0.1", 1
+		co.clusterHook = func(*gocql.ClusterConfig) {
+			close(connecting)
+			<-resume
+		}
+		done := make(chan error, 1)
+		go func() {
+			_, err := rebuildSession(co, stale)
+			done <- err
+		}()
+
+		Convey("The shared sessions are not locked while it connects", func() {
+			<-connecting
+			locked := make(chan struct{})
+			go func() {
+				sessionsMu.Lock()
+				sessionsMu.Unlock()
+				close(locked)
+			}()
+			free := false
+			select {
+			case <-locked:
+				free = true
+			case <-time.After(time.Second):
+			}
+			close(resume)
+			So(<-done, ShouldNotBeNil)
+			So(free, ShouldBeTrue)
+		})
+	})
+}
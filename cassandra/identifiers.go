@@ -0,0 +1,65 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"strings"
+)
+
+// Cases of the keyspace and table names written into statements.
+const (
+	identifierLower    = "lower"
+	identifierPreserve = "preserve"
+)
+
+// cqlIdentifier returns a keyspace or table name as written into statements.
+// Names preserving their case are quoted, others are left unquoted and so
+// folded to lowercase by Cassandra.
+func cqlIdentifier(name string, preserve bool) string {
+	if !preserve {
+		return name
+	}
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// schemaIdentifier returns a keyspace or table name as stored in the schema
+// tables, to look it up in the schema metadata.
+func schemaIdentifier(name string, preserve bool) string {
+	if !preserve {
+		return strings.ToLower(name)
+	}
+	return name
+}
+
+// cqlNames are the names of the keyspaces and the metrics table of a client
+// as written into statements.
+type cqlNames struct {
+	keyspace     string
+	tagsKeyspace string
+	table        string
+}
+
+func newCQLNames(co clientOptions) cqlNames {
+	return cqlNames{
+		keyspace:     cqlIdentifier(co.keyspace, co.preserveCase),
+		tagsKeyspace: cqlIdentifier(co.tagsKeyspace, co.preserveCase),
+		table:        cqlIdentifier(co.tableName, co.preserveCase),
+	}
+}
@@ -0,0 +1,60 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIdentifiers(t *testing.T) {
+	Convey("Given lowercase identifiers", t, func() {
+		co := clientOptions{keyspace: "Snap", tagsKeyspace: "Snap", tableName: "Metrics", timeColumn: "time", timeColumnType: "timestamp"}
+
+		Convey("Names are written unquoted and looked up in lowercase", func() {
+			So(newCQLNames(co), ShouldResemble, cqlNames{keyspace: "Snap", tagsKeyspace: "Snap", table: "Metrics"})
+			So(schemaIdentifier("Metrics", false), ShouldEqual, "metrics")
+			So(metricsTableCQL(co), ShouldStartWith, "CREATE TABLE IF NOT EXISTS Snap.Metrics (")
+		})
+	})
+
+	Convey("Given identifiers preserving their case", t, func() {
+		co := clientOptions{keyspace: "Snap", tagsKeyspace: "Tags", tableName: "Metrics", preserveCase: true, timeColumn: "time", timeColumnType: "timestamp"}
+
+		Convey("Names are quoted and looked up as is", func() {
+			So(newCQLNames(co), ShouldResemble, cqlNames{keyspace: `"Snap"`, tagsKeyspace: `"Tags"`, table: `"Metrics"`})
+			So(schemaIdentifier("Metrics", true), ShouldEqual, "Metrics")
+			So(metricsTableCQL(co), ShouldStartWith, `CREATE TABLE IF NOT EXISTS "Snap"."Metrics" (`)
+			So(staticCQL(co), ShouldStartWith, `INSERT INTO "Snap"."Metrics" (`)
+		})
+
+		Convey("Inserts of a client use the quoted names", func() {
+			cc := newCassaClient(nil, clientOptions{keyspace: "Snap", tagsKeyspace: "Snap", tableName: "Metrics", preserveCase: true, readOnly: true}, "")
+			So(cc.names.table, ShouldEqual, `"Metrics"`)
+			So(cc.transitionStmt, ShouldStartWith, `INSERT INTO "Snap".transitions`)
+		})
+	})
+
+	Convey("Given a name containing quotes", t, func() {
+		So(cqlIdentifier(`a"b`, true), ShouldEqual, `"a""b"`)
+	})
+}
@@ -0,0 +1,85 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// validateConnectionCQL is a lightweight query validating a connection
+const validateConnectionCQL = "SELECT now() FROM system.local"
+
+// idleTracker tracks when a client was used last, so connections can be
+// validated after an idle period before they are written to again.
+type idleTracker struct {
+	mu      sync.Mutex
+	timeout time.Duration
+	last    time.Time
+}
+
+func newIdleTracker(timeout time.Duration) *idleTracker {
+	return &idleTracker{timeout: timeout}
+}
+
+// idle returns true if the client was used before but not within the timeout.
+// A nil idleTracker is never idle.
+func (t *idleTracker) idle(now time.Time) bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.timeout > 0 && !t.last.IsZero() && now.Sub(t.last) >= t.timeout
+}
+
+// touch records a use of the client.
+func (t *idleTracker) touch(now time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.last = now
+	t.mu.Unlock()
+}
+
+// validateIdleConnections runs a validation query when the client was idle,
+// so a stale connection is detected and replaced by gocql before the metrics
+// are written instead of failing the first write. gocql picks the connection
+// of the query, so only one connection of the pool is checked; the others
+// are kept alive by the TCP keepalive of the same period.
+func (cc *cassaClient) validateIdleConnections() {
+	now := time.Now()
+	defer cc.idle.touch(now)
+	if !cc.idle.idle(now) {
+		return
+	}
+
+	err := cc.retry.do(func() error {
+		return cc.currentSession().Query(validateConnectionCQL).Exec()
+	})
+	if err != nil {
+		cassaLog.WithFields(log.Fields{
+			"err": err,
+		}).Warn("Cassandra client connection validation error after an idle period")
+	}
+}
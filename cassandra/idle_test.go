@@ -0,0 +1,49 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIdleTracker(t *testing.T) {
+	Convey("Track idle periods of a client", t, func() {
+		now := time.Now()
+		tracker := newIdleTracker(time.Minute)
+
+		Convey("So an unused client should not be idle", func() {
+			So(tracker.idle(now), ShouldBeFalse)
+		})
+		Convey("So a client should be idle only after the timeout", func() {
+			tracker.touch(now)
+			So(tracker.idle(now.Add(30*time.Second)), ShouldBeFalse)
+			So(tracker.idle(now.Add(time.Minute)), ShouldBeTrue)
+		})
+		Convey("So a zero timeout should disable the validation", func() {
+			disabled := newIdleTracker(0)
+			disabled.touch(now)
+			So(disabled.idle(now.Add(time.Hour)), ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,88 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"fmt"
+
+	"github.com/gocql/gocql"
+	log "github.com/sirupsen/logrus"
+)
+
+// createTagMapIndexCQL indexes the entries of the tags map of a table, so
+// rows can be queried by tag, e.g. WHERE tags['experiment'] = '1'.
+const createTagMapIndexCQL = "CREATE INDEX IF NOT EXISTS %s ON %s.%s (ENTRIES(tags));"
+
+// createStrValIndexCQL creates a SASI index on the string values of a
+// table, so they can be searched with LIKE, in the mode of the index.
+const createStrValIndexCQL = "CREATE CUSTOM INDEX IF NOT EXISTS %s ON %s.%s (strVal) USING 'org.apache.cassandra.index.sasi.SASIIndex' WITH OPTIONS = {'mode': '%s'};"
+
+// Modes of the SASI index on the string values.
+const (
+	// strValIndexPrefix searches values by prefix, LIKE 'abc%'
+	strValIndexPrefix = "PREFIX"
+	// strValIndexContains searches values by substring, LIKE '%abc%'
+	strValIndexContains = "CONTAINS"
+)
+
+// indexName returns the name of the index of the column of a table.
+func indexName(table, column string, preserve bool) string {
+	return cqlIdentifier(table+"_"+column+"_idx", preserve)
+}
+
+// indexesCQL returns the statements creating the secondary indexes of the
+// metrics table configured by the options, for the version of the cluster.
+func indexesCQL(co clientOptions, version serverVersion) []string {
+	// tables of a template keep their layout
+	if co.tableTemplate != "" || co.metadataOnly {
+		return nil
+	}
+	names := newCQLNames(co)
+	var stmts []string
+	if co.createTagMapIndex {
+		if version.before(2, 2, 0) {
+			cassaLog.WithFields(log.Fields{
+				"version": version,
+			}).Warn("Cassandra version cannot index map entries, the tags map is not indexed")
+		} else {
+			stmts = append(stmts, fmt.Sprintf(createTagMapIndexCQL, indexName(co.tableName, "tags", co.preserveCase), names.keyspace, names.table))
+		}
+	}
+	if co.strValIndex != "" {
+		if version.before(3, 4, 0) {
+			cassaLog.WithFields(log.Fields{
+				"version": version,
+			}).Warn("Cassandra version has no SASI indexes, the string values are not indexed")
+		} else {
+			stmts = append(stmts, fmt.Sprintf(createStrValIndexCQL, indexName(co.tableName, "strVal", co.preserveCase), names.keyspace, names.table, co.strValIndex))
+		}
+	}
+	return stmts
+}
+
+// createIndexes creates the secondary indexes of the metrics table.
+func createIndexes(session *gocql.Session, co clientOptions, version serverVersion) error {
+	for _, stmt := range indexesCQL(co, version) {
+		if err := session.Query(stmt).Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+
+	"github.com/intelsdi-x/snap/core/ctypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIndexes(t *testing.T) {
+	Convey("Given options indexing the tags map", t, func() {
+		co := clientOptions{keyspace: "snap", tableName: "metrics", createTagMapIndex: true}
+
+		Convey("The entries of the tags map are indexed", func() {
+			So(indexesCQL(co, serverVersion{3, 11, 4}), ShouldResemble, []string{
+				"CREATE INDEX IF NOT EXISTS metrics_tags_idx ON snap.metrics (ENTRIES(tags));",
+			})
+			So(indexesCQL(co, serverVersion{}), ShouldHaveLength, 1)
+		})
+
+		Convey("Names preserving their case are quoted", func() {
+			co.tableName, co.preserveCase = "Metrics", true
+			So(indexesCQL(co, serverVersion{}), ShouldResemble, []string{
+				`CREATE INDEX IF NOT EXISTS "Metrics_tags_idx" ON "snap"."Metrics" (ENTRIES(tags));`,
+			})
+		})
+
+		Convey("Versions without entries indexes get none", func() {
+			So(indexesCQL(co, serverVersion{2, 1, 22}), ShouldBeEmpty)
+		})
+
+		Convey("Tables of a template or of metadata-only mode get none", func() {
+			co.metadataOnly = true
+			So(indexesCQL(co, serverVersion{}), ShouldBeEmpty)
+		})
+	})
+
+	Convey("Given options indexing the string values", t, func() {
+		co := clientOptions{keyspace: "snap", tableName: "metrics", strValIndex: strValIndexContains}
+
+		Convey("A SASI index in the mode is created", func() {
+			So(indexesCQL(co, serverVersion{3, 11, 4}), ShouldResemble, []string{
+				"CREATE CUSTOM INDEX IF NOT EXISTS metrics_strVal_idx ON snap.metrics (strVal) USING 'org.apache.cassandra.index.sasi.SASIIndex' WITH OPTIONS = {'mode': 'CONTAINS'};",
+			})
+		})
+
+		Convey("Versions without SASI get none", func() {
+			So(indexesCQL(co, serverVersion{3, 0, 15}), ShouldBeEmpty)
+		})
+
+		Convey("The mode is read case-insensitively and invalid modes create none", func() {
+			config := ruleDefaults()
+			config[serverAddrRuleKey] = ctypes.ConfigValueStr{Value: serverAddress}
+			config[strValIndexRuleKey] = ctypes.ConfigValueStr{Value: "contains"}
+			So(prepareClientOptions(config).strValIndex, ShouldEqual, strValIndexContains)
+			config[strValIndexRuleKey] = ctypes.ConfigValueStr{Value: "SPARSE"}
+			So(prepareClientOptions(config).strValIndex, ShouldBeEmpty)
+		})
+	})
+
+	Convey("Given options without indexes", t, func() {
+		So(indexesCQL(clientOptions{keyspace: "snap", tableName: "metrics"}, serverVersion{}), ShouldBeEmpty)
+	})
+}
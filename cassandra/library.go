@@ -0,0 +1,180 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+// ErrNoServer is returned by Connect for options without a server.
+var ErrNoServer = errors.New("Cassandra server address is required")
+
+// Options configures a Client. Zero values keep the defaults of the publisher
+// settings documented in the README.
+type Options struct {
+	// Server is the address of a Cassandra node, required
+	Server string
+	Port   int
+	// Keyspace and TableName name the keyspace and the table of the metrics
+	Keyspace  string
+	TableName string
+	// TagIndex are the tags indexed in the tags table
+	TagIndex []string
+	// Consistency is the consistency level of the writes, e.g. LOCAL_QUORUM
+	Consistency string
+	// TTL is the time after which written metrics expire, truncated to seconds
+	TTL time.Duration
+	// Settings sets any other publisher setting by its key, e.g. "batchSize": 10.
+	// Values must be bool, int or string.
+	Settings map[string]interface{}
+}
+
+// Metric is a sample written by a Client.
+type Metric struct {
+	Namespace []string
+	Version   int
+	Timestamp time.Time
+	// Value is a number, a string or a bool
+	Value interface{}
+	Tags  map[string]string
+	Unit  string
+}
+
+// Client writes metrics to Cassandra with the schema and the write logic of
+// the publisher, for Go programs running outside of snap.
+type Client struct {
+	cc *cassaClient
+}
+
+// Connect connects to the cluster and creates the schema, unless the
+// readOnly setting is set. The client options are applied after the options.
+// The client owns its session and must be closed.
+func Connect(opts Options, clientOpts ...ClientOption) (*Client, error) {
+	config, err := opts.config()
+	if err != nil {
+		return nil, err
+	}
+	tagIndex, ok := getValueForKey(config, tagIndexRuleKey).(string)
+	checkAssertion(ok, tagIndexRuleKey)
+	co, err := newClientOptions(append([]ClientOption{withClientOptions(prepareClientOptions(config))}, clientOpts...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := getSession(co)
+	if err != nil {
+		return nil, err
+	}
+	cc := newCassaClient(session, co, tagIndex)
+	if !cc.schema.isReady() {
+		_, err := cc.schema.status()
+		session.Close()
+		return nil, err
+	}
+	return &Client{cc: cc}, nil
+}
+
+// WriteMetrics writes the metrics and returns the errors of all metrics which
+// could not be written, joined into one.
+func (c *Client) WriteMetrics(metrics []Metric) error {
+	mts := make([]plugin.MetricType, len(metrics))
+	for i, m := range metrics {
+		mts[i] = m.metricType()
+	}
+	return c.cc.saveMetrics(mts, nil)
+}
+
+// Close closes the session of the client.
+func (c *Client) Close() error {
+	c.cc.currentSession().Close()
+	return nil
+}
+
+// config returns the publisher config of the options.
+func (opts Options) config() (map[string]ctypes.ConfigValue, error) {
+	if opts.Server == "" {
+		return nil, ErrNoServer
+	}
+	defaults := ruleDefaults()
+	config := ruleDefaults()
+	// settings of write profiles are only set if given, so profiles apply to the others
+	for key := range profileDefaults {
+		delete(config, key)
+	}
+	config[serverAddrRuleKey] = ctypes.ConfigValueStr{Value: opts.Server}
+	if opts.Port != 0 {
+		config[portRuleKey] = ctypes.ConfigValueInt{Value: opts.Port}
+	}
+	if opts.Keyspace != "" {
+		config[keyspaceNameRuleKey] = ctypes.ConfigValueStr{Value: opts.Keyspace}
+	}
+	if opts.TableName != "" {
+		config[tableNameRuleKey] = ctypes.ConfigValueStr{Value: opts.TableName}
+	}
+	if len(opts.TagIndex) > 0 {
+		config[tagIndexRuleKey] = ctypes.ConfigValueStr{Value: strings.Join(opts.TagIndex, ",")}
+	}
+	if opts.Consistency != "" {
+		config[consistencyRuleKey] = ctypes.ConfigValueStr{Value: opts.Consistency}
+	}
+	if opts.TTL > 0 {
+		config[ttlRuleKey] = ctypes.ConfigValueInt{Value: int(opts.TTL / time.Second)}
+	}
+
+	for key, value := range opts.Settings {
+		def, ok := defaults[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown setting %q", key)
+		}
+		var cv ctypes.ConfigValue
+		switch v := value.(type) {
+		case bool:
+			cv = ctypes.ConfigValueBool{Value: v}
+		case int:
+			cv = ctypes.ConfigValueInt{Value: v}
+		case string:
+			cv = ctypes.ConfigValueStr{Value: v}
+		}
+		if cv == nil || (def != nil && def.Type() != cv.Type()) {
+			return nil, fmt.Errorf("setting %q has the wrong type %T", key, value)
+		}
+		config[key] = cv
+	}
+	return config, nil
+}
+
+// metricType returns the snap metric written for m.
+func (m Metric) metricType() plugin.MetricType {
+	return plugin.MetricType{
+		Namespace_: core.NewNamespace(m.Namespace...),
+		Version_:   m.Version,
+		Timestamp_: m.Timestamp,
+		Data_:      m.Value,
+		Tags_:      m.Tags,
+		Unit_:      m.Unit,
+	}
+}
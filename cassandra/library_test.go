@@ -0,0 +1,71 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/core/ctypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLibraryOptions(t *testing.T) {
+	Convey("Build the publisher config of library options", t, func() {
+		Convey("So a server should be required", func() {
+			_, err := Options{}.config()
+			So(err, ShouldEqual, ErrNoServer)
+		})
+		Convey("So options should override the defaults", func() {
+			config, err := Options{
+				Server:   "cassandra",
+				Keyspace: "metrics",
+				TagIndex: []string{"dc", "rack"},
+				TTL:      90 * time.Minute,
+				Settings: map[string]interface{}{"batchSize": 10, "checksum": true},
+			}.config()
+			So(err, ShouldBeNil)
+			co := prepareClientOptions(config)
+			So(co.server, ShouldEqual, "cassandra")
+			So(co.port, ShouldEqual, 9042)
+			So(co.keyspace, ShouldEqual, "metrics")
+			So(co.ttl, ShouldEqual, 5400)
+			So(co.batchSize, ShouldEqual, 10)
+			So(co.checksum, ShouldBeTrue)
+			So(config[tagIndexRuleKey], ShouldResemble, ctypes.ConfigValueStr{Value: "dc,rack"})
+		})
+		Convey("So unknown settings and wrong types should be refused", func() {
+			_, err := Options{Server: "cassandra", Settings: map[string]interface{}{"nope": 1}}.config()
+			So(err, ShouldNotBeNil)
+			_, err = Options{Server: "cassandra", Settings: map[string]interface{}{"batchSize": "10"}}.config()
+			So(err, ShouldNotBeNil)
+		})
+	})
+	Convey("Convert library metrics", t, func() {
+		now := time.Now()
+		mt := Metric{Namespace: []string{"intel", "cpu"}, Version: 2, Timestamp: now, Value: 1.5, Unit: "%"}.metricType()
+		So(mt.Namespace().String(), ShouldEqual, "/intel/cpu")
+		So(mt.Version(), ShouldEqual, 2)
+		So(mt.Timestamp(), ShouldResemble, now)
+		So(mt.Data(), ShouldEqual, 1.5)
+		So(mt.Unit(), ShouldEqual, "%")
+	})
+}
@@ -0,0 +1,172 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core/ctypes"
+	log "github.com/sirupsen/logrus"
+)
+
+// lintPublishes is the number of publishes the runtime checks of a client
+// observe before they report.
+const lintPublishes = 10
+
+// LintConfig checks the config for dangerous combinations of settings and
+// returns a warning for each, without connecting to Cassandra. The checks
+// needing the published metrics are run by the clients at runtime.
+func LintConfig(config map[string]ctypes.ConfigValue) ([]string, error) {
+	co, err := configClientOptions(config)
+	if err != nil {
+		return nil, err
+	}
+	return lintOptions(co), nil
+}
+
+// lintOptions returns the warnings about the settings of the options.
+func lintOptions(co clientOptions) []string {
+	var warnings []string
+	if co.createKeyspace && minReplicationFactor(co.replication) == 1 {
+		for _, c := range lintConsistencies(co) {
+			if c == gocql.Quorum || c == gocql.LocalQuorum || c == gocql.EachQuorum || c == gocql.All {
+				warnings = append(warnings, fmt.Sprintf("consistency %s with a replication factor of 1 needs the only replica of a row, "+
+					"a single node down fails the writes: raise the replication factor or lower the consistency", c))
+				break
+			}
+		}
+	}
+	if co.batchSize > 1 && !co.batchByPartition {
+		warnings = append(warnings, fmt.Sprintf("batchSize %d sends batches spanning partitions, which burden their coordinator: "+
+			"set batchByPartition to batch the inserts of a partition only", co.batchSize))
+	}
+	return warnings
+}
+
+// minReplicationFactor returns the lowest replication factor of the replication options.
+func minReplicationFactor(r replicationOptions) int {
+	if r.strategy != replicationTopology {
+		return r.factor
+	}
+	min := 0
+	for _, factor := range r.dcs {
+		if min == 0 || factor < min {
+			min = factor
+		}
+	}
+	return min
+}
+
+// lintConsistencies returns the consistencies the options write at.
+func lintConsistencies(co clientOptions) []gocql.Consistency {
+	consistencies := []gocql.Consistency{co.consistency}
+	for _, r := range co.consistencyRoutes {
+		if c, err := gocql.ParseConsistencyWrapper(r.target); err == nil {
+			consistencies = append(consistencies, c)
+		}
+	}
+	return consistencies
+}
+
+// publishLint checks the settings of a client against its first publishes
+// and warns once about a TTL shorter than the interval of the publishes
+// and about indexed tags no metric carries. A nil lint checks nothing.
+type publishLint struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	unseen    map[string]bool
+	last      time.Time
+	minGap    time.Duration
+	publishes int
+}
+
+// newPublishLint returns the checks of a client writing rows of ttl seconds
+// and indexing the comma separated tags.
+func newPublishLint(ttl int, tagIndex string) *publishLint {
+	l := &publishLint{ttl: time.Duration(ttl) * time.Second, unseen: map[string]bool{}}
+	for _, tag := range strings.Split(tagIndex, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			l.unseen[tag] = true
+		}
+	}
+	return l
+}
+
+// observe checks a publish of the metrics at now and returns the warnings
+// once enough publishes have been observed.
+func (l *publishLint) observe(mts []plugin.MetricType, now time.Time) []string {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.publishes >= lintPublishes {
+		return nil
+	}
+	l.publishes++
+	if !l.last.IsZero() {
+		if gap := now.Sub(l.last); l.minGap == 0 || gap < l.minGap {
+			l.minGap = gap
+		}
+	}
+	l.last = now
+	for _, m := range mts {
+		if len(l.unseen) == 0 {
+			break
+		}
+		for tag := range m.Tags() {
+			delete(l.unseen, tag)
+		}
+	}
+	if l.publishes < lintPublishes {
+		return nil
+	}
+
+	var warnings []string
+	if l.ttl > 0 && l.minGap > l.ttl {
+		warnings = append(warnings, fmt.Sprintf("ttl of %v is shorter than the interval of %v between publishes, "+
+			"rows expire before the next sample of their series is written: raise the ttl", l.ttl, l.minGap))
+	}
+	if len(l.unseen) > 0 {
+		tags := make([]string, 0, len(l.unseen))
+		for tag := range l.unseen {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+		warnings = append(warnings, fmt.Sprintf("tagIndex keys %s were not seen in %d publishes, "+
+			"check their spelling or remove them", strings.Join(tags, ", "), lintPublishes))
+	}
+	return warnings
+}
+
+// lint logs the warnings of the runtime checks about a publish.
+func (cc *cassaClient) lint(mts []plugin.MetricType) {
+	for _, w := range cc.lints.observe(mts, time.Now()) {
+		cassaLog.WithFields(log.Fields{
+			"table": cc.tableName,
+		}).Warn(w)
+	}
+}
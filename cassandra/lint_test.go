@@ -0,0 +1,104 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLintConfig(t *testing.T) {
+	lint := func(js string) []string {
+		config, err := LoadConfig(strings.NewReader(js))
+		So(err, ShouldBeNil)
+		warnings, err := LintConfig(config)
+		So(err, ShouldBeNil)
+		return warnings
+	}
+
+	Convey("Lint publisher configs", t, func() {
+		Convey("So the defaults should not be warned about", func() {
+			So(lint(`{"server": "127.0.0.1"}`), ShouldBeEmpty)
+		})
+		Convey("So QUORUM with a replication factor of 1 should be warned about", func() {
+			warnings := lint(`{"server": "127.0.0.1", "consistency": "QUORUM"}`)
+			So(warnings, ShouldHaveLength, 1)
+			So(warnings[0], ShouldStartWith, "consistency QUORUM with a replication factor of 1")
+			So(lint(`{"server": "127.0.0.1", "consistency": "QUORUM", "replicationFactor": 3}`), ShouldBeEmpty)
+			So(lint(`{"server": "127.0.0.1", "consistency": "QUORUM", "createKeyspace": false}`), ShouldBeEmpty)
+		})
+		Convey("So consistency routes should be linted too", func() {
+			So(lint(`{"server": "127.0.0.1", "consistencyRoutes": "/intel/billing=LOCAL_QUORUM"}`), ShouldHaveLength, 1)
+		})
+		Convey("So batches spanning partitions should be warned about", func() {
+			warnings := lint(`{"server": "127.0.0.1", "batchSize": 50}`)
+			So(warnings, ShouldHaveLength, 1)
+			So(warnings[0], ShouldContainSubstring, "batchByPartition")
+			So(lint(`{"server": "127.0.0.1", "batchSize": 50, "batchByPartition": true}`), ShouldBeEmpty)
+		})
+		Convey("So an invalid config should be refused", func() {
+			config, err := LoadConfig(strings.NewReader(`{}`))
+			So(err, ShouldBeNil)
+			_, err = LintConfig(config)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestPublishLint(t *testing.T) {
+	Convey("Given the runtime checks of a client", t, func() {
+		l := newPublishLint(30, "experiment, scope")
+		mts := []plugin.MetricType{
+			*plugin.NewMetricType(core.NewNamespace("foo"), time.Now(), map[string]string{"experiment": "1"}, "", 1.0),
+		}
+		start := time.Now()
+
+		Convey("They report once after the first publishes", func() {
+			var warnings []string
+			for i := 0; i < lintPublishes; i++ {
+				So(warnings, ShouldBeEmpty)
+				warnings = l.observe(mts, start.Add(time.Duration(i)*time.Minute))
+			}
+			So(warnings, ShouldHaveLength, 2)
+			So(warnings[0], ShouldStartWith, "ttl of 30s is shorter than the interval of 1m0s")
+			So(warnings[1], ShouldStartWith, "tagIndex keys scope were not seen")
+			So(l.observe(mts, start.Add(time.Hour)), ShouldBeEmpty)
+		})
+
+		Convey("Publishes within the ttl are fine", func() {
+			var warnings []string
+			for i := 0; i < lintPublishes; i++ {
+				warnings = l.observe(mts, start.Add(time.Duration(i)*10*time.Second))
+			}
+			So(warnings, ShouldHaveLength, 1)
+		})
+	})
+
+	Convey("Given no runtime checks", t, func() {
+		var l *publishLint
+		So(l.observe(nil, time.Now()), ShouldBeEmpty)
+	})
+}
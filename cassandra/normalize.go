@@ -0,0 +1,112 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"bytes"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+)
+
+// normalizeMetric returns a copy of the metric whose namespace and tags are
+// safe to store in CQL text columns. The original metric is left untouched.
+func normalizeMetric(m plugin.MetricType) plugin.MetricType {
+	if isNormalizedMetric(m) {
+		return m
+	}
+	ns := make([]core.NamespaceElement, len(m.Namespace_))
+	for i, e := range m.Namespace_ {
+		e.Value = normalizeText(e.Value)
+		ns[i] = e
+	}
+	m.Namespace_ = ns
+	m.Tags_ = normalizeTags(m.Tags_)
+	return m
+}
+
+// isNormalizedMetric returns true when no namespace element and no tag of the
+// metric needs to be normalized, which is the case for almost every metric.
+func isNormalizedMetric(m plugin.MetricType) bool {
+	for _, e := range m.Namespace_ {
+		if !isNormalized(e.Value) {
+			return false
+		}
+	}
+	for k, v := range m.Tags_ {
+		if !isNormalized(k) || !isNormalized(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeTags returns the tags with normalized keys and values.
+func normalizeTags(tags map[string]string) map[string]string {
+	if tags == nil {
+		return nil
+	}
+	nt := make(map[string]string, len(tags))
+	for k, v := range tags {
+		nt[normalizeText(k)] = normalizeText(v)
+	}
+	return nt
+}
+
+// normalizeText replaces invalid UTF-8 sequences, which Cassandra rejects in
+// text columns, with U+FFFD and escapes control characters such as newlines
+// so that rows stay readable. Quotes and valid non-ASCII characters are kept
+// as they are, values are always bound and never formatted into CQL.
+func normalizeText(s string) string {
+	if isNormalized(s) {
+		return s
+	}
+	var buf bytes.Buffer
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		switch {
+		case r == utf8.RuneError && size == 1:
+			buf.WriteRune(utf8.RuneError)
+		case unicode.IsControl(r):
+			// QuoteRune gives the Go escape sequence wrapped in single quotes
+			q := strconv.QuoteRune(r)
+			buf.WriteString(q[1 : len(q)-1])
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	return buf.String()
+}
+
+func isNormalized(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		if unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
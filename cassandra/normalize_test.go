@@ -0,0 +1,71 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNormalizeText(t *testing.T) {
+	Convey("Normalize text stored in CQL text columns", t, func() {
+		Convey("So plain, quoted and unicode text should be kept as is", func() {
+			So(normalizeText("intel/psutil/load"), ShouldEqual, "intel/psutil/load")
+			So(normalizeText(`it's "quoted"`), ShouldEqual, `it's "quoted"`)
+			So(normalizeText("température/日本語"), ShouldEqual, "température/日本語")
+		})
+		Convey("So control characters should be escaped", func() {
+			So(normalizeText("line1\nline2"), ShouldEqual, `line1\nline2`)
+			So(normalizeText("a\tb\r"), ShouldEqual, `a\tb\r`)
+			So(normalizeText("nul\x00"), ShouldEqual, `nul\x00`)
+		})
+		Convey("So invalid UTF-8 should be replaced", func() {
+			So(normalizeText("bad\xffbyte"), ShouldEqual, "bad�byte")
+		})
+	})
+}
+
+func TestNormalizeMetric(t *testing.T) {
+	Convey("Normalize a metric", t, func() {
+		tags := map[string]string{"key\n": "val\xff"}
+		m := *plugin.NewMetricType(core.NewNamespace("foo", "b\nar"), time.Now(), tags, "", 1)
+		nm := normalizeMetric(m)
+
+		Convey("So namespace and tags should be normalized", func() {
+			So(nm.Namespace().Strings(), ShouldResemble, []string{"foo", `b\nar`})
+			So(nm.Tags(), ShouldResemble, map[string]string{`key\n`: "val�"})
+		})
+		Convey("So the original metric should be left untouched", func() {
+			So(m.Namespace().Strings(), ShouldResemble, []string{"foo", "b\nar"})
+			So(m.Tags(), ShouldResemble, tags)
+		})
+		Convey("So a valid metric should be kept as it is", func() {
+			valid := *plugin.NewMetricType(core.NewNamespace("foo", "bar"), time.Now(), map[string]string{"key": "val"}, "", 1)
+			So(isNormalizedMetric(valid), ShouldBeTrue)
+			So(isNormalizedMetric(m), ShouldBeFalse)
+			So(&normalizeMetric(valid).Namespace_[0], ShouldPointTo, &valid.Namespace_[0])
+		})
+	})
+}
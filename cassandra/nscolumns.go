@@ -0,0 +1,69 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"strconv"
+	"strings"
+)
+
+// nsRestColumn holds the namespace elements following the ones written into
+// the ns<i> columns.
+const nsRestColumn = "nsRest"
+
+// nsColumnNames returns the names of the columns the first n namespace
+// elements and the remainder are written into.
+func nsColumnNames(n int) []string {
+	if n <= 0 {
+		return nil
+	}
+	names := make([]string, 0, n+1)
+	for i := 0; i < n; i++ {
+		names = append(names, "ns"+strconv.Itoa(i))
+	}
+	return append(names, nsRestColumn)
+}
+
+// splitNamespace returns the first n elements of the namespace, empty for
+// the missing ones, followed by the remaining elements joined by the
+// separator of the namespace, which is its first character.
+func splitNamespace(ns string, n int) []string {
+	parts := make([]string, n+1)
+	if ns == "" {
+		return parts
+	}
+	sep := ns[:1]
+	elements := strings.SplitN(ns[1:], sep, n+1)
+	copy(parts, elements)
+	return parts
+}
+
+// nsColumns appends the columns holding the namespace elements of the point
+// to cols.
+func (cc *cassaClient) nsColumns(cols []column, p *point) []column {
+	if cc.nsColumnCount <= 0 {
+		return cols
+	}
+	values := splitNamespace(p.ns, cc.nsColumnCount)
+	for i, name := range nsColumnNames(cc.nsColumnCount) {
+		cols = append(cols, column{name, values[i]})
+	}
+	return cols
+}
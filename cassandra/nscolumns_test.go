@@ -0,0 +1,59 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNsColumns(t *testing.T) {
+	Convey("Given namespaces split into columns", t, func() {
+		Convey("The column names end with the remainder", func() {
+			So(nsColumnNames(0), ShouldBeNil)
+			So(nsColumnNames(2), ShouldResemble, []string{"ns0", "ns1", "nsRest"})
+		})
+
+		Convey("The leading elements get a column each", func() {
+			So(splitNamespace("/intel/psutil/load/load1", 2), ShouldResemble, []string{"intel", "psutil", "load/load1"})
+		})
+
+		Convey("Missing elements are empty", func() {
+			So(splitNamespace("/intel/psutil", 3), ShouldResemble, []string{"intel", "psutil", "", ""})
+			So(splitNamespace("", 1), ShouldResemble, []string{"", ""})
+		})
+
+		Convey("The separator of the namespace is kept", func() {
+			So(splitNamespace("|intel|disk|sda/1|ops", 2), ShouldResemble, []string{"intel", "disk", "sda/1|ops"})
+		})
+
+		Convey("The columns of a point are appended", func() {
+			cc := &cassaClient{nsColumnCount: 1}
+			p, err := newPoint(*plugin.NewMetricType(core.NewNamespace("intel", "psutil", "load"), time.Now(), nil, "", 1.0))
+			So(err, ShouldBeNil)
+			So(cc.nsColumns(nil, p), ShouldResemble, []column{{"ns0", "intel"}, {nsRestColumn, "psutil/load"}})
+		})
+	})
+}
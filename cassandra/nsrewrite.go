@@ -0,0 +1,117 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Namespace rewrite rule kinds.
+const (
+	nsRewriteStrip   = "strip"
+	nsRewriteSegment = "segment"
+	nsRewriteRegex   = "regex"
+)
+
+// nsRewrite is a transformation of the namespace written into the ns column.
+type nsRewrite struct {
+	kind string
+	from string
+	to   string
+	re   *regexp.Regexp
+}
+
+// nsRewrites are applied to a namespace in order, each to the result of the
+// previous one.
+type nsRewrites []nsRewrite
+
+// parseNsRewrites parses semicolon separated namespace rewrite rules:
+// "strip:/intel" strips a namespace prefix, "segment:psutil->ps" replaces a
+// namespace element and "regex:^/snap/(\w+)/(.*)$->/$2/$1" rewrites the
+// namespace with a regular expression, expanding $1 etc. in the replacement.
+func parseNsRewrites(rules string) (nsRewrites, error) {
+	var rewrites nsRewrites
+	for _, rule := range strings.Split(rules, ";") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		parts := strings.SplitN(rule, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid namespace rewrite '%s', expected kind:rule", rule)
+		}
+		r := nsRewrite{kind: strings.TrimSpace(parts[0])}
+		args := strings.TrimSpace(parts[1])
+		switch r.kind {
+		case nsRewriteStrip:
+			r.from = "/" + strings.Trim(args, "/")
+			if r.from == "/" {
+				return nil, fmt.Errorf("invalid namespace rewrite '%s', the prefix is required", rule)
+			}
+		case nsRewriteSegment, nsRewriteRegex:
+			fromTo := strings.SplitN(args, "->", 2)
+			if len(fromTo) != 2 || strings.TrimSpace(fromTo[0]) == "" {
+				return nil, fmt.Errorf("invalid namespace rewrite '%s', expected %s:from->to", rule, r.kind)
+			}
+			r.from, r.to = strings.TrimSpace(fromTo[0]), strings.TrimSpace(fromTo[1])
+			if r.kind == nsRewriteRegex {
+				re, err := regexp.Compile(r.from)
+				if err != nil {
+					return nil, fmt.Errorf("invalid namespace rewrite '%s': %v", rule, err)
+				}
+				r.re = re
+			}
+		default:
+			return nil, fmt.Errorf("invalid namespace rewrite '%s', unknown kind '%s'", rule, r.kind)
+		}
+		rewrites = append(rewrites, r)
+	}
+	return rewrites, nil
+}
+
+// apply returns the rewritten namespace. Rewrites leaving an empty
+// namespace are skipped, so every row keeps a namespace.
+func (rs nsRewrites) apply(ns string) string {
+	for _, r := range rs {
+		rewritten := ns
+		switch r.kind {
+		case nsRewriteStrip:
+			if strings.HasPrefix(ns, r.from+"/") {
+				rewritten = ns[len(r.from):]
+			}
+		case nsRewriteSegment:
+			elements := strings.Split(ns, "/")
+			for i, e := range elements {
+				if i > 0 && e == r.from {
+					elements[i] = r.to
+				}
+			}
+			rewritten = strings.Join(elements, "/")
+		case nsRewriteRegex:
+			rewritten = r.re.ReplaceAllString(ns, r.to)
+		}
+		if rewritten != "" && rewritten != "/" {
+			ns = rewritten
+		}
+	}
+	return ns
+}
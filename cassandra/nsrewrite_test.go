@@ -0,0 +1,68 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNsRewrites(t *testing.T) {
+	Convey("Given namespace rewrites", t, func() {
+		rewrites, err := parseNsRewrites(`strip:/intel/; segment:psutil->ps; regex:^/ps/cpu(\d+)/(.*)$->/ps/cpu/$2/$1`)
+		So(err, ShouldBeNil)
+		So(rewrites, ShouldHaveLength, 3)
+
+		Convey("They are applied in order", func() {
+			So(rewrites.apply("/intel/psutil/load/load1"), ShouldEqual, "/ps/load/load1")
+			So(rewrites.apply("/intel/psutil/cpu0/user"), ShouldEqual, "/ps/cpu/user/0")
+		})
+		Convey("Prefixes are only stripped at element boundaries", func() {
+			So(rewrites.apply("/intelligence/load"), ShouldEqual, "/intelligence/load")
+		})
+		Convey("Namespaces are never rewritten to empty ones", func() {
+			So(rewrites.apply("/intel"), ShouldEqual, "/intel")
+		})
+	})
+
+	Convey("Given no namespace rewrites", t, func() {
+		rewrites, err := parseNsRewrites("")
+		So(err, ShouldBeNil)
+
+		Convey("Namespaces are kept", func() {
+			So(rewrites.apply("/intel/psutil/load/load1"), ShouldEqual, "/intel/psutil/load/load1")
+		})
+	})
+
+	Convey("Given invalid namespace rewrites", t, func() {
+		for _, rules := range []string{
+			"strip:/",
+			"segment:psutil",
+			"regex:([->x",
+			"rename:a->b",
+			"/intel",
+		} {
+			_, err := parseNsRewrites(rules)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
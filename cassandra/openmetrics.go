@@ -0,0 +1,128 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// statsSample is the stats of a client as last written into a stats file.
+type statsSample struct {
+	statsSnapshot
+	dropped uint64
+}
+
+// statsFiles holds the latest stats of every client by the stats file they
+// are written to, as clients of routed clusters and tables share the file.
+var statsFiles = struct {
+	sync.Mutex
+	clients map[string]map[string]statsSample
+}{clients: map[string]map[string]statsSample{}}
+
+// labelEscaper escapes label values of the OpenMetrics text format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeStatsFile records the stats of the client and rewrites the stats file
+// with the stats of all its clients in the OpenMetrics text format, which the
+// textfile collector of the node_exporter reads. The file is replaced
+// atomically, so collectors never read a partial file.
+func writeStatsFile(path, client string, s statsSample) error {
+	statsFiles.Lock()
+	defer statsFiles.Unlock()
+	samples, ok := statsFiles.clients[path]
+	if !ok {
+		samples = map[string]statsSample{}
+		statsFiles.clients[path] = samples
+	}
+	samples[client] = s
+	return replaceStatsFile(path, samples)
+}
+
+// forgetStatsFile drops the stats of a closed client from the stats file, and
+// removes the file once it holds no client, so no stale stats are collected.
+func forgetStatsFile(path, client string) error {
+	statsFiles.Lock()
+	defer statsFiles.Unlock()
+	samples := statsFiles.clients[path]
+	delete(samples, client)
+	if len(samples) > 0 {
+		return replaceStatsFile(path, samples)
+	}
+	delete(statsFiles.clients, path)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func replaceStatsFile(path string, samples map[string]statsSample) error {
+	var buf bytes.Buffer
+	renderOpenMetrics(&buf, samples)
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// renderOpenMetrics writes the stats of the clients, labelled by client.
+func renderOpenMetrics(w io.Writer, samples map[string]statsSample) {
+	clients := make([]string, 0, len(samples))
+	for client := range samples {
+		clients = append(clients, client)
+	}
+	sort.Strings(clients)
+
+	counters := []struct {
+		name, help string
+		value      func(statsSample) uint64
+	}{
+		{"snap_publisher_cassandra_published_total", "Metrics written.", func(s statsSample) uint64 { return s.published }},
+		{"snap_publisher_cassandra_errors_total", "Metrics failing to be written.", func(s statsSample) uint64 { return s.errors }},
+		{"snap_publisher_cassandra_retries_total", "Retried inserts.", func(s statsSample) uint64 { return s.retries }},
+		{"snap_publisher_cassandra_dropped_total", "Metrics dropped instead of written.", func(s statsSample) uint64 { return s.dropped }},
+	}
+	for _, c := range counters {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+		for _, client := range clients {
+			fmt.Fprintf(w, "%s{client=\"%s\"} %d\n", c.name, labelEscaper.Replace(client), c.value(samples[client]))
+		}
+	}
+
+	const latency = "snap_publisher_cassandra_publish_latency_seconds"
+	fmt.Fprintf(w, "# HELP %s Latency percentiles of the recent publishes.\n# TYPE %s gauge\n", latency, latency)
+	for _, client := range clients {
+		s := samples[client]
+		for _, q := range []struct {
+			quantile string
+			value    float64
+		}{{"0.5", s.p50.Seconds()}, {"0.9", s.p90.Seconds()}, {"0.99", s.p99.Seconds()}} {
+			fmt.Fprintf(w, "%s{client=\"%s\",quantile=\"%s\"} %g\n", latency, labelEscaper.Replace(client), q.quantile, q.value)
+		}
+	}
+	fmt.Fprint(w, "# EOF\n")
+}
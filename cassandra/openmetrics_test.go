@@ -0,0 +1,82 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestOpenMetrics(t *testing.T) {
+	Convey("Given the stats of two clients", t, func() {
+		samples := map[string]statsSample{
+			"snap.metrics": {statsSnapshot{published: 10, errors: 1, retries: 2, p50: 5 * time.Millisecond, p90: 20 * time.Millisecond, p99: time.Second}, 3},
+			"snap.rollup":  {statsSnapshot{published: 7}, 0},
+		}
+
+		Convey("They are rendered in the OpenMetrics text format", func() {
+			var buf bytes.Buffer
+			renderOpenMetrics(&buf, samples)
+			out := buf.String()
+			So(out, ShouldContainSubstring, "# TYPE snap_publisher_cassandra_published_total counter\n"+
+				"snap_publisher_cassandra_published_total{client=\"snap.metrics\"} 10\n"+
+				"snap_publisher_cassandra_published_total{client=\"snap.rollup\"} 7\n")
+			So(out, ShouldContainSubstring, "snap_publisher_cassandra_dropped_total{client=\"snap.metrics\"} 3\n")
+			So(out, ShouldContainSubstring, "snap_publisher_cassandra_publish_latency_seconds{client=\"snap.metrics\",quantile=\"0.99\"} 1\n")
+			So(out, ShouldContainSubstring, "snap_publisher_cassandra_publish_latency_seconds{client=\"snap.metrics\",quantile=\"0.5\"} 0.005\n")
+			So(strings.HasSuffix(out, "# EOF\n"), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a stats file", t, func() {
+		dir, err := ioutil.TempDir("", "stats")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "cassandra.prom")
+
+		Convey("The stats of all clients writing it are kept", func() {
+			So(writeStatsFile(path, "snap.metrics", statsSample{statsSnapshot{published: 1}, 0}), ShouldBeNil)
+			So(writeStatsFile(path, "snap.rollup", statsSample{statsSnapshot{published: 2}, 0}), ShouldBeNil)
+			out, err := ioutil.ReadFile(path)
+			So(err, ShouldBeNil)
+			So(string(out), ShouldContainSubstring, `{client="snap.metrics"} 1`)
+			So(string(out), ShouldContainSubstring, `{client="snap.rollup"} 2`)
+
+			Convey("Closed clients are dropped and the file removed with the last", func() {
+				So(forgetStatsFile(path, "snap.metrics"), ShouldBeNil)
+				out, err := ioutil.ReadFile(path)
+				So(err, ShouldBeNil)
+				So(string(out), ShouldNotContainSubstring, "snap.metrics")
+
+				So(forgetStatsFile(path, "snap.rollup"), ShouldBeNil)
+				_, err = os.Stat(path)
+				So(os.IsNotExist(err), ShouldBeTrue)
+			})
+		})
+	})
+}
@@ -0,0 +1,207 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+// ClientOption configures a client created by NewCassaClient. Options are
+// applied in order, later options override earlier ones.
+type ClientOption func(*clientOptions) error
+
+// TLSConfig configures the encrypted connections of a client and the
+// password authentication sent over them.
+type TLSConfig struct {
+	Username string
+	Password string
+	// AuthorizationID is the DSE role to act as after authenticating
+	AuthorizationID  string
+	CertPath         string
+	KeyPath          string
+	CAPath           string
+	VerifyServerCert bool
+}
+
+// defaultClientOptions returns the options of the defaults of the config policy, without a server.
+func defaultClientOptions() clientOptions {
+	config := ruleDefaults()
+	config[serverAddrRuleKey] = ctypes.ConfigValueStr{Value: ""}
+	return prepareClientOptions(config)
+}
+
+// newClientOptions applies the options to the defaults and validates the result.
+func newClientOptions(opts ...ClientOption) (clientOptions, error) {
+	co := defaultClientOptions()
+	for _, opt := range opts {
+		if err := opt(&co); err != nil {
+			return clientOptions{}, err
+		}
+	}
+	return co, co.validate()
+}
+
+// validate checks the options not validated by the options setting them.
+func (co clientOptions) validate() error {
+	if co.server == "" {
+		return ErrNoServer
+	}
+	if co.port < 1 || co.port > 65535 {
+		return fmt.Errorf("invalid port %d", co.port)
+	}
+	return nil
+}
+
+// withClientOptions sets all options, e.g. the ones of a publisher config.
+func withClientOptions(options clientOptions) ClientOption {
+	return func(co *clientOptions) error {
+		*co = options
+		return nil
+	}
+}
+
+// WithServer sets the address and the port of a node of the cluster.
+func WithServer(server string, port int) ClientOption {
+	return func(co *clientOptions) error {
+		co.server = server
+		co.port = port
+		return nil
+	}
+}
+
+// WithKeyspace sets the keyspace of the metrics and the tags table.
+func WithKeyspace(keyspace string) ClientOption {
+	return func(co *clientOptions) error {
+		if !tableNamePattern.MatchString(keyspace) {
+			return fmt.Errorf("invalid keyspace name %q", keyspace)
+		}
+		co.keyspace = keyspace
+		co.tagsKeyspace = keyspace
+		co.insertTemplate = co.insertTemplate.forTable(keyspace, co.tableName)
+		return nil
+	}
+}
+
+// WithTable sets the metrics table.
+func WithTable(table string) ClientOption {
+	return func(co *clientOptions) error {
+		if !tableNamePattern.MatchString(table) {
+			return fmt.Errorf("invalid table name %q", table)
+		}
+		co.tableName = table
+		co.insertTemplate = co.insertTemplate.forTable(co.keyspace, table)
+		return nil
+	}
+}
+
+// WithConsistency sets the consistency level of the writes, e.g. LOCAL_QUORUM.
+func WithConsistency(level string) ClientOption {
+	return func(co *clientOptions) error {
+		consistency, err := gocql.ParseConsistencyWrapper(level)
+		if err != nil {
+			return err
+		}
+		co.consistency = consistency
+		return nil
+	}
+}
+
+// WithBatching sets the maximum number of inserts sent in one unlogged batch
+// and the number of workers writing the metrics of a publish.
+func WithBatching(batchSize, concurrency int) ClientOption {
+	return func(co *clientOptions) error {
+		if batchSize < 1 || concurrency < 1 {
+			return fmt.Errorf("invalid batching, batch size %d and concurrency %d must be positive", batchSize, concurrency)
+		}
+		co.batchSize = batchSize
+		co.writeConcurrency = concurrency
+		return nil
+	}
+}
+
+// WithRetry sets how often failed inserts are retried and the delay before
+// the first retry, doubled for every further one.
+func WithRetry(attempts int, delay, jitter time.Duration) ClientOption {
+	return func(co *clientOptions) error {
+		if attempts < 0 || delay < 0 || jitter < 0 {
+			return fmt.Errorf("invalid retry, attempts %d, delay %v and jitter %v must not be negative", attempts, delay, jitter)
+		}
+		co.retry.attempts = attempts
+		co.retry.delay = delay
+		co.retry.jitter = jitter
+		return nil
+	}
+}
+
+// WithTimeouts sets the query and the initial connection timeout.
+func WithTimeouts(timeout, connect time.Duration) ClientOption {
+	return func(co *clientOptions) error {
+		if timeout <= 0 || connect <= 0 {
+			return fmt.Errorf("invalid timeouts %v and %v, must be positive", timeout, connect)
+		}
+		co.timeout = timeout
+		co.connectionTimeout = connect
+		return nil
+	}
+}
+
+// WithTTL sets the time after which rows of the metrics and the tags table
+// expire, truncated to seconds. 0 disables the expiry.
+func WithTTL(ttl time.Duration) ClientOption {
+	return func(co *clientOptions) error {
+		if ttl < 0 {
+			return fmt.Errorf("invalid TTL %v", ttl)
+		}
+		co.ttl = int(ttl / time.Second)
+		co.tagsTTL = co.ttl
+		return nil
+	}
+}
+
+// WithClusterHook sets a function changing the gocql cluster config after the
+// options were applied and before the session is created, so driver features
+// without an option remain reachable, e.g. a custom retry policy.
+func WithClusterHook(hook func(*gocql.ClusterConfig)) ClientOption {
+	return func(co *clientOptions) error {
+		co.clusterHook = hook
+		return nil
+	}
+}
+
+// WithTLS encrypts the connections and authenticates with the username and
+// the password, if both are set.
+func WithTLS(c TLSConfig) ClientOption {
+	return func(co *clientOptions) error {
+		co.ssl = &sslOptions{
+			username:                     c.Username,
+			password:                     c.Password,
+			authorizationID:              c.AuthorizationID,
+			certPath:                     c.CertPath,
+			keyPath:                      c.KeyPath,
+			caPath:                       c.CAPath,
+			enableServerCertVerification: c.VerifyServerCert,
+		}
+		return nil
+	}
+}
@@ -0,0 +1,130 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestClientOptions(t *testing.T) {
+	Convey("Configure clients with functional options", t, func() {
+		Convey("So options should be applied to the defaults", func() {
+			co, err := newClientOptions(
+				WithServer("10.0.0.1", 9043),
+				WithKeyspace("metrics"),
+				WithConsistency("LOCAL_QUORUM"),
+				WithBatching(20, 4),
+				WithRetry(3, time.Second, 0),
+				WithTTL(time.Hour),
+				WithTLS(TLSConfig{Username: "snap", Password: "secret", VerifyServerCert: true}),
+			)
+			So(err, ShouldBeNil)
+			So(co.server, ShouldEqual, "10.0.0.1")
+			So(co.port, ShouldEqual, 9043)
+			So(co.keyspace, ShouldEqual, "metrics")
+			So(co.tagsKeyspace, ShouldEqual, "metrics")
+			So(co.tableName, ShouldEqual, "metrics")
+			So(co.consistency, ShouldEqual, gocql.LocalQuorum)
+			So(co.batchSize, ShouldEqual, 20)
+			So(co.writeConcurrency, ShouldEqual, 4)
+			So(co.retry.attempts, ShouldEqual, 3)
+			So(co.ttl, ShouldEqual, 3600)
+			So(co.ssl.username, ShouldEqual, "snap")
+			So(co.ssl.enableServerCertVerification, ShouldBeTrue)
+		})
+		Convey("So later options should override earlier ones", func() {
+			base, err := newClientOptions(WithServer("10.0.0.1", 9042))
+			So(err, ShouldBeNil)
+			co, err := newClientOptions(withClientOptions(base), WithServer("10.0.0.2", 9042), WithTable("psutil"))
+			So(err, ShouldBeNil)
+			So(co.server, ShouldEqual, "10.0.0.2")
+			So(co.tableName, ShouldEqual, "psutil")
+		})
+		Convey("So invalid options should be refused", func() {
+			for _, opt := range []ClientOption{
+				WithConsistency("MOST"),
+				WithBatching(0, 1),
+				WithRetry(-1, 0, 0),
+				WithTimeouts(0, time.Second),
+				WithTTL(-time.Second),
+				WithTable("my-table"),
+			} {
+				_, err := newClientOptions(WithServer("10.0.0.1", 9042), opt)
+				So(err, ShouldNotBeNil)
+			}
+		})
+		Convey("So a server should be required", func() {
+			_, err := newClientOptions()
+			So(err, ShouldEqual, ErrNoServer)
+			_, err = newClientOptions(WithServer("10.0.0.1", 0))
+			So(err, ShouldNotBeNil)
+		})
+		Convey("So the cluster hook should change the cluster config last", func() {
+			co, err := newClientOptions(
+				WithServer("10.0.0.1", 9042),
+				WithConsistency("QUORUM"),
+				WithClusterHook(func(cluster *gocql.ClusterConfig) {
+					cluster.Consistency = gocql.LocalOne
+					cluster.NumConns = 4
+				}))
+			So(err, ShouldBeNil)
+			cluster := createCluster(co)
+			So(cluster.Consistency, ShouldEqual, gocql.LocalOne)
+			So(cluster.NumConns, ShouldEqual, 4)
+
+			Convey("So clients with hooks of one function literal should not share sessions", func() {
+				hookFor := func(conns int) func(*gocql.ClusterConfig) {
+					return func(cluster *gocql.ClusterConfig) {
+						cluster.NumConns = conns
+					}
+				}
+				co.server, co.port, co.clusterHook = "127.0.0.1", 1, hookFor(1)
+				other := co
+				other.clusterHook = hookFor(4)
+				So(sessionKey(other), ShouldEqual, sessionKey(co))
+
+				shared := &gocql.Session{}
+				sessionsMu.Lock()
+				sessions[sessionKey(co)] = shared
+				sessionsMu.Unlock()
+				defer func() {
+					sessionsMu.Lock()
+					delete(sessions, sessionKey(co))
+					delete(sessionRefs, shared)
+					sessionsMu.Unlock()
+				}()
+				// the hooked client connects a session of its own, failing on the closed port
+				session, err := getSharedSession(other)
+				So(err, ShouldNotBeNil)
+				So(session, ShouldNotEqual, shared)
+
+				other.clusterHook = nil
+				session, err = getSharedSession(other)
+				So(err, ShouldBeNil)
+				So(session, ShouldEqual, shared)
+			})
+		})
+	})
+}
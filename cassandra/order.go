@@ -0,0 +1,70 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"sync"
+	"time"
+)
+
+// Out-of-order detection modes.
+const (
+	outOfOrderReport = "report"
+	outOfOrderFlag   = "flag"
+)
+
+// orderTracker remembers the latest timestamp of every series to detect
+// samples arriving out of order, e.g. from collectors with skewed clocks.
+type orderTracker struct {
+	mu     sync.Mutex
+	latest map[string]time.Time
+	late   uint64
+}
+
+func newOrderTracker() *orderTracker {
+	return &orderTracker{latest: map[string]time.Time{}}
+}
+
+// outOfOrder records the timestamp of a sample of the series and reports
+// whether it is older than the latest sample seen. A nil tracker detects nothing.
+func (t *orderTracker) outOfOrder(series string, ts time.Time) bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	latest, ok := t.latest[series]
+	if ok && ts.Before(latest) {
+		t.late++
+		return true
+	}
+	t.latest[series] = ts
+	return false
+}
+
+// total returns the number of out-of-order samples seen so far.
+func (t *orderTracker) total() uint64 {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.late
+}
@@ -0,0 +1,57 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestOrderTracker(t *testing.T) {
+	Convey("Detect samples arriving out of order", t, func() {
+		tracker := newOrderTracker()
+		now := time.Now()
+		So(tracker.outOfOrder("a", now), ShouldBeFalse)
+		So(tracker.outOfOrder("a", now.Add(time.Second)), ShouldBeFalse)
+
+		Convey("So older samples should be counted", func() {
+			So(tracker.outOfOrder("a", now), ShouldBeTrue)
+			So(tracker.total(), ShouldEqual, 1)
+			Convey("So they should not move the latest timestamp back", func() {
+				So(tracker.outOfOrder("a", now.Add(500*time.Millisecond)), ShouldBeTrue)
+				So(tracker.total(), ShouldEqual, 2)
+			})
+		})
+		Convey("So series should be tracked apart", func() {
+			So(tracker.outOfOrder("b", now), ShouldBeFalse)
+		})
+		Convey("So samples with the same timestamp should be in order", func() {
+			So(tracker.outOfOrder("a", now.Add(time.Second)), ShouldBeFalse)
+		})
+		Convey("So a nil tracker should detect nothing", func() {
+			var disabled *orderTracker
+			So(disabled.outOfOrder("a", now), ShouldBeFalse)
+			So(disabled.total(), ShouldEqual, 0)
+		})
+	})
+}
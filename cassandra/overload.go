@@ -0,0 +1,50 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"fmt"
+)
+
+// OverloadedError is returned when metrics are dropped because an internal
+// queue of the publisher is full. Unlike other errors it is temporary, the
+// publisher is expected to accept metrics again once the queue drains, so
+// callers can back off and retry instead of treating it as a permanent failure.
+type OverloadedError struct {
+	// Queue is the full queue, the drop reason of the metrics
+	Queue string
+	// Dropped is the number of metrics dropped
+	Dropped int
+	// Err holds the errors of the publish besides the overload, if any
+	Err error
+}
+
+func (e *OverloadedError) Error() string {
+	msg := fmt.Sprintf("Cassandra publisher temporarily overloaded, %s queue full, %d metrics dropped", e.Queue, e.Dropped)
+	if e.Err != nil {
+		msg += ";" + e.Err.Error()
+	}
+	return msg
+}
+
+// Temporary reports that the publish may succeed when retried later.
+func (e *OverloadedError) Temporary() bool {
+	return true
+}
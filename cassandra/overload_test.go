@@ -0,0 +1,51 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestOverloadedError(t *testing.T) {
+	Convey("Report full queues as temporary overloads", t, func() {
+		err := &OverloadedError{Queue: dropSpoolFull, Dropped: 3, Err: errors.New("timeout")}
+		So(err.Temporary(), ShouldBeTrue)
+		So(err.Error(), ShouldEqual, "Cassandra publisher temporarily overloaded, spoolFull queue full, 3 metrics dropped;timeout")
+
+		Convey("So metrics evicted from the schema pending buffer should overload the client", func() {
+			cc := &cassaClient{schema: newSchemaState(1), drops: newDropCounters()}
+			mts := []plugin.MetricType{
+				*plugin.NewMetricType(core.NewNamespace("foo"), time.Now(), nil, "", 1),
+				*plugin.NewMetricType(core.NewNamespace("foo"), time.Now(), nil, "", 2),
+			}
+			err := cc.saveMetrics(mts, nil)
+			So(err, ShouldHaveSameTypeAs, &OverloadedError{})
+			So(err.(*OverloadedError).Dropped, ShouldEqual, 1)
+			So(err.(*OverloadedError).Queue, ShouldEqual, dropSchemaPending)
+		})
+	})
+}
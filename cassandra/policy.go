@@ -0,0 +1,130 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/gocql/gocql"
+)
+
+// dcAwareHostPolicy is a round-robin host selection policy preferring the
+// hosts of the local data center, hosts of other data centers are only tried
+// after all local hosts. The vendored gocql has no such policy.
+type dcAwareHostPolicy struct {
+	localDC string
+	pos     uint32
+
+	// local and remote are copied on write, so picks iterate them unlocked
+	mu     sync.RWMutex
+	local  []*gocql.HostInfo
+	remote []*gocql.HostInfo
+}
+
+func newDCAwareHostPolicy(localDC string) *dcAwareHostPolicy {
+	return &dcAwareHostPolicy{localDC: localDC}
+}
+
+// SetPartitioner is a noop, hosts are not selected by token.
+func (p *dcAwareHostPolicy) SetPartitioner(partitioner string) {}
+
+func (p *dcAwareHostPolicy) AddHost(host *gocql.HostInfo) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if host.DataCenter() == p.localDC {
+		p.local = withHost(p.local, host)
+	} else {
+		p.remote = withHost(p.remote, host)
+	}
+}
+
+func (p *dcAwareHostPolicy) RemoveHost(host *gocql.HostInfo) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.local = withoutHost(p.local, host)
+	p.remote = withoutHost(p.remote, host)
+}
+
+func (p *dcAwareHostPolicy) HostUp(host *gocql.HostInfo) {
+	p.AddHost(host)
+}
+
+func (p *dcAwareHostPolicy) HostDown(host *gocql.HostInfo) {
+	p.RemoveHost(host)
+}
+
+// Pick returns the local hosts followed by the remote hosts, both starting
+// at the next position of the round-robin.
+func (p *dcAwareHostPolicy) Pick(qry gocql.ExecutableQuery) gocql.NextHost {
+	p.mu.RLock()
+	local, remote := p.local, p.remote
+	p.mu.RUnlock()
+
+	start := atomic.AddUint32(&p.pos, 1) - 1
+	i := 0
+	return func() gocql.SelectedHost {
+		var hosts []*gocql.HostInfo
+		j := i
+		switch {
+		case i < len(local):
+			hosts = local
+		case i < len(local)+len(remote):
+			hosts = remote
+			j -= len(local)
+		default:
+			return nil
+		}
+		i++
+		return selectedHost{hosts[(start+uint32(j))%uint32(len(hosts))]}
+	}
+}
+
+// selectedHost is a host picked by dcAwareHostPolicy.
+type selectedHost struct {
+	info *gocql.HostInfo
+}
+
+func (h selectedHost) Info() *gocql.HostInfo {
+	return h.info
+}
+
+func (h selectedHost) Mark(err error) {}
+
+// withHost returns a copy of hosts including host.
+func withHost(hosts []*gocql.HostInfo, host *gocql.HostInfo) []*gocql.HostInfo {
+	for _, h := range hosts {
+		if h.Peer().Equal(host.Peer()) {
+			return hosts
+		}
+	}
+	return append(hosts[:len(hosts):len(hosts)], host)
+}
+
+// withoutHost returns a copy of hosts without host.
+func withoutHost(hosts []*gocql.HostInfo, host *gocql.HostInfo) []*gocql.HostInfo {
+	kept := make([]*gocql.HostInfo, 0, len(hosts))
+	for _, h := range hosts {
+		if !h.Peer().Equal(host.Peer()) {
+			kept = append(kept, h)
+		}
+	}
+	return kept
+}
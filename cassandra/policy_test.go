@@ -0,0 +1,69 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+
+	"github.com/gocql/gocql"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDCAwareHostPolicy(t *testing.T) {
+	Convey("Create a DC-aware host policy", t, func() {
+		p := newDCAwareHostPolicy("dc1")
+
+		Convey("So nothing should be picked without hosts", func() {
+			So(p.Pick(nil)(), ShouldBeNil)
+		})
+		Convey("So hosts of other data centers should be picked as remote hosts", func() {
+			// hosts without host lookup have no data center
+			host := &gocql.HostInfo{}
+			p.AddHost(host)
+			So(p.local, ShouldBeEmpty)
+			So(p.remote, ShouldHaveLength, 1)
+
+			next := p.Pick(nil)
+			So(next().Info(), ShouldPointTo, host)
+			So(next(), ShouldBeNil)
+
+			Convey("So hosts should only be added once", func() {
+				p.HostUp(host)
+				So(p.remote, ShouldHaveLength, 1)
+			})
+			Convey("So hosts going down should not be picked anymore", func() {
+				p.HostDown(host)
+				So(p.remote, ShouldBeEmpty)
+				So(p.Pick(nil)(), ShouldBeNil)
+			})
+		})
+		Convey("So the policy should be set on the cluster with a local data center", func() {
+			So(createCluster(clientOptions{}).PoolConfig.HostSelectionPolicy, ShouldBeNil)
+			cluster := createCluster(clientOptions{localDC: "dc1"})
+			So(cluster.PoolConfig.HostSelectionPolicy, ShouldHaveSameTypeAs, p)
+		})
+		Convey("So token-aware routing should wrap the selected policy", func() {
+			tokenAware := gocql.TokenAwareHostPolicy(gocql.RoundRobinHostPolicy())
+			So(hostSelectionPolicy(clientOptions{tokenAware: true}), ShouldHaveSameTypeAs, tokenAware)
+			So(hostSelectionPolicy(clientOptions{tokenAware: true, localDC: "dc1"}), ShouldHaveSameTypeAs, tokenAware)
+		})
+	})
+}
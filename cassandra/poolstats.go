@@ -0,0 +1,175 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+	log "github.com/sirupsen/logrus"
+)
+
+// poolStats wraps the host selection policy of a session to track the state
+// of the hosts and the requests in flight to them, which gocql does not expose.
+type poolStats struct {
+	gocql.HostSelectionPolicy
+
+	mu    sync.Mutex
+	hosts map[string]*hostStats
+}
+
+// hostStats is the state of a host of the cluster.
+type hostStats struct {
+	host     string
+	dc       string
+	up       bool
+	inFlight int
+}
+
+// newPoolStats tracks the hosts picked by policy, nil tracks the round-robin
+// default of gocql.
+func newPoolStats(policy gocql.HostSelectionPolicy) *poolStats {
+	if policy == nil {
+		policy = gocql.RoundRobinHostPolicy()
+	}
+	return &poolStats{HostSelectionPolicy: policy, hosts: map[string]*hostStats{}}
+}
+
+// stats returns the stats of the host, adding it if it is new. Callers hold mu.
+func (p *poolStats) stats(host *gocql.HostInfo) *hostStats {
+	key := host.Peer().String()
+	s, ok := p.hosts[key]
+	if !ok {
+		s = &hostStats{host: key, dc: host.DataCenter(), up: host.IsUp()}
+		p.hosts[key] = s
+	}
+	return s
+}
+
+func (p *poolStats) setUp(host *gocql.HostInfo, up bool) {
+	p.mu.Lock()
+	p.stats(host).up = up
+	p.mu.Unlock()
+}
+
+func (p *poolStats) AddHost(host *gocql.HostInfo) {
+	p.setUp(host, host.IsUp())
+	p.HostSelectionPolicy.AddHost(host)
+}
+
+func (p *poolStats) RemoveHost(host *gocql.HostInfo) {
+	p.mu.Lock()
+	delete(p.hosts, host.Peer().String())
+	p.mu.Unlock()
+	p.HostSelectionPolicy.RemoveHost(host)
+}
+
+func (p *poolStats) HostUp(host *gocql.HostInfo) {
+	p.setUp(host, true)
+	p.HostSelectionPolicy.HostUp(host)
+}
+
+func (p *poolStats) HostDown(host *gocql.HostInfo) {
+	p.setUp(host, false)
+	p.HostSelectionPolicy.HostDown(host)
+}
+
+// Pick counts a request in flight to a picked host until the result of the
+// request is marked or, if gocql skips the host, the next host is picked.
+func (p *poolStats) Pick(q gocql.ExecutableQuery) gocql.NextHost {
+	next := p.HostSelectionPolicy.Pick(q)
+	var pending *trackedHost
+	return func() gocql.SelectedHost {
+		pending.release()
+		pending = nil
+		host := next()
+		if host == nil || host.Info() == nil {
+			return host
+		}
+		p.mu.Lock()
+		s := p.stats(host.Info())
+		s.inFlight++
+		p.mu.Unlock()
+		pending = &trackedHost{SelectedHost: host, pool: p, stats: s}
+		return pending
+	}
+}
+
+// snapshot returns a copy of the stats of all hosts, ordered by host.
+func (p *poolStats) snapshot() []hostStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	keys := make([]string, 0, len(p.hosts))
+	for key := range p.hosts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	hosts := make([]hostStats, 0, len(keys))
+	for _, key := range keys {
+		hosts = append(hosts, *p.hosts[key])
+	}
+	return hosts
+}
+
+// logEvery logs the stats at debug level every interval until the session is closed.
+func (p *poolStats) logEvery(session *gocql.Session, server string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if session.Closed() {
+			return
+		}
+		for _, s := range p.snapshot() {
+			cassaLog.WithFields(log.Fields{
+				"server":    server,
+				"host":      s.host,
+				"dc":        s.dc,
+				"up":        s.up,
+				"in-flight": s.inFlight,
+			}).Debug("Cassandra connection pool stats")
+		}
+	}
+}
+
+// trackedHost is a picked host whose request is counted in flight.
+type trackedHost struct {
+	gocql.SelectedHost
+	pool     *poolStats
+	stats    *hostStats
+	released bool
+}
+
+func (h *trackedHost) Mark(err error) {
+	h.release()
+	h.SelectedHost.Mark(err)
+}
+
+// release stops counting the request in flight, once.
+func (h *trackedHost) release() {
+	if h == nil || h.released {
+		return
+	}
+	h.released = true
+	h.pool.mu.Lock()
+	h.stats.inFlight--
+	h.pool.mu.Unlock()
+}
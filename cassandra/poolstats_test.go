@@ -0,0 +1,80 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+
+	"github.com/gocql/gocql"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPoolStats(t *testing.T) {
+	Convey("Given pool stats of a policy with one host", t, func() {
+		p := newPoolStats(newDCAwareHostPolicy("dc1"))
+		host := &gocql.HostInfo{}
+		p.AddHost(host)
+
+		Convey("The host is tracked as up without requests in flight", func() {
+			stats := p.snapshot()
+			So(stats, ShouldHaveLength, 1)
+			So(stats[0].up, ShouldBeTrue)
+			So(stats[0].inFlight, ShouldEqual, 0)
+		})
+
+		Convey("A picked host counts a request in flight until it is marked", func() {
+			next := p.Pick(nil)
+			picked := next()
+			So(picked.Info(), ShouldPointTo, host)
+			So(p.snapshot()[0].inFlight, ShouldEqual, 1)
+
+			picked.Mark(nil)
+			So(p.snapshot()[0].inFlight, ShouldEqual, 0)
+
+			Convey("A request is only released once", func() {
+				picked.Mark(nil)
+				So(next(), ShouldBeNil)
+				So(p.snapshot()[0].inFlight, ShouldEqual, 0)
+			})
+		})
+
+		Convey("A host skipped by gocql is released when the next host is picked", func() {
+			next := p.Pick(nil)
+			So(next(), ShouldNotBeNil)
+			So(next(), ShouldBeNil)
+			So(p.snapshot()[0].inFlight, ShouldEqual, 0)
+		})
+
+		Convey("Host state changes are tracked", func() {
+			p.HostDown(host)
+			So(p.snapshot()[0].up, ShouldBeFalse)
+			So(p.Pick(nil)(), ShouldBeNil)
+			p.HostUp(host)
+			So(p.snapshot()[0].up, ShouldBeTrue)
+			p.RemoveHost(host)
+			So(p.snapshot(), ShouldBeEmpty)
+		})
+	})
+
+	Convey("Given pool stats without policy", t, func() {
+		So(newPoolStats(nil).HostSelectionPolicy, ShouldHaveSameTypeAs, gocql.RoundRobinHostPolicy())
+	})
+}
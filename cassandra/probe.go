@@ -0,0 +1,110 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	log "github.com/sirupsen/logrus"
+)
+
+// deleteProbeCQL deletes the partition of the probe row of a metrics table.
+const deleteProbeCQL = "DELETE FROM %s.%s WHERE ns = ? AND ver = ? AND host = ?"
+
+// probeNamespace is the namespace of the probe row, which no collector writes.
+var probeNamespace = core.NewNamespace("snap-plugin-publisher-cassandra", "probe")
+
+// writeProbe verifies that a client can write into its metrics table before
+// the first metrics are written, by writing and deleting a probe row through
+// the write path of the metrics. Publishes fail until a probe succeeds, so
+// missing permissions or an incompatible table fail the task on its start.
+// A nil probe verifies nothing.
+type writeProbe struct {
+	mu     sync.Mutex
+	passed bool
+}
+
+func newWriteProbe(enabled bool) *writeProbe {
+	if !enabled {
+		return nil
+	}
+	return &writeProbe{}
+}
+
+// probeWrites probes the writes of the client unless a probe succeeded.
+func (cc *cassaClient) probeWrites() error {
+	if cc.probe == nil {
+		return nil
+	}
+	cc.probe.mu.Lock()
+	defer cc.probe.mu.Unlock()
+	if cc.probe.passed {
+		return nil
+	}
+	if err := cc.writeProbeRow(time.Now()); err != nil {
+		cassaLog.WithFields(log.Fields{
+			"err":   err,
+			"table": cc.tableName,
+		}).Error("Cassandra client write probe failed")
+		return fmt.Errorf("write probe of %s.%s failed: %v", cc.keyspace, cc.tableName, err)
+	}
+	cc.probe.passed = true
+	cassaLog.WithFields(log.Fields{
+		"table": cc.tableName,
+	}).Info("Cassandra client write probe passed")
+	return nil
+}
+
+// writeProbeRow writes the probe row of now and deletes it again. Rows of a
+// table template are kept, as their partition key is unknown.
+func (cc *cassaClient) writeProbeRow(now time.Time) error {
+	host, _ := os.Hostname()
+	m := plugin.NewMetricType(probeNamespace, now, map[string]string{core.STD_TAG_PLUGIN_RUNNING_ON: host}, "", 0.0)
+	p, err := newPoint(*m)
+	if err != nil {
+		return err
+	}
+	wb := newWriteBatch(cc.currentSession(), 1, cc.retry)
+	if err := cc.executeMetricsQuery(wb, p, map[string]string{}); err != nil {
+		return err
+	}
+	if cc.insertTemplate != nil {
+		return nil
+	}
+	stmt, values := cc.deleteProbeCQL(p)
+	return cc.currentSession().Query(stmt, values...).Exec()
+}
+
+// deleteProbeCQL returns the statement deleting the probe row of the point
+// and its values.
+func (cc *cassaClient) deleteProbeCQL(p *point) (string, []interface{}) {
+	stmt := fmt.Sprintf(deleteProbeCQL, cc.names.keyspace, cc.names.table)
+	values := []interface{}{p.ns, p.m.Version(), p.host}
+	if cc.partitionBucket > 0 {
+		stmt += " AND bucket = ?"
+		values = append(values, bucketOf(p.m.Timestamp(), cc.partitionBucket))
+	}
+	return stmt, values
+}
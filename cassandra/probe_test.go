@@ -0,0 +1,64 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWriteProbe(t *testing.T) {
+	Convey("Given a client probing its writes", t, func() {
+		cc := &cassaClient{names: cqlNames{keyspace: "snap", table: "metrics"}, probe: newWriteProbe(true)}
+		now := time.Date(2020, 1, 1, 10, 30, 0, 0, time.UTC)
+		p, err := newPoint(*plugin.NewMetricType(probeNamespace, now, map[string]string{core.STD_TAG_PLUGIN_RUNNING_ON: "h1"}, "", 0.0))
+		So(err, ShouldBeNil)
+
+		Convey("The probe partition is deleted", func() {
+			stmt, values := cc.deleteProbeCQL(p)
+			So(stmt, ShouldEqual, "DELETE FROM snap.metrics WHERE ns = ? AND ver = ? AND host = ?")
+			So(values, ShouldResemble, []interface{}{"/snap-plugin-publisher-cassandra/probe", 0, "h1"})
+		})
+
+		Convey("The bucket of a bucketed table is part of the partition", func() {
+			cc.partitionBucket = 24 * time.Hour
+			stmt, values := cc.deleteProbeCQL(p)
+			So(stmt, ShouldEndWith, "AND host = ? AND bucket = ?")
+			So(values, ShouldHaveLength, 4)
+			So(values[3], ShouldResemble, bucketOf(now, cc.partitionBucket))
+		})
+
+		Convey("A passed probe is not repeated", func() {
+			cc.probe.passed = true
+			So(cc.probeWrites(), ShouldBeNil)
+		})
+	})
+
+	Convey("Given a client not probing its writes", t, func() {
+		cc := &cassaClient{probe: newWriteProbe(false)}
+		So(cc.probe, ShouldBeNil)
+		So(cc.probeWrites(), ShouldBeNil)
+	})
+}
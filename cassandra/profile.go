@@ -0,0 +1,112 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"github.com/intelsdi-x/snap/core/ctypes"
+	log "github.com/sirupsen/logrus"
+)
+
+// writeProfiles are coherent bundles of write settings, so users do not need
+// to tune every setting on its own.
+var writeProfiles = map[string]map[string]ctypes.ConfigValue{
+	// fast favors throughput over durability
+	"fast": {
+		consistencyRuleKey:      ctypes.ConfigValueStr{Value: "ONE"},
+		retryAttemptsRuleKey:    ctypes.ConfigValueInt{Value: 1},
+		batchSizeRuleKey:        ctypes.ConfigValueInt{Value: 100},
+		writeConcurrencyRuleKey: ctypes.ConfigValueInt{Value: 4},
+		timeoutRuleKey:          ctypes.ConfigValueInt{Value: 2},
+	},
+	"balanced": {
+		consistencyRuleKey:      ctypes.ConfigValueStr{Value: "LOCAL_QUORUM"},
+		retryAttemptsRuleKey:    ctypes.ConfigValueInt{Value: 3},
+		batchSizeRuleKey:        ctypes.ConfigValueInt{Value: 20},
+		writeConcurrencyRuleKey: ctypes.ConfigValueInt{Value: 2},
+		timeoutRuleKey:          ctypes.ConfigValueInt{Value: 5},
+	},
+	// durable acknowledges writes only once a quorum of replicas has them
+	"durable": {
+		consistencyRuleKey:      ctypes.ConfigValueStr{Value: "QUORUM"},
+		retryAttemptsRuleKey:    ctypes.ConfigValueInt{Value: 5},
+		batchSizeRuleKey:        ctypes.ConfigValueInt{Value: 1},
+		writeConcurrencyRuleKey: ctypes.ConfigValueInt{Value: 1},
+		timeoutRuleKey:          ctypes.ConfigValueInt{Value: 10},
+	},
+}
+
+// profileDefaults are the defaults of the settings of the write profiles.
+// Their rules have no default, so the config policy leaves them out of the
+// configs not setting them and a profile can tell explicit settings apart
+// from defaults, even if they have the same value.
+var profileDefaults = map[string]ctypes.ConfigValue{
+	consistencyRuleKey:      ctypes.ConfigValueStr{Value: "ONE"},
+	retryAttemptsRuleKey:    ctypes.ConfigValueInt{Value: 1},
+	batchSizeRuleKey:        ctypes.ConfigValueInt{Value: 1},
+	writeConcurrencyRuleKey: ctypes.ConfigValueInt{Value: 1},
+	timeoutRuleKey:          ctypes.ConfigValueInt{Value: 2},
+}
+
+// applyWriteProfile returns a copy of the config where every setting of its
+// write profile missing from the config takes the value of the profile.
+// Settings given explicitly win over the profile.
+func applyWriteProfile(config map[string]ctypes.ConfigValue) map[string]ctypes.ConfigValue {
+	name, ok := getValueForKey(config, writeProfileRuleKey).(string)
+	checkAssertion(ok, writeProfileRuleKey)
+	if name == "" {
+		return config
+	}
+	profile, ok := writeProfiles[name]
+	if !ok {
+		log.WithFields(log.Fields{
+			"value":             name,
+			"acceptable values": "fast, balanced, durable",
+		}).Warn("invalid config value")
+		return config
+	}
+
+	applied := make(map[string]ctypes.ConfigValue, len(config))
+	for k, v := range config {
+		applied[k] = v
+	}
+	for k, v := range profile {
+		if _, ok := applied[k]; !ok {
+			applied[k] = v
+		}
+	}
+	return applied
+}
+
+// ruleDefaults returns the default values of the rules of the config policy,
+// including the ones of the settings of the write profiles.
+func ruleDefaults() map[string]ctypes.ConfigValue {
+	defaults := map[string]ctypes.ConfigValue{}
+	cp, err := NewCassandraPublisher().GetConfigPolicy()
+	handleErr(err)
+	rules, err := cp.Get([]string{""}).CopyRules()
+	handleErr(err)
+	for _, rule := range rules {
+		defaults[rule.Key()] = rule.Default()
+	}
+	for k, v := range profileDefaults {
+		defaults[k] = v
+	}
+	return defaults
+}
@@ -0,0 +1,74 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/intelsdi-x/snap/core/ctypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWriteProfile(t *testing.T) {
+	Convey("Prepare client options with a write profile", t, func() {
+		configPolicy, err := NewCassandraPublisher().GetConfigPolicy()
+		So(err, ShouldBeNil)
+
+		testConfig := make(map[string]ctypes.ConfigValue)
+		testConfig[serverAddrRuleKey] = ctypes.ConfigValueStr{Value: serverAddress}
+		testConfig[writeProfileRuleKey] = ctypes.ConfigValueStr{Value: "balanced"}
+		testConfig[batchSizeRuleKey] = ctypes.ConfigValueInt{Value: 50}
+		_, errs := configPolicy.Get([]string{""}).Process(testConfig)
+		So(errs.HasErrors(), ShouldBeFalse)
+
+		co := prepareClientOptions(testConfig)
+		Convey("So settings left at their default should take the profile values", func() {
+			So(co.consistency, ShouldEqual, gocql.LocalQuorum)
+			So(co.retry.attempts, ShouldEqual, 3)
+			So(co.writeConcurrency, ShouldEqual, 2)
+			So(co.timeout, ShouldEqual, 5*time.Second)
+		})
+		Convey("So explicit settings should win over the profile", func() {
+			So(co.batchSize, ShouldEqual, 50)
+		})
+		Convey("So the config itself should be left untouched", func() {
+			_, ok := testConfig[consistencyRuleKey]
+			So(ok, ShouldBeFalse)
+		})
+		Convey("So unknown profiles should be ignored", func() {
+			testConfig[writeProfileRuleKey] = ctypes.ConfigValueStr{Value: "turbo"}
+			So(prepareClientOptions(testConfig).consistency, ShouldEqual, gocql.One)
+		})
+		Convey("So explicit settings equal to their default should win over the profile", func() {
+			testConfig[writeProfileRuleKey] = ctypes.ConfigValueStr{Value: "durable"}
+			testConfig[consistencyRuleKey] = ctypes.ConfigValueStr{Value: "ONE"}
+			co := prepareClientOptions(testConfig)
+			So(co.consistency, ShouldEqual, gocql.One)
+			So(co.retry.attempts, ShouldEqual, 5)
+
+			testConfig[writeProfileRuleKey] = ctypes.ConfigValueStr{Value: "fast"}
+			testConfig[batchSizeRuleKey] = ctypes.ConfigValueInt{Value: 1}
+			So(prepareClientOptions(testConfig).batchSize, ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,82 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimit is a token bucket bounding the rows a client writes per second,
+// so an aggressive task schedule does not starve other users of a shared
+// cluster. The bucket holds up to a second of writes, which lets short
+// bursts pass unthrottled. A nil limit does not bound the writes.
+type rateLimit struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// newRateLimit returns a limit of perSecond rows, nil if perSecond is not positive.
+func newRateLimit(perSecond int) *rateLimit {
+	if perSecond <= 0 {
+		return nil
+	}
+	rate := float64(perSecond)
+	return &rateLimit{
+		rate:   rate,
+		burst:  rate,
+		tokens: rate,
+		last:   time.Now(),
+		now:    time.Now,
+		sleep:  time.Sleep,
+	}
+}
+
+// wait blocks until rows may be written. Writes larger than the bucket,
+// like big batches, are let through once the bucket has paid off their
+// excess, so the average rate holds for them too.
+func (l *rateLimit) wait(rows int) {
+	if l == nil || rows <= 0 {
+		return
+	}
+	l.mu.Lock()
+	now := l.now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens -= float64(rows)
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay > 0 {
+		l.sleep(delay)
+	}
+}
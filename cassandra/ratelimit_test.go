@@ -0,0 +1,77 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRateLimit(t *testing.T) {
+	Convey("Given a limit of 100 writes per second", t, func() {
+		now := time.Unix(1000, 0)
+		var slept time.Duration
+		l := newRateLimit(100)
+		l.last = now
+		l.now = func() time.Time { return now }
+		l.sleep = func(d time.Duration) {
+			slept += d
+			now = now.Add(d)
+		}
+
+		Convey("A burst of up to a second of writes is not delayed", func() {
+			l.wait(60)
+			l.wait(40)
+			So(slept, ShouldEqual, 0)
+		})
+
+		Convey("Writes beyond the bucket wait for their tokens", func() {
+			l.wait(100)
+			l.wait(10)
+			So(slept, ShouldEqual, 100*time.Millisecond)
+		})
+
+		Convey("Batches larger than the bucket pay off their excess", func() {
+			l.wait(300)
+			So(slept, ShouldEqual, 2*time.Second)
+		})
+
+		Convey("Tokens are refilled over time up to the bucket size", func() {
+			l.wait(100)
+			now = now.Add(time.Hour)
+			l.wait(100)
+			So(slept, ShouldEqual, 0)
+			l.wait(1)
+			So(slept, ShouldEqual, 10*time.Millisecond)
+		})
+	})
+
+	Convey("Given no limit", t, func() {
+		l := newRateLimit(0)
+
+		Convey("Writes are not bounded", func() {
+			So(l, ShouldBeNil)
+			l.wait(1000000)
+		})
+	})
+}
@@ -0,0 +1,128 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gocql/gocql"
+	log "github.com/sirupsen/logrus"
+)
+
+// localDCCQL returns the data center of the coordinator.
+const localDCCQL = "SELECT data_center FROM system.local"
+
+// Replication strategies of the created keyspaces.
+const (
+	replicationSimple   = "SimpleStrategy"
+	replicationTopology = "NetworkTopologyStrategy"
+)
+
+// replicationOptions configure the replication of the keyspaces created by the publisher.
+type replicationOptions struct {
+	strategy string
+	// factor is the replication factor of SimpleStrategy
+	factor int
+	// dcs are the replication factors of NetworkTopologyStrategy, by data center
+	dcs map[string]int
+}
+
+// cql returns the replication map of a CREATE KEYSPACE statement.
+func (r replicationOptions) cql() string {
+	if r.strategy != replicationTopology {
+		return fmt.Sprintf("{'class': '%s', 'replication_factor': %d}", replicationSimple, r.factor)
+	}
+	dcs := make([]string, 0, len(r.dcs))
+	for dc := range r.dcs {
+		dcs = append(dcs, dc)
+	}
+	sort.Strings(dcs)
+	opts := []string{fmt.Sprintf("'class': '%s'", replicationTopology)}
+	for _, dc := range dcs {
+		opts = append(opts, fmt.Sprintf("'%s': %d", dc, r.dcs[dc]))
+	}
+	return "{" + strings.Join(opts, ", ") + "}"
+}
+
+// parseReplicationDCs parses a comma separated list of dc:factor pairs.
+func parseReplicationDCs(s string) (map[string]int, error) {
+	dcs := map[string]int{}
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		parts := strings.Split(pair, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid data center replication %q, expected dc:factor", pair)
+		}
+		dc := strings.TrimSpace(parts[0])
+		factor, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if dc == "" || err != nil || factor < 1 {
+			return nil, fmt.Errorf("invalid data center replication %q, expected dc:factor", pair)
+		}
+		dcs[dc] = factor
+	}
+	return dcs, nil
+}
+
+// validateReplication checks that the keyspace is replicated to the local data
+// center, writes fail with unavailable replicas otherwise. The local data center
+// is localDC or, if empty, the one of the coordinator.
+func validateReplication(session *gocql.Session, keyspace, localDC string) error {
+	if localDC == "" {
+		if err := session.Query(localDCCQL).Scan(&localDC); err != nil {
+			cassaLog.WithFields(log.Fields{
+				"err": err,
+			}).Warn("local data center unknown, replication not validated")
+			return nil
+		}
+	}
+	km, err := session.KeyspaceMetadata(keyspace)
+	if err != nil {
+		return err
+	}
+	return checkReplication(km, localDC)
+}
+
+// checkReplication returns an error suggesting the ALTER KEYSPACE statement
+// to fix the replication when the keyspace has no replicas in localDC.
+func checkReplication(km *gocql.KeyspaceMetadata, localDC string) error {
+	// SimpleStrategy places replicas in every data center
+	if !strings.HasSuffix(km.StrategyClass, replicationTopology) {
+		return nil
+	}
+	dcs := map[string]int{}
+	for dc, v := range km.StrategyOptions {
+		if factor, err := strconv.Atoi(fmt.Sprint(v)); err == nil {
+			dcs[dc] = factor
+		}
+	}
+	if dcs[localDC] > 0 {
+		return nil
+	}
+	dcs[localDC] = 3
+	fix := replicationOptions{strategy: replicationTopology, dcs: dcs}
+	return fmt.Errorf("keyspace %s has no replicas in the local data center %s, writes fail with unavailable replicas; "+
+		"add replicas with: ALTER KEYSPACE %s WITH REPLICATION = %s; and run nodetool repair %s in %s",
+		km.Name, localDC, km.Name, fix.cql(), km.Name, localDC)
+}
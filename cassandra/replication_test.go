@@ -0,0 +1,77 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+
+	"github.com/gocql/gocql"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReplication(t *testing.T) {
+	Convey("Build the replication of created keyspaces", t, func() {
+		Convey("So SimpleStrategy should use the replication factor", func() {
+			r := replicationOptions{strategy: replicationSimple, factor: 3}
+			So(r.cql(), ShouldEqual, "{'class': 'SimpleStrategy', 'replication_factor': 3}")
+		})
+		Convey("So NetworkTopologyStrategy should list the data centers", func() {
+			dcs, err := parseReplicationDCs("dc2:2, dc1:3")
+			So(err, ShouldBeNil)
+			r := replicationOptions{strategy: replicationTopology, dcs: dcs}
+			So(r.cql(), ShouldEqual, "{'class': 'NetworkTopologyStrategy', 'dc1': 3, 'dc2': 2}")
+		})
+		Convey("So invalid data center lists should be refused", func() {
+			for _, s := range []string{"dc1", "dc1:x", ":3", "dc1:0"} {
+				_, err := parseReplicationDCs(s)
+				So(err, ShouldNotBeNil)
+			}
+		})
+	})
+}
+
+func TestCheckReplication(t *testing.T) {
+	Convey("Validate the replication of the keyspace against the local data center", t, func() {
+		Convey("So SimpleStrategy should always be accepted", func() {
+			km := &gocql.KeyspaceMetadata{Name: "snap", StrategyClass: "org.apache.cassandra.locator.SimpleStrategy"}
+			So(checkReplication(km, "dc1"), ShouldBeNil)
+		})
+		Convey("So a replicated local data center should be accepted", func() {
+			km := &gocql.KeyspaceMetadata{
+				Name:            "snap",
+				StrategyClass:   "org.apache.cassandra.locator.NetworkTopologyStrategy",
+				StrategyOptions: map[string]interface{}{"dc1": "3"},
+			}
+			So(checkReplication(km, "dc1"), ShouldBeNil)
+		})
+		Convey("So a missing local data center should suggest the ALTER statement", func() {
+			km := &gocql.KeyspaceMetadata{
+				Name:            "snap",
+				StrategyClass:   "org.apache.cassandra.locator.NetworkTopologyStrategy",
+				StrategyOptions: map[string]interface{}{"dc1": "3", "dc2": "0"},
+			}
+			err := checkReplication(km, "dc2")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring,
+				"ALTER KEYSPACE snap WITH REPLICATION = {'class': 'NetworkTopologyStrategy', 'dc1': 3, 'dc2': 3};")
+		})
+	})
+}
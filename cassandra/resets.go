@@ -0,0 +1,110 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Counter reset detection modes.
+const (
+	counterResetFlag   = "flag"
+	counterResetMarker = "marker"
+)
+
+// resetTracker remembers the latest value of every counter series to detect
+// counter resets, i.e. values decreasing because the counter restarted, so
+// query layers reconstructing rates do not see a huge negative rate.
+type resetTracker struct {
+	// prefixes are the namespace prefixes of the counters, empty for all series
+	prefixes []string
+
+	mu     sync.Mutex
+	latest map[string]float64
+}
+
+// newResetTracker returns a tracker of the counters of the comma separated
+// namespace prefixes, e.g. "/intel/procfs/iface/*".
+func newResetTracker(namespaces string) *resetTracker {
+	t := &resetTracker{latest: map[string]float64{}}
+	for _, prefix := range splitPatterns(namespaces) {
+		t.prefixes = append(t.prefixes, "/"+strings.Trim(strings.TrimSuffix(prefix, "*"), "/"))
+	}
+	return t
+}
+
+// counter returns true if the namespace is the one of a tracked counter.
+func (t *resetTracker) counter(ns string) bool {
+	if len(t.prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range t.prefixes {
+		if ns == prefix || strings.HasPrefix(ns, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// reset records the value of the point and reports whether it is lower than
+// the latest value of its series. Out-of-order samples are not recorded and
+// never a reset. A nil tracker detects nothing.
+func (t *resetTracker) reset(p *point) bool {
+	if t == nil || p.outOfOrder || !t.counter(p.ns) {
+		return false
+	}
+	v, ok := numericValue(p.value)
+	if !ok {
+		return false
+	}
+	series := seriesKey(p.ns, p.m.Version(), p.host)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	latest, seen := t.latest[series]
+	t.latest[series] = v
+	return seen && v < latest
+}
+
+// numericValue returns the value of a numeric column as float64.
+func numericValue(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, !math.IsNaN(v)
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// resetMarker returns the sentinel point written a millisecond before the
+// first sample after a counter reset. Its value is negative infinity in the
+// doubleVal column, so query layers can tell the reset from a decrease.
+func resetMarker(p *point) *point {
+	marker := *p
+	marker.m.Timestamp_ = p.m.Timestamp().Add(-time.Millisecond)
+	marker.column = "doubleVal"
+	marker.value = math.Inf(-1)
+	return &marker
+}
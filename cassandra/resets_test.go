@@ -0,0 +1,90 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCounterResets(t *testing.T) {
+	now := time.Now()
+	sample := func(ns string, data interface{}, ts time.Time) *point {
+		p, err := newPoint(*plugin.NewMetricType(core.NewNamespace("intel", "procfs", ns), ts, nil, "", data))
+		So(err, ShouldBeNil)
+		return p
+	}
+
+	Convey("Given a tracker of the iface counters", t, func() {
+		tracker := newResetTracker("/intel/procfs/iface/*")
+
+		Convey("A decreasing value is a reset", func() {
+			So(tracker.reset(sample("iface", 100.0, now)), ShouldBeFalse)
+			So(tracker.reset(sample("iface", 150.0, now.Add(time.Second))), ShouldBeFalse)
+			So(tracker.reset(sample("iface", 3.0, now.Add(2*time.Second))), ShouldBeTrue)
+			So(tracker.reset(sample("iface", 10.0, now.Add(3*time.Second))), ShouldBeFalse)
+		})
+
+		Convey("Integer counters are tracked too", func() {
+			So(tracker.reset(sample("iface", int64(7), now)), ShouldBeFalse)
+			So(tracker.reset(sample("iface", uint64(2), now.Add(time.Second))), ShouldBeTrue)
+		})
+
+		Convey("Out-of-order samples are never a reset", func() {
+			So(tracker.reset(sample("iface", 100.0, now)), ShouldBeFalse)
+			late := sample("iface", 50.0, now.Add(-time.Second))
+			late.outOfOrder = true
+			So(tracker.reset(late), ShouldBeFalse)
+			So(tracker.reset(sample("iface", 120.0, now.Add(time.Second))), ShouldBeFalse)
+		})
+
+		Convey("Other namespaces are not tracked", func() {
+			So(tracker.reset(sample("meminfo", 100.0, now)), ShouldBeFalse)
+			So(tracker.reset(sample("meminfo", 10.0, now.Add(time.Second))), ShouldBeFalse)
+		})
+	})
+
+	Convey("Given no tracker", t, func() {
+		var tracker *resetTracker
+
+		Convey("Nothing is a reset", func() {
+			So(tracker.reset(sample("iface", 1.0, now)), ShouldBeFalse)
+		})
+	})
+
+	Convey("Given the first sample after a reset", t, func() {
+		p := sample("iface", int64(3), now)
+
+		Convey("Its marker is negative infinity a millisecond before it", func() {
+			marker := resetMarker(p)
+			So(marker.m.Timestamp(), ShouldResemble, now.Add(-time.Millisecond))
+			So(marker.column, ShouldEqual, "doubleVal")
+			So(math.IsInf(marker.value.(float64), -1), ShouldBeTrue)
+			So(p.m.Timestamp(), ShouldResemble, now)
+			So(p.value, ShouldNotEqual, marker.value)
+		})
+	})
+}
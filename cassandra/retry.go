@@ -0,0 +1,86 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/gocql/gocql"
+	log "github.com/sirupsen/logrus"
+)
+
+// overloadedErrCode is the protocol error code of an overloaded coordinator
+const overloadedErrCode = 0x1001
+
+// retryPolicy retries failed inserts with an exponential backoff.
+type retryPolicy struct {
+	// attempts is the maximum number of executions of an insert, 1 disables retries
+	attempts int
+	// delay is the delay before the first retry, doubled for every further retry
+	delay time.Duration
+	// jitter is the maximum random delay added to every retry
+	jitter time.Duration
+	// speculative starts further executions of a slow attempt
+	speculative speculativePolicy
+	// stats counts the retries, nil if not counted
+	stats *publisherStats
+}
+
+// do executes fn until it succeeds, fails with an error not worth retrying
+// or the attempts are used up, and returns its last error.
+func (p retryPolicy) do(fn func() error) error {
+	delay := p.delay
+	for attempt := 1; ; attempt++ {
+		err := p.speculative.do(fn)
+		if err == nil || attempt >= p.attempts || !isRetryable(err) {
+			return err
+		}
+
+		wait := delay
+		if p.jitter > 0 {
+			wait += time.Duration(rand.Int63n(int64(p.jitter)))
+		}
+		cassaLog.WithFields(log.Fields{
+			"err":     err,
+			"attempt": attempt,
+			"delay":   wait,
+		}).Warn("Cassandra client insertion error, retrying")
+		p.stats.retry()
+		time.Sleep(wait)
+		delay *= 2
+	}
+}
+
+// isRetryable returns true for transient errors: timeouts, unavailable
+// replicas, overloaded coordinators and lost connections.
+func isRetryable(err error) bool {
+	switch e := err.(type) {
+	case *gocql.RequestErrWriteTimeout, *gocql.RequestErrReadTimeout, *gocql.RequestErrUnavailable:
+		return true
+	case gocql.RequestError:
+		return e.Code() == overloadedErrCode
+	}
+	switch err {
+	case gocql.ErrTimeoutNoResponse, gocql.ErrConnectionClosed, gocql.ErrNoConnections:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gocql/gocql"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	Convey("Execute inserts with a retry policy", t, func() {
+		p := retryPolicy{attempts: 3}
+		calls := 0
+
+		Convey("So transient errors should be retried up to the attempts", func() {
+			err := p.do(func() error {
+				calls++
+				return gocql.ErrTimeoutNoResponse
+			})
+			So(err, ShouldEqual, gocql.ErrTimeoutNoResponse)
+			So(calls, ShouldEqual, 3)
+		})
+		Convey("So retrying should stop once the insert succeeds", func() {
+			err := p.do(func() error {
+				calls++
+				if calls < 2 {
+					return &gocql.RequestErrUnavailable{}
+				}
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(calls, ShouldEqual, 2)
+		})
+		Convey("So other errors should not be retried", func() {
+			err := p.do(func() error {
+				calls++
+				return errors.New("invalid query")
+			})
+			So(err, ShouldNotBeNil)
+			So(calls, ShouldEqual, 1)
+		})
+		Convey("So a single attempt should disable retries", func() {
+			p.attempts = 1
+			p.do(func() error {
+				calls++
+				return gocql.ErrTimeoutNoResponse
+			})
+			So(calls, ShouldEqual, 1)
+		})
+	})
+}
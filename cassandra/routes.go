@@ -0,0 +1,337 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"container/list"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+// tableNamePattern matches the unquoted table names routes can target.
+var tableNamePattern = regexp.MustCompile(`^[A-Za-z]\w*$`)
+
+// clusterSettingsPattern matches the server and the settings of an entry of
+// cluster settings. The colon must be followed by a space, as servers may be
+// IPv6 addresses and setting values may contain colons.
+var clusterSettingsPattern = regexp.MustCompile(`^(\S+):\s+(.*)$`)
+
+// route maps metrics with a namespace prefix onto a target.
+type route struct {
+	prefix string
+	target string
+}
+
+// parseRoutes parses routing rules given as a comma separated list of
+// prefix=target pairs, e.g. "/intel/psutil=10.0.0.1,/app=10.0.1.1". Rules
+// may also be written as "/intel/psutil/* -> target".
+func parseRoutes(rules string) ([]route, error) {
+	routes := []route{}
+	for _, rule := range strings.Split(rules, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		sep := "="
+		if strings.Contains(rule, "->") {
+			sep = "->"
+		}
+		parts := strings.SplitN(rule, sep, 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid routing rule '%s', expected prefix=target", rule)
+		}
+		prefix := "/" + strings.Trim(strings.TrimSuffix(strings.TrimSpace(parts[0]), "*"), "/")
+		target := strings.TrimSpace(parts[1])
+		if prefix == "/" || target == "" {
+			return nil, fmt.Errorf("invalid routing rule '%s', prefix and target are required", rule)
+		}
+		routes = append(routes, route{prefix: prefix, target: target})
+	}
+	return routes, nil
+}
+
+// parseTableRoutes parses routing rules whose targets are table names.
+func parseTableRoutes(rules string) ([]route, error) {
+	routes, err := parseRoutes(rules)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range routes {
+		if !tableNamePattern.MatchString(r.target) {
+			return nil, fmt.Errorf("invalid table name '%s' of routing rule for '%s'", r.target, r.prefix)
+		}
+	}
+	return routes, nil
+}
+
+// configRoutes are the routing settings of a publisher config.
+type configRoutes struct {
+	clusters []route
+	tables   []route
+	profiles tableProfiles
+	// options of the routed clusters with settings of their own, by server
+	options map[string]clientOptions
+}
+
+// routeSettings are the keys of the settings a routed cluster cannot
+// override, as they select the clusters and tables themselves.
+var routeSettings = map[string]bool{
+	serverAddrRuleKey:      true,
+	clusterRoutesRuleKey:   true,
+	clusterSettingsRuleKey: true,
+	tableRoutesRuleKey:     true,
+	tableProfilesRuleKey:   true,
+}
+
+// parseClusterSettings parses the settings of routed clusters given as a
+// semicolon separated list of "server: key=value ..." entries, e.g.
+// "10.0.1.1: consistency=QUORUM batchSize=50 port=9142", into the config
+// values overriding the ones of the config, by server.
+func parseClusterSettings(s string) (map[string]map[string]ctypes.ConfigValue, error) {
+	settings := map[string]map[string]ctypes.ConfigValue{}
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		match := clusterSettingsPattern.FindStringSubmatch(entry)
+		if match == nil {
+			return nil, fmt.Errorf("invalid cluster settings '%s', expected server: key=value ...", entry)
+		}
+		server := match[1]
+		if _, ok := settings[server]; ok {
+			return nil, fmt.Errorf("duplicate cluster settings for '%s'", server)
+		}
+		values := map[string]ctypes.ConfigValue{}
+		for _, setting := range strings.Fields(match[2]) {
+			kv := strings.SplitN(setting, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("invalid cluster settings for '%s': setting '%s' is not key=value", server, setting)
+			}
+			value, err := parseSetting(kv[0], kv[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid cluster settings for '%s': %v", server, err)
+			}
+			values[kv[0]] = value
+		}
+		settings[server] = values
+	}
+	return settings, nil
+}
+
+// parseSetting parses the value of a setting as the type of its rule.
+func parseSetting(key, value string) (ctypes.ConfigValue, error) {
+	def := defaultValue(key)
+	if def == nil || routeSettings[key] {
+		return nil, fmt.Errorf("unknown setting '%s'", key)
+	}
+	switch def.Type() {
+	case "bool":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s '%s'", key, value)
+		}
+		return ctypes.ConfigValueBool{Value: b}, nil
+	case "integer":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s '%s'", key, value)
+		}
+		return ctypes.ConfigValueInt{Value: n}, nil
+	}
+	return ctypes.ConfigValueStr{Value: value}, nil
+}
+
+// parseConfigRoutes parses the cluster routes, the table routes and the
+// table profiles of a publisher config.
+func parseConfigRoutes(config map[string]ctypes.ConfigValue) (configRoutes, error) {
+	clusterRoutes, ok := getValueForKey(config, clusterRoutesRuleKey).(string)
+	checkAssertion(ok, clusterRoutesRuleKey)
+	clusters, err := parseRoutes(clusterRoutes)
+	if err != nil {
+		return configRoutes{}, fmt.Errorf("invalid cluster routes: %v", err)
+	}
+	tableRoutes, ok := getValueForKey(config, tableRoutesRuleKey).(string)
+	checkAssertion(ok, tableRoutesRuleKey)
+	tables, err := parseTableRoutes(tableRoutes)
+	if err != nil {
+		return configRoutes{}, fmt.Errorf("invalid table routes: %v", err)
+	}
+	tableProfiles, ok := getValueForKey(config, tableProfilesRuleKey).(string)
+	checkAssertion(ok, tableProfilesRuleKey)
+	profiles, err := parseTableProfiles(tableProfiles)
+	if err != nil {
+		return configRoutes{}, fmt.Errorf("invalid table profiles: %v", err)
+	}
+	clusterSettings, ok := getValueForKey(config, clusterSettingsRuleKey).(string)
+	checkAssertion(ok, clusterSettingsRuleKey)
+	settings, err := parseClusterSettings(clusterSettings)
+	if err != nil {
+		return configRoutes{}, err
+	}
+
+	// clusters with settings of their own get the options of the config
+	// with their settings applied
+	options := map[string]clientOptions{}
+	for server, values := range settings {
+		if !routesTo(clusters, server) {
+			return configRoutes{}, fmt.Errorf("invalid cluster settings for '%s': no cluster route targets it", server)
+		}
+		merged := make(map[string]ctypes.ConfigValue, len(config)+len(values))
+		for k, v := range config {
+			merged[k] = v
+		}
+		for k, v := range values {
+			merged[k] = v
+		}
+		options[server] = prepareClientOptions(merged)
+	}
+	return configRoutes{clusters: clusters, tables: tables, profiles: profiles, options: options}, nil
+}
+
+// routesTo returns true if a route targets the target.
+func routesTo(routes []route, target string) bool {
+	for _, r := range routes {
+		if r.target == target {
+			return true
+		}
+	}
+	return false
+}
+
+// clusterOptions returns the options of the clients of the server, the
+// options of the config unless the cluster has settings of its own.
+func (r configRoutes) clusterOptions(co clientOptions, server string) clientOptions {
+	if o, ok := r.options[server]; ok {
+		return o
+	}
+	return co
+}
+
+// clientTarget is a client of a publisher config with its options.
+type clientTarget struct {
+	target tableTarget
+	opts   []ClientOption
+}
+
+// targets returns the clients of a publisher config of the client options:
+// the client of the config with the zero target, the client of every routed
+// cluster with the server as target and the client of every routed table of
+// every cluster with the server, empty for the config's, and the table.
+// Clients of clusters with settings of their own get their options.
+func (r configRoutes) targets(co clientOptions) []clientTarget {
+	targets := []clientTarget{{opts: []ClientOption{withClientOptions(co), r.profiles.option(co.tableName)}}}
+	seen := map[tableTarget]bool{{}: true}
+	add := func(target tableTarget, opts ...ClientOption) {
+		if !seen[target] {
+			seen[target] = true
+			targets = append(targets, clientTarget{target: target, opts: opts})
+		}
+	}
+
+	servers := []string{""}
+	for _, c := range r.clusters {
+		cco := r.clusterOptions(co, c.target)
+		add(tableTarget{server: c.target}, withClientOptions(cco), WithServer(c.target, cco.port), r.profiles.option(cco.tableName))
+		servers = append(servers, c.target)
+	}
+	for _, server := range servers {
+		cco := co
+		if server != "" {
+			cco = r.clusterOptions(co, server)
+		}
+		for _, t := range r.tables {
+			opts := []ClientOption{withClientOptions(cco), WithTable(t.target), r.profiles.option(t.target)}
+			if server != "" {
+				opts = append(opts, WithServer(server, cco.port))
+			}
+			add(tableTarget{server: server, table: t.target}, opts...)
+		}
+	}
+	return targets
+}
+
+// matchRoute returns the target of the route with the longest prefix matching
+// whole elements of the namespace.
+func matchRoute(routes []route, ns string) (string, bool) {
+	match := -1
+	for i, r := range routes {
+		if ns != r.prefix && !strings.HasPrefix(ns, r.prefix+"/") {
+			continue
+		}
+		if match < 0 || len(r.prefix) > len(routes[match].prefix) {
+			match = i
+		}
+	}
+	if match < 0 {
+		return "", false
+	}
+	return routes[match].target, true
+}
+
+// routeCache caches the route matched by namespaces, including namespaces
+// matching no route, so the rules are evaluated once per namespace. The
+// least recently used namespaces are evicted when the cache is full.
+type routeCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type routeCacheEntry struct {
+	ns     string
+	target string
+	ok     bool
+}
+
+func newRouteCache(size int) *routeCache {
+	return &routeCache{size: size, entries: map[string]*list.Element{}, order: list.New()}
+}
+
+// match returns the cached route target of the namespace, matching the routes
+// on a cache miss. A nil or zero sized cache always matches the routes.
+func (c *routeCache) match(routes []route, ns string) (string, bool) {
+	if c == nil || c.size <= 0 {
+		return matchRoute(routes, ns)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[ns]; ok {
+		c.order.MoveToFront(e)
+		entry := e.Value.(*routeCacheEntry)
+		return entry.target, entry.ok
+	}
+
+	target, ok := matchRoute(routes, ns)
+	c.entries[ns] = c.order.PushFront(&routeCacheEntry{ns: ns, target: target, ok: ok})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*routeCacheEntry).ns)
+	}
+	return target, ok
+}
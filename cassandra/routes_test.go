@@ -0,0 +1,236 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/ctypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRoutes(t *testing.T) {
+	Convey("Parse routing rules", t, func() {
+		routes, err := parseRoutes(" /intel/psutil/ = 10.0.0.1, intel=10.0.0.2,/app=10.0.1.1 ,")
+		So(err, ShouldBeNil)
+		So(routes, ShouldResemble, []route{
+			{prefix: "/intel/psutil", target: "10.0.0.1"},
+			{prefix: "/intel", target: "10.0.0.2"},
+			{prefix: "/app", target: "10.0.1.1"},
+		})
+
+		Convey("So the longest matching prefix should win", func() {
+			target, ok := matchRoute(routes, "/intel/psutil/load/load1")
+			So(ok, ShouldBeTrue)
+			So(target, ShouldEqual, "10.0.0.1")
+			target, ok = matchRoute(routes, "/intel/mock/foo")
+			So(ok, ShouldBeTrue)
+			So(target, ShouldEqual, "10.0.0.2")
+		})
+		Convey("So prefixes should match whole namespace elements only", func() {
+			_, ok := matchRoute(routes, "/application/foo")
+			So(ok, ShouldBeFalse)
+			target, ok := matchRoute(routes, "/app")
+			So(ok, ShouldBeTrue)
+			So(target, ShouldEqual, "10.0.1.1")
+		})
+		Convey("So arrow rules with wildcards should be accepted", func() {
+			routes, err := parseTableRoutes("/intel/psutil/* -> psutil_metrics")
+			So(err, ShouldBeNil)
+			So(routes, ShouldResemble, []route{{prefix: "/intel/psutil", target: "psutil_metrics"}})
+		})
+		Convey("So invalid table names should be refused", func() {
+			_, err := parseTableRoutes("/intel=psutil-metrics")
+			So(err, ShouldNotBeNil)
+		})
+		Convey("So empty rules should give no routes", func() {
+			routes, err := parseRoutes("")
+			So(err, ShouldBeNil)
+			So(routes, ShouldBeEmpty)
+		})
+		Convey("So invalid rules should return an error", func() {
+			_, err := parseRoutes("/intel")
+			So(err, ShouldNotBeNil)
+			_, err = parseRoutes("/=10.0.0.1")
+			So(err, ShouldNotBeNil)
+			_, err = parseRoutes("/intel=")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestRouteCache(t *testing.T) {
+	Convey("Create a route cache", t, func() {
+		routes := []route{{prefix: "/intel", target: "10.0.0.1"}}
+		c := newRouteCache(2)
+
+		Convey("So matches and misses should be cached", func() {
+			target, ok := c.match(routes, "/intel/foo")
+			So(ok, ShouldBeTrue)
+			So(target, ShouldEqual, "10.0.0.1")
+			_, ok = c.match(routes, "/app/foo")
+			So(ok, ShouldBeFalse)
+
+			// cached decisions are used even if the rules change
+			target, ok = c.match(nil, "/intel/foo")
+			So(ok, ShouldBeTrue)
+			So(target, ShouldEqual, "10.0.0.1")
+			So(c.order.Len(), ShouldEqual, 2)
+		})
+		Convey("So the least recently used namespace should be evicted", func() {
+			c.match(routes, "/intel/a")
+			c.match(routes, "/intel/b")
+			c.match(routes, "/intel/a")
+			c.match(routes, "/intel/c")
+			So(c.entries, ShouldContainKey, "/intel/a")
+			So(c.entries, ShouldContainKey, "/intel/c")
+			So(c.entries, ShouldNotContainKey, "/intel/b")
+		})
+		Convey("So a nil cache should match the routes", func() {
+			var nc *routeCache
+			target, ok := nc.match(routes, "/intel/foo")
+			So(ok, ShouldBeTrue)
+			So(target, ShouldEqual, "10.0.0.1")
+		})
+	})
+}
+
+func TestGroupByCluster(t *testing.T) {
+	Convey("Group metrics by cluster", t, func() {
+		def, other := &cassaClient{}, &cassaClient{}
+		cas := &configClients{
+			client:         def,
+			routes:         []route{{prefix: "/intel/psutil", target: "10.0.0.1"}},
+			clusterClients: map[string]*cassaClient{"10.0.0.1": other},
+		}
+		metrics := []plugin.MetricType{
+			*plugin.NewMetricType(core.NewNamespace("intel", "psutil", "load"), time.Now(), nil, "", 1),
+			*plugin.NewMetricType(core.NewNamespace("intel", "mock", "foo"), time.Now(), nil, "", 2),
+		}
+		groups := cas.groupByCluster(metrics)
+		So(groups, ShouldHaveLength, 2)
+		So(groups[other], ShouldHaveLength, 1)
+		So(groups[other][0].Data(), ShouldEqual, 1)
+		So(groups[def], ShouldHaveLength, 1)
+		So(groups[def][0].Data(), ShouldEqual, 2)
+
+		Convey("So table routes should pick the table of the routed cluster", func() {
+			table, otherTable := &cassaClient{}, &cassaClient{}
+			cas.tableRoutes = []route{{prefix: "/intel", target: "intel_metrics"}}
+			cas.tableClients = map[tableTarget]*cassaClient{
+				{server: "", table: "intel_metrics"}:         table,
+				{server: "10.0.0.1", table: "intel_metrics"}: otherTable,
+			}
+			groups := cas.groupByCluster(metrics)
+			So(groups[otherTable], ShouldHaveLength, 1)
+			So(groups[otherTable][0].Data(), ShouldEqual, 1)
+			So(groups[table], ShouldHaveLength, 1)
+			So(groups[table][0].Data(), ShouldEqual, 2)
+		})
+	})
+}
+
+func TestConfigTargets(t *testing.T) {
+	Convey("Given a config routing clusters and tables", t, func() {
+		config := ruleDefaults()
+		config[serverAddrRuleKey] = ctypes.ConfigValueStr{Value: "10.0.0.1"}
+		config[clusterRoutesRuleKey] = ctypes.ConfigValueStr{Value: "/app=10.0.1.1"}
+		config[tableRoutesRuleKey] = ctypes.ConfigValueStr{Value: "/intel=metrics_intel"}
+		config[tableProfilesRuleKey] = ctypes.ConfigValueStr{Value: "metrics_intel: ttl=3600"}
+		routes, err := parseConfigRoutes(config)
+		So(err, ShouldBeNil)
+
+		Convey("So every cluster and table should get a client", func() {
+			targets := routes.targets(prepareClientOptions(config))
+			options := map[tableTarget]clientOptions{}
+			for _, t := range targets {
+				co, err := newClientOptions(t.opts...)
+				So(err, ShouldBeNil)
+				options[t.target] = co
+			}
+			So(targets, ShouldHaveLength, 4)
+			So(targets[0].target, ShouldResemble, tableTarget{})
+			So(options[tableTarget{}].server, ShouldEqual, "10.0.0.1")
+			So(options[tableTarget{server: "10.0.1.1"}].server, ShouldEqual, "10.0.1.1")
+			So(options[tableTarget{server: "10.0.1.1"}].tableName, ShouldEqual, "metrics")
+
+			Convey("With the table profiles of routed tables", func() {
+				co := options[tableTarget{table: "metrics_intel"}]
+				So(co.server, ShouldEqual, "10.0.0.1")
+				So(co.tableName, ShouldEqual, "metrics_intel")
+				So(co.ttl, ShouldEqual, 3600)
+				co = options[tableTarget{server: "10.0.1.1", table: "metrics_intel"}]
+				So(co.server, ShouldEqual, "10.0.1.1")
+				So(co.ttl, ShouldEqual, 3600)
+			})
+		})
+
+		Convey("So routed clusters should get their own settings", func() {
+			config[clusterSettingsRuleKey] = ctypes.ConfigValueStr{Value: "10.0.1.1: consistency=QUORUM port=9142 batchSize=50 checksum=true"}
+			routes, err := parseConfigRoutes(config)
+			So(err, ShouldBeNil)
+			options := map[tableTarget]clientOptions{}
+			for _, t := range routes.targets(prepareClientOptions(config)) {
+				co, err := newClientOptions(t.opts...)
+				So(err, ShouldBeNil)
+				options[t.target] = co
+			}
+			for _, target := range []tableTarget{{server: "10.0.1.1"}, {server: "10.0.1.1", table: "metrics_intel"}} {
+				co := options[target]
+				So(co.server, ShouldEqual, "10.0.1.1")
+				So(co.port, ShouldEqual, 9142)
+				So(co.batchSize, ShouldEqual, 50)
+				So(co.checksum, ShouldBeTrue)
+			}
+			So(options[tableTarget{server: "10.0.1.1"}].consistency, ShouldEqual, gocql.Quorum)
+			So(options[tableTarget{}].consistency, ShouldEqual, gocql.One)
+			So(options[tableTarget{}].port, ShouldEqual, 9042)
+			So(options[tableTarget{table: "metrics_intel"}].batchSize, ShouldEqual, 1)
+		})
+
+		Convey("So invalid cluster settings should be refused", func() {
+			for _, settings := range []string{
+				"10.0.2.2: batchSize=50",
+				"10.0.1.1: nope=1",
+				"10.0.1.1: batchSize=many",
+				"10.0.1.1: server=10.0.3.3",
+				"10.0.1.1 batchSize=50",
+				"10.0.1.1:batchSize=50",
+				"10.0.1.1: batchSize=50; 10.0.1.1: ttl=60",
+			} {
+				config[clusterSettingsRuleKey] = ctypes.ConfigValueStr{Value: settings}
+				_, err := parseConfigRoutes(config)
+				So(err, ShouldNotBeNil)
+			}
+		})
+
+		Convey("So invalid routes should be refused", func() {
+			config[tableRoutesRuleKey] = ctypes.ConfigValueStr{Value: "/intel=metrics-intel"}
+			_, err := parseConfigRoutes(config)
+			So(err, ShouldNotBeNil)
+			So(Bootstrap(config), ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,145 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"sync"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// dropSchemaPending is the drop reason of metrics evicted from a full schema pending buffer
+	dropSchemaPending = "schemaPending"
+
+	schemaRetryInitialDelay = time.Second
+	schemaRetryMaxDelay     = time.Minute
+)
+
+// schemaState tracks whether the schema of a client has been created. While
+// it is pending, incoming metrics are buffered up to a limit, evicting the
+// oldest ones, and written once the schema is ready.
+type schemaState struct {
+	mu      sync.Mutex
+	ready   bool
+	lastErr error
+	buffer  []plugin.MetricType
+	limit   int
+}
+
+func newSchemaState(limit int) *schemaState {
+	if limit < 0 {
+		limit = 0
+	}
+	return &schemaState{limit: limit}
+}
+
+// setReady marks the schema as created.
+func (s *schemaState) setReady() {
+	s.mu.Lock()
+	s.ready = true
+	s.lastErr = nil
+	s.mu.Unlock()
+}
+
+// isReady reports whether the schema is created.
+func (s *schemaState) isReady() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ready
+}
+
+// setFailed records the error of the last schema creation attempt.
+func (s *schemaState) setFailed(err error) {
+	s.mu.Lock()
+	s.lastErr = err
+	s.mu.Unlock()
+}
+
+// status returns the number of buffered metrics and the last schema creation error.
+func (s *schemaState) status() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.buffer), s.lastErr
+}
+
+// admit buffers the metrics and reports true while the schema is pending,
+// along with the number of metrics evicted from the full buffer. Once it is
+// ready, the buffered metrics are returned ahead of the given ones.
+func (s *schemaState) admit(mts []plugin.MetricType, drops *dropCounters) ([]plugin.MetricType, bool, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ready {
+		if len(s.buffer) > 0 {
+			mts = append(s.buffer, mts...)
+			s.buffer = nil
+		}
+		return mts, false, 0
+	}
+	s.buffer = append(s.buffer, mts...)
+	over := len(s.buffer) - s.limit
+	if over <= 0 {
+		return nil, true, 0
+	}
+	for i := 0; i < over; i++ {
+		drops.inc(dropSchemaPending)
+	}
+	s.buffer = append([]plugin.MetricType(nil), s.buffer[over:]...)
+	return nil, true, over
+}
+
+// setupSchema creates the schema of the client. If it fails, the creation
+// is retried in the background with an exponential backoff.
+func (cc *cassaClient) setupSchema(co clientOptions) {
+	err := createSchema(cc.currentSession(), co)
+	if err == nil {
+		cc.schema.setReady()
+		return
+	}
+	cc.schema.setFailed(err)
+	cassaLog.WithFields(log.Fields{
+		"err": err,
+	}).Error("Cassandra client schema creation error, retrying in the background")
+	go cc.retrySchema(co)
+}
+
+func (cc *cassaClient) retrySchema(co clientOptions) {
+	delay := schemaRetryInitialDelay
+	for !cc.currentSession().Closed() {
+		time.Sleep(delay)
+		err := createSchema(cc.currentSession(), co)
+		if err == nil {
+			cc.schema.setReady()
+			cassaLog.Info("Cassandra client schema created")
+			return
+		}
+		cc.schema.setFailed(err)
+		cassaLog.WithFields(log.Fields{
+			"err":   err,
+			"delay": delay,
+		}).Warn("Cassandra client schema creation error")
+
+		if delay *= 2; delay > schemaRetryMaxDelay {
+			delay = schemaRetryMaxDelay
+		}
+	}
+}
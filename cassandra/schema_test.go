@@ -0,0 +1,94 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/ctypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSchemaState(t *testing.T) {
+	Convey("Create a pending schema state", t, func() {
+		s := newSchemaState(2)
+		drops := newDropCounters()
+		metric := func(v int) plugin.MetricType {
+			return *plugin.NewMetricType(core.NewNamespace("foo"), time.Now(), nil, "", v)
+		}
+
+		Convey("So metrics should be buffered while the schema is pending", func() {
+			mts, pending, evicted := s.admit([]plugin.MetricType{metric(1)}, drops)
+			So(pending, ShouldBeTrue)
+			So(evicted, ShouldEqual, 0)
+			So(mts, ShouldBeEmpty)
+			s.setFailed(errors.New("timeout"))
+			buffered, err := s.status()
+			So(buffered, ShouldEqual, 1)
+			So(err, ShouldNotBeNil)
+		})
+		Convey("So the oldest metrics should be evicted from a full buffer", func() {
+			_, _, evicted := s.admit([]plugin.MetricType{metric(1), metric(2), metric(3)}, drops)
+			So(evicted, ShouldEqual, 1)
+			buffered, _ := s.status()
+			So(buffered, ShouldEqual, 2)
+			So(drops.snapshot()[dropSchemaPending], ShouldEqual, 1)
+
+			Convey("So buffered metrics should be returned first once the schema is ready", func() {
+				s.setReady()
+				mts, pending, _ := s.admit([]plugin.MetricType{metric(4)}, drops)
+				So(pending, ShouldBeFalse)
+				So(mts, ShouldHaveLength, 3)
+				So(mts[0].Data(), ShouldEqual, 2)
+				So(mts[2].Data(), ShouldEqual, 4)
+				buffered, err := s.status()
+				So(buffered, ShouldEqual, 0)
+				So(err, ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestSchemaBufferSize(t *testing.T) {
+	Convey("Prepare client options with a negative schemaBufferSize", t, func() {
+		config := ruleDefaults()
+		config[serverAddrRuleKey] = ctypes.ConfigValueStr{Value: serverAddress}
+		config[schemaBufferSizeRuleKey] = ctypes.ConfigValueInt{Value: -1}
+		co := prepareClientOptions(config)
+
+		Convey("So nothing should be buffered while the schema is pending", func() {
+			So(co.schemaBufferSize, ShouldEqual, 0)
+			s := newSchemaState(co.schemaBufferSize)
+			mts := []plugin.MetricType{*plugin.NewMetricType(core.NewNamespace("foo"), time.Now(), nil, "", 1)}
+			_, pending, evicted := s.admit(mts, newDropCounters())
+			So(pending, ShouldBeTrue)
+			So(evicted, ShouldEqual, 1)
+		})
+		Convey("So a negative limit should not panic either", func() {
+			mts := []plugin.MetricType{*plugin.NewMetricType(core.NewNamespace("foo"), time.Now(), nil, "", 1)}
+			So(func() { newSchemaState(-1).admit(mts, newDropCounters()) }, ShouldNotPanic)
+		})
+	})
+}
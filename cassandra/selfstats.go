@@ -0,0 +1,189 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// latencyWindow is the number of recent publishes latency percentiles are computed of.
+const latencyWindow = 1024
+
+var (
+	createSelfStatsTableCQL = `CREATE TABLE IF NOT EXISTS %s.snap_publisher_stats (
+        host text,
+        client text,
+        time timestamp,
+        published bigint,
+        errors bigint,
+        retries bigint,
+        dropped bigint,
+        p50 double,
+        p90 double,
+        p99 double,
+        PRIMARY KEY ((host, client), time)
+    ) WITH CLUSTERING ORDER BY (time DESC);`
+	insertSelfStatsCQL = `INSERT INTO %s.snap_publisher_stats (host, client, time, published, errors, retries, dropped, p50, p90, p99) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) USING TTL ?`
+)
+
+// publisherStats counts what a client wrote, so the publisher itself can be
+// monitored. Counters are totals since the client was created.
+type publisherStats struct {
+	mu        sync.Mutex
+	published uint64
+	errors    uint64
+	retries   uint64
+	// latencies of the recent publishes, used as a ring
+	latencies []time.Duration
+	next      int
+}
+
+func newPublisherStats() *publisherStats {
+	return &publisherStats{latencies: make([]time.Duration, 0, latencyWindow)}
+}
+
+// publish records a publish of written metrics, failed metrics and its latency.
+// A nil publisherStats records nothing.
+func (s *publisherStats) publish(written, failed int, latency time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.published += uint64(written)
+	s.errors += uint64(failed)
+	if len(s.latencies) < latencyWindow {
+		s.latencies = append(s.latencies, latency)
+		return
+	}
+	s.latencies[s.next] = latency
+	s.next = (s.next + 1) % latencyWindow
+}
+
+// retry records a retried insert.
+func (s *publisherStats) retry() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.retries++
+	s.mu.Unlock()
+}
+
+// statsSnapshot is a copy of the stats with the latency percentiles of the
+// recent publishes.
+type statsSnapshot struct {
+	published, errors, retries uint64
+	p50, p90, p99              time.Duration
+}
+
+func (s *publisherStats) snapshot() statsSnapshot {
+	s.mu.Lock()
+	latencies := append([]time.Duration(nil), s.latencies...)
+	snap := statsSnapshot{published: s.published, errors: s.errors, retries: s.retries}
+	s.mu.Unlock()
+
+	sort.Sort(durations(latencies))
+	snap.p50 = percentile(latencies, 50)
+	snap.p90 = percentile(latencies, 90)
+	snap.p99 = percentile(latencies, 99)
+	return snap
+}
+
+// percentile returns the p-th percentile of the sorted durations, 0 without durations.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[(len(sorted)-1)*p/100]
+}
+
+type durations []time.Duration
+
+func (d durations) Len() int           { return len(d) }
+func (d durations) Less(i, j int) bool { return d[i] < d[j] }
+func (d durations) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
+// reportStats logs the stats of the client every interval and, if table is
+// set, writes them into the snap_publisher_stats table and, if file is set,
+// into an OpenMetrics file, until the session of the client is closed.
+func (cc *cassaClient) reportStats(interval time.Duration, table bool, file string) {
+	host, _ := os.Hostname()
+	client := cc.keyspace + "." + cc.tableName
+	stmt := fmt.Sprintf(insertSelfStatsCQL, cc.names.keyspace)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		if cc.currentSession().Closed() {
+			if file != "" {
+				if err := forgetStatsFile(file, client); err != nil {
+					cassaLog.WithFields(log.Fields{
+						"err": err,
+					}).Warn("Cassandra client stats file removal error")
+				}
+			}
+			return
+		}
+		s := cc.stats.snapshot()
+		var dropped uint64
+		for _, n := range cc.drops.snapshot() {
+			dropped += n
+		}
+		cassaLog.WithFields(log.Fields{
+			"client":    client,
+			"published": s.published,
+			"errors":    s.errors,
+			"retries":   s.retries,
+			"dropped":   dropped,
+			"p50":       s.p50,
+			"p90":       s.p90,
+			"p99":       s.p99,
+		}).Info("Cassandra publisher stats")
+		if file != "" {
+			if err := writeStatsFile(file, client, statsSample{s, dropped}); err != nil {
+				cassaLog.WithFields(log.Fields{
+					"err": err,
+				}).Warn("Cassandra client stats file write error")
+			}
+		}
+		if !table || !cc.schema.isReady() {
+			continue
+		}
+		err := cc.currentSession().Query(stmt, host, client, now, int64(s.published), int64(s.errors), int64(s.retries), int64(dropped),
+			milliseconds(s.p50), milliseconds(s.p90), milliseconds(s.p99), cc.ttl).Exec()
+		if err != nil {
+			cassaLog.WithFields(log.Fields{
+				"err": err,
+			}).Warn("Cassandra client stats insertion error")
+		}
+	}
+}
+
+// milliseconds returns d in fractional milliseconds.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
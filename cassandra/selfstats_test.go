@@ -0,0 +1,84 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPublisherStats(t *testing.T) {
+	Convey("Given publisher stats", t, func() {
+		s := newPublisherStats()
+
+		Convey("Publishes and retries are counted", func() {
+			s.publish(10, 2, time.Millisecond)
+			s.publish(5, 0, 3*time.Millisecond)
+			s.retry()
+			snap := s.snapshot()
+			So(snap.published, ShouldEqual, 15)
+			So(snap.errors, ShouldEqual, 2)
+			So(snap.retries, ShouldEqual, 1)
+		})
+
+		Convey("Latency percentiles are computed of the recent publishes", func() {
+			for i := 1; i <= 100; i++ {
+				s.publish(1, 0, time.Duration(i)*time.Millisecond)
+			}
+			snap := s.snapshot()
+			So(snap.p50, ShouldEqual, 50*time.Millisecond)
+			So(snap.p90, ShouldEqual, 90*time.Millisecond)
+			So(snap.p99, ShouldEqual, 99*time.Millisecond)
+		})
+
+		Convey("Only the latencies of the window are kept", func() {
+			for i := 0; i < latencyWindow; i++ {
+				s.publish(1, 0, time.Second)
+			}
+			for i := 0; i < latencyWindow; i++ {
+				s.publish(1, 0, time.Millisecond)
+			}
+			So(s.latencies, ShouldHaveLength, latencyWindow)
+			So(s.snapshot().p99, ShouldEqual, time.Millisecond)
+		})
+
+		Convey("Without publishes the percentiles are 0", func() {
+			So(s.snapshot().p50, ShouldEqual, 0)
+		})
+	})
+
+	Convey("Given no publisher stats", t, func() {
+		var s *publisherStats
+		So(func() { s.publish(1, 0, time.Second); s.retry() }, ShouldNotPanic)
+	})
+
+	Convey("Given a read-only client", t, func() {
+		co := clientOptions{keyspace: "snap", tableName: "metrics", readOnly: true, selfStatsInterval: time.Minute}
+		cc := newCassaClient(nil, co, "")
+
+		Convey("Its stats are not reported", func() {
+			So(cc.stats, ShouldBeNil)
+			So(cc.retry.stats, ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,153 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+const (
+	// sigv4InitialResponse selects the SigV4 mechanism of Amazon Keyspaces
+	sigv4InitialResponse = "SigV4\x00\x00"
+	sigv4Service         = "cassandra"
+	sigv4TimeFormat      = "2006-01-02T15:04:05.000Z"
+)
+
+// ErrNoAWSCredentials is returned when SigV4 authentication has no credentials.
+var ErrNoAWSCredentials = errors.New("AWS credentials not found, set awsAccessKeyId and awsSecretAccessKey or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+
+// sigv4Options are the AWS credentials authenticating against Amazon Keyspaces.
+type sigv4Options struct {
+	region          string
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// withEnvironment returns the options completed by the standard AWS environment
+// variables, so credentials injected by the environment are used.
+func (o sigv4Options) withEnvironment() sigv4Options {
+	if o.accessKeyID == "" && o.secretAccessKey == "" {
+		o.accessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		o.secretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		o.sessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	return o
+}
+
+// sigv4Authenticator authenticates against Amazon Keyspaces by signing the
+// nonce sent by the server with AWS Signature Version 4.
+type sigv4Authenticator struct {
+	sigv4Options
+	// now returns the signing time, replaced in tests
+	now func() time.Time
+}
+
+// addSigV4 authenticates the cluster with SigV4 over TLS, which Amazon Keyspaces
+// requires. Without ssl options the server certificate is verified against the
+// system roots.
+func addSigV4(cluster *gocql.ClusterConfig, o sigv4Options) *gocql.ClusterConfig {
+	cluster.Authenticator = sigv4Authenticator{sigv4Options: o.withEnvironment(), now: time.Now}
+	if cluster.SslOpts == nil {
+		cluster.SslOpts = &gocql.SslOptions{EnableHostVerification: true}
+	}
+	return cluster
+}
+
+// Challenge selects the SigV4 mechanism and answers the nonce challenge of
+// the server with the signed response.
+func (a sigv4Authenticator) Challenge(req []byte) ([]byte, gocql.Authenticator, error) {
+	if a.accessKeyID == "" || a.secretAccessKey == "" {
+		return nil, nil, ErrNoAWSCredentials
+	}
+	if req == nil {
+		return []byte(sigv4InitialResponse), a, nil
+	}
+	nonce, err := sigv4Nonce(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	return []byte(a.signedResponse(nonce, a.now().UTC())), nil, nil
+}
+
+// Success is called when the authentication succeeds.
+func (a sigv4Authenticator) Success(data []byte) error {
+	return nil
+}
+
+// signedResponse returns the response to the nonce, signed at t.
+func (a sigv4Authenticator) signedResponse(nonce string, t time.Time) string {
+	amzDate := t.Format(sigv4TimeFormat)
+	scope := strings.Join([]string{t.Format("20060102"), a.region, sigv4Service, "aws4_request"}, "/")
+
+	nonceHash := sha256.Sum256([]byte(nonce))
+	query := strings.Join([]string{
+		"X-Amz-Algorithm=AWS4-HMAC-SHA256",
+		fmt.Sprintf("X-Amz-Credential=%s%%2F%s", a.accessKeyID, url.QueryEscape(scope)),
+		"X-Amz-Date=" + url.QueryEscape(amzDate),
+		"X-Amz-Expires=900",
+	}, "&")
+	canonicalRequest := fmt.Sprintf("PUT\n/authenticate\n%s\nhost:%s\n\nhost\n%s", query, sigv4Service, hex.EncodeToString(nonceHash[:]))
+
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := fmt.Sprintf("AWS4-HMAC-SHA256\n%s\n%s\n%s", amzDate, scope, hex.EncodeToString(requestHash[:]))
+
+	key := hmacSHA256([]byte("AWS4"+a.secretAccessKey), t.Format("20060102"))
+	for _, part := range []string{a.region, sigv4Service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hmacSHA256(key, stringToSign)
+
+	resp := fmt.Sprintf("signature=%s,access_key=%s,amzdate=%s", hex.EncodeToString(signature), a.accessKeyID, amzDate)
+	if a.sessionToken != "" {
+		resp += ",session_token=" + a.sessionToken
+	}
+	return resp
+}
+
+// sigv4Nonce returns the nonce of a challenge of the form "nonce=...".
+func sigv4Nonce(challenge []byte) (string, error) {
+	s := string(challenge)
+	i := strings.Index(s, "nonce=")
+	if i < 0 {
+		return "", fmt.Errorf("unexpected SigV4 challenge %q without nonce", s)
+	}
+	s = s[i+len("nonce="):]
+	if j := strings.Index(s, ","); j >= 0 {
+		s = s[:j]
+	}
+	return s, nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
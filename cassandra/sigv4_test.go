@@ -0,0 +1,114 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSigV4Authenticator(t *testing.T) {
+	Convey("Given a SigV4 authenticator", t, func() {
+		auth := sigv4Authenticator{
+			sigv4Options: sigv4Options{
+				region:          "us-east-1",
+				accessKeyID:     "AKIDEXAMPLE",
+				secretAccessKey: "secret",
+			},
+			now: func() time.Time { return time.Date(2020, 6, 9, 22, 41, 51, 0, time.UTC) },
+		}
+
+		Convey("The first challenge selects the SigV4 mechanism", func() {
+			resp, next, err := auth.Challenge(nil)
+			So(err, ShouldBeNil)
+			So(string(resp), ShouldEqual, "SigV4\x00\x00")
+			So(next, ShouldNotBeNil)
+		})
+
+		Convey("The nonce challenge is answered with a signed response", func() {
+			resp, next, err := auth.Challenge([]byte("nonce=1234abcd"))
+			So(err, ShouldBeNil)
+			So(next, ShouldBeNil)
+			So(string(resp), ShouldStartWith, "signature=")
+			So(string(resp), ShouldEndWith, ",access_key=AKIDEXAMPLE,amzdate=2020-06-09T22:41:51.000Z")
+		})
+
+		Convey("The signature depends on the nonce and is deterministic", func() {
+			at := auth.now()
+			So(auth.signedResponse("a", at), ShouldEqual, auth.signedResponse("a", at))
+			So(auth.signedResponse("a", at), ShouldNotEqual, auth.signedResponse("b", at))
+		})
+
+		Convey("A session token is appended to the response", func() {
+			auth.sessionToken = "token"
+			So(auth.signedResponse("a", auth.now()), ShouldEndWith, ",session_token=token")
+		})
+
+		Convey("A challenge without nonce is an error", func() {
+			_, _, err := auth.Challenge([]byte("garbage"))
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Missing credentials are an error", func() {
+			auth.secretAccessKey = ""
+			_, _, err := auth.Challenge(nil)
+			So(err, ShouldEqual, ErrNoAWSCredentials)
+		})
+	})
+
+	Convey("Given a challenge with several fields", t, func() {
+		nonce, err := sigv4Nonce([]byte("nonce=abc,extra=1"))
+		So(err, ShouldBeNil)
+		So(nonce, ShouldEqual, "abc")
+	})
+
+	Convey("Given credentials in the environment", t, func() {
+		os.Setenv("AWS_ACCESS_KEY_ID", "env-id")
+		os.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+		defer os.Unsetenv("AWS_ACCESS_KEY_ID")
+		defer os.Unsetenv("AWS_SECRET_ACCESS_KEY")
+
+		Convey("They are used when the config has none", func() {
+			o := sigv4Options{region: "eu-west-1"}.withEnvironment()
+			So(o.accessKeyID, ShouldEqual, "env-id")
+			So(o.secretAccessKey, ShouldEqual, "env-secret")
+		})
+
+		Convey("Configured credentials take precedence", func() {
+			o := sigv4Options{region: "eu-west-1", accessKeyID: "id", secretAccessKey: "secret"}.withEnvironment()
+			So(o.accessKeyID, ShouldEqual, "id")
+		})
+	})
+
+	Convey("Given a cluster authenticated with SigV4", t, func() {
+		cluster := addSigV4(gocql.NewCluster("cassandra.us-east-1.amazonaws.com"), sigv4Options{region: "us-east-1"})
+
+		Convey("TLS with host verification is enabled", func() {
+			So(cluster.Authenticator, ShouldHaveSameTypeAs, sigv4Authenticator{})
+			So(cluster.SslOpts, ShouldNotBeNil)
+			So(cluster.SslOpts.EnableHostVerification, ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,69 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"time"
+)
+
+// speculativePolicy starts further executions of an idempotent statement
+// when it did not complete within delay, so a slow replica does not stall a
+// publish. The vendored gocql has no speculative execution of its own.
+type speculativePolicy struct {
+	// attempts is the maximum number of speculative executions, 0 disables them
+	attempts int
+	// delay is the time waited for an execution before starting the next one
+	delay time.Duration
+}
+
+// do executes fn, which has to be safe to run concurrently, and returns the
+// first success or the error of the last execution to complete.
+func (p speculativePolicy) do(fn func() error) error {
+	if p.attempts <= 0 || p.delay <= 0 {
+		return fn()
+	}
+
+	// buffered for all executions, so late ones never block
+	results := make(chan error, p.attempts+1)
+	run := func() {
+		results <- fn()
+	}
+	go run()
+	pending, remaining := 1, p.attempts
+
+	timer := time.NewTimer(p.delay)
+	defer timer.Stop()
+	for {
+		select {
+		case err := <-results:
+			pending--
+			if err == nil || pending == 0 {
+				return err
+			}
+		case <-timer.C:
+			if remaining > 0 {
+				remaining--
+				pending++
+				go run()
+				timer.Reset(p.delay)
+			}
+		}
+	}
+}
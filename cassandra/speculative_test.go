@@ -0,0 +1,75 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSpeculativePolicy(t *testing.T) {
+	Convey("Execute statements speculatively", t, func() {
+		var executions int32
+		p := speculativePolicy{attempts: 2, delay: 10 * time.Millisecond}
+
+		Convey("So fast executions should not be repeated", func() {
+			err := p.do(func() error {
+				atomic.AddInt32(&executions, 1)
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(atomic.LoadInt32(&executions), ShouldEqual, 1)
+		})
+		Convey("So a slow execution should be overtaken by a speculative one", func() {
+			start := time.Now()
+			err := p.do(func() error {
+				if atomic.AddInt32(&executions, 1) == 1 {
+					time.Sleep(time.Second)
+				}
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(time.Since(start), ShouldBeLessThan, time.Second)
+			So(atomic.LoadInt32(&executions), ShouldEqual, 2)
+		})
+		Convey("So the error should be returned once all executions failed", func() {
+			err := p.do(func() error {
+				atomic.AddInt32(&executions, 1)
+				time.Sleep(15 * time.Millisecond)
+				return errors.New("timeout")
+			})
+			So(err, ShouldNotBeNil)
+			So(atomic.LoadInt32(&executions), ShouldBeBetweenOrEqual, 1, 3)
+		})
+		Convey("So speculation should be disabled by default", func() {
+			err := speculativePolicy{}.do(func() error {
+				atomic.AddInt32(&executions, 1)
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(atomic.LoadInt32(&executions), ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,366 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// spoolFileExt is the extension of the segments of the spool
+	spoolFileExt = ".gob"
+	// spoolRecordHeader is the size of the length preceding every record of a segment
+	spoolRecordHeader = 4
+	// spoolReplayInterval is the interval of replaying the spool
+	spoolReplayInterval = 30 * time.Second
+)
+
+// ErrSpoolFull is returned when metrics do not fit into the spool even once
+// all other metrics are evicted.
+var ErrSpoolFull = errors.New("Cassandra client spool is full")
+
+// spool keeps metrics which could not be written in segment files of a
+// directory until they are replayed. The metrics of every failed publish
+// are appended to the current segment as a record, and the segment is
+// rotated once it reached the segment size. The oldest segments are evicted
+// when the spool would exceed its maximum size.
+type spool struct {
+	mu          sync.Mutex
+	dir         string
+	maxSize     int64
+	segmentSize int64
+	size        int64
+	seq         int
+	// segment is the file records are appended to, empty once it is sealed
+	segment    string
+	segmentLen int64
+
+	// replayMu keeps one replay at a time, as clients of one spool all replay it
+	replayMu sync.Mutex
+}
+
+// spools are the spools opened by the clients, by directory, so clients
+// spooling into one directory share its size and its replay.
+var spools = struct {
+	sync.Mutex
+	byDir map[string]*spool
+}{byDir: map[string]*spool{}}
+
+// openSpool returns the spool of the directory, opening it if no client
+// has opened it yet.
+func openSpool(dir string, maxSize, segmentSize int64) (*spool, error) {
+	spools.Lock()
+	defer spools.Unlock()
+	if s, ok := spools.byDir[dir]; ok {
+		return s, nil
+	}
+	s, err := newSpool(dir, maxSize, segmentSize)
+	if err != nil {
+		return nil, err
+	}
+	spools.byDir[dir] = s
+	return s, nil
+}
+
+// newSpool creates the directory of the spool if needed and takes over the
+// segments already in it, sealed.
+func newSpool(dir string, maxSize, segmentSize int64) (*spool, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	s := &spool{dir: dir, maxSize: maxSize, segmentSize: segmentSize}
+	files, err := s.files()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if fi, err := os.Stat(f); err == nil {
+			s.size += fi.Size()
+		}
+	}
+	return s, nil
+}
+
+// spoolDir returns the directory spooling the metrics of a client, a
+// directory per cluster holding one per keyspace and table of the client.
+// The session options are hashed into its name, so only clients writing
+// alike into the table share a spool.
+func spoolDir(path string, co clientOptions) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s|%s|%s|%s", sessionKey(co), co.keyspace, co.tagsKeyspace, co.tableName)
+	table := fmt.Sprintf("%s.%s-%s", co.keyspace, co.tableName, hex.EncodeToString(h.Sum(nil))[:12])
+	return filepath.Join(path, spoolName(co.server), spoolName(table))
+}
+
+// spoolName returns the name turned into a file name.
+func spoolName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// write appends the metrics to the current segment of the spool as a
+// record, rotating the segment if it reached the segment size. The oldest
+// segments are evicted while the record does not fit into the spool, the
+// number of metrics evicted is returned.
+func (s *spool) write(mts []plugin.MetricType) (int, error) {
+	buf, err := encodeMetrics(mts)
+	if err != nil {
+		return 0, err
+	}
+	defer releaseBuffer(buf)
+	record := int64(spoolRecordHeader + buf.Len())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if record > s.maxSize {
+		return 0, ErrSpoolFull
+	}
+	evicted := 0
+	for s.size+record > s.maxSize {
+		n, err := s.evictOldest()
+		evicted += n
+		if err != nil {
+			return evicted, err
+		}
+	}
+
+	if s.segment == "" || s.segmentLen > 0 && s.segmentLen+record > s.segmentSize {
+		s.seq++
+		s.segment = filepath.Join(s.dir, fmt.Sprintf("%020d-%06d%s", time.Now().UnixNano(), s.seq, spoolFileExt))
+		s.segmentLen = 0
+	}
+	f, err := os.OpenFile(s.segment, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return evicted, err
+	}
+	header := make([]byte, spoolRecordHeader)
+	binary.BigEndian.PutUint32(header, uint32(buf.Len()))
+	if _, err = f.Write(header); err == nil {
+		_, err = f.Write(buf.Bytes())
+	}
+	if err != nil {
+		// a partial record would make the whole segment unreadable
+		f.Truncate(s.segmentLen)
+		f.Close()
+		return evicted, err
+	}
+	if err := f.Close(); err != nil {
+		return evicted, err
+	}
+	s.size += record
+	s.segmentLen += record
+	return evicted, nil
+}
+
+// evictOldest removes the oldest segment of the spool and returns its
+// number of metrics. s.mu must be held.
+func (s *spool) evictOldest() (int, error) {
+	files, err := s.glob()
+	if err != nil {
+		return 0, err
+	}
+	if len(files) == 0 {
+		// nothing is left to evict, the size was off
+		s.size = 0
+		return 0, nil
+	}
+	oldest := files[0]
+	mts, _ := s.read(oldest)
+	fi, err := os.Stat(oldest)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Remove(oldest); err != nil {
+		return 0, err
+	}
+	s.size -= fi.Size()
+	if oldest == s.segment {
+		s.segment = ""
+	}
+	return len(mts), nil
+}
+
+// files seals the current segment, so no records are appended to it while
+// it is replayed, and returns the segments of the spool, oldest first.
+func (s *spool) files() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.segment = ""
+	return s.glob()
+}
+
+// glob returns the segments of the spool, oldest first.
+func (s *spool) glob() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*"+spoolFileExt))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// read returns the metrics of the records of a segment of the spool.
+func (s *spool) read(file string) ([]plugin.MetricType, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var mts []plugin.MetricType
+	for len(data) > 0 {
+		if len(data) < spoolRecordHeader {
+			return nil, fmt.Errorf("truncated record header in %s", file)
+		}
+		n := int(binary.BigEndian.Uint32(data))
+		data = data[spoolRecordHeader:]
+		if len(data) < n {
+			return nil, fmt.Errorf("truncated record in %s", file)
+		}
+		record, err := decodeMetrics(bytes.NewReader(data[:n]))
+		if err != nil {
+			return nil, err
+		}
+		mts = append(mts, record...)
+		data = data[n:]
+	}
+	return mts, nil
+}
+
+// remove deletes a segment of the spool, unless it was evicted already.
+func (s *spool) remove(file string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fi, err := os.Stat(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil {
+		return err
+	}
+	s.size -= fi.Size()
+	if file == s.segment {
+		s.segment = ""
+	}
+	return nil
+}
+
+// spoolMetrics stores metrics which could not be written in the spool of the client.
+// Metrics which do not fit into the spool are dropped, as are the oldest
+// spooled metrics evicted for them.
+func (cc *cassaClient) spoolMetrics(mts []plugin.MetricType) error {
+	evicted, err := cc.spool.write(mts)
+	for i := 0; i < evicted; i++ {
+		cc.drops.inc(dropSpoolEvicted)
+	}
+	if evicted > 0 {
+		cassaLog.WithFields(log.Fields{
+			"evicted": evicted,
+		}).Warn("Cassandra client spool is full, the oldest spooled metrics are dropped")
+	}
+	if err != nil {
+		for range mts {
+			cc.drops.inc(dropSpoolFull)
+		}
+		cassaLog.WithFields(log.Fields{
+			"err":     err,
+			"metrics": len(mts),
+		}).Error("Cassandra client spool error, metrics are dropped")
+		return err
+	}
+	cassaLog.WithFields(log.Fields{
+		"metrics": len(mts),
+	}).Warn("Cassandra client spooled metrics which could not be written")
+	return nil
+}
+
+// replaySpool writes the spooled metrics again, oldest first, until the session is closed.
+func (cc *cassaClient) replaySpool() {
+	for !cc.currentSession().Closed() {
+		time.Sleep(spoolReplayInterval)
+		if cc.schema.isReady() {
+			cc.replaySpoolFiles()
+		}
+	}
+}
+
+// replaySpoolFiles replays the files of the spool. It stops at the first file
+// none of whose metrics could be written, as the cluster is still unreachable.
+func (cc *cassaClient) replaySpoolFiles() {
+	cc.spool.replayMu.Lock()
+	defer cc.spool.replayMu.Unlock()
+	files, err := cc.spool.files()
+	if err != nil {
+		cassaLog.WithFields(log.Fields{
+			"err": err,
+		}).Error("Cassandra client spool error")
+		return
+	}
+	for _, f := range files {
+		mts, err := cc.spool.read(f)
+		if err != nil {
+			// unreadable files would block the spool forever
+			cassaLog.WithFields(log.Fields{
+				"err":  err,
+				"file": f,
+			}).Error("Cassandra client spool file cannot be read, removing it")
+			cc.spool.remove(f)
+			continue
+		}
+
+		res := cc.writeConcurrently(mts, nil, nil)
+		if len(res.failed) == len(mts) && len(mts) > 0 {
+			return
+		}
+		if err := cc.spool.remove(f); err != nil {
+			cassaLog.WithFields(log.Fields{
+				"err":  err,
+				"file": f,
+			}).Error("Cassandra client spool error")
+			return
+		}
+		if len(res.failed) > 0 {
+			cc.spoolMetrics(res.failed)
+		}
+		cassaLog.WithFields(log.Fields{
+			"metrics": len(mts) - len(res.failed),
+		}).Info("Cassandra client replayed spooled metrics")
+	}
+}
@@ -0,0 +1,159 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSpool(t *testing.T) {
+	Convey("Spool metrics which could not be written", t, func() {
+		dir, err := ioutil.TempDir("", "cassandra-spool")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		mts := []plugin.MetricType{
+			*plugin.NewMetricType(core.NewNamespace("foo", "bar"), time.Now(), map[string]string{"a": "b"}, "", 1.5),
+			*plugin.NewMetricType(core.NewNamespace("foo", "baz"), time.Now(), nil, "", "up"),
+		}
+		// every record gets a segment of its own
+		sp, err := newSpool(dir, 1<<20, 1)
+		So(err, ShouldBeNil)
+		write := func(sp *spool, mts []plugin.MetricType) int {
+			evicted, err := sp.write(mts)
+			So(err, ShouldBeNil)
+			return evicted
+		}
+
+		Convey("So spooled metrics should be read back oldest first", func() {
+			write(sp, mts[:1])
+			write(sp, mts[1:])
+			files, err := sp.files()
+			So(err, ShouldBeNil)
+			So(len(files), ShouldEqual, 2)
+
+			read, err := sp.read(files[0])
+			So(err, ShouldBeNil)
+			So(read[0].Namespace().String(), ShouldEqual, "/foo/bar")
+			So(read[0].Data(), ShouldEqual, 1.5)
+			So(read[0].Tags()["a"], ShouldEqual, "b")
+
+			So(sp.remove(files[0]), ShouldBeNil)
+			files, _ = sp.files()
+			So(len(files), ShouldEqual, 1)
+		})
+		Convey("So the size of existing files should be taken over", func() {
+			write(sp, mts)
+			reopened, err := newSpool(dir, 1<<20, 1)
+			So(err, ShouldBeNil)
+			So(reopened.size, ShouldEqual, sp.size)
+		})
+		Convey("So records should be appended to a segment until it is rotated", func() {
+			large, err := newSpool(filepath.Join(dir, "large"), 1<<20, 1<<20)
+			So(err, ShouldBeNil)
+			write(large, mts[:1])
+			write(large, mts[1:])
+			files, err := large.files()
+			So(err, ShouldBeNil)
+			So(files, ShouldHaveLength, 1)
+			read, err := large.read(files[0])
+			So(err, ShouldBeNil)
+			So(read, ShouldHaveLength, 2)
+
+			Convey("So a replayed segment should be sealed", func() {
+				write(large, mts)
+				files, _ = large.files()
+				So(files, ShouldHaveLength, 2)
+			})
+		})
+		Convey("So the oldest segments should be evicted once the spool is full", func() {
+			write(sp, mts[:1])
+			record := sp.size
+			small, err := newSpool(filepath.Join(dir, "small"), 2*record, 1)
+			So(err, ShouldBeNil)
+			So(write(small, mts[:1]), ShouldEqual, 0)
+			So(write(small, mts[:1]), ShouldEqual, 0)
+			So(write(small, mts[:1]), ShouldEqual, 1)
+			So(small.size, ShouldEqual, 2*record)
+			files, _ := small.files()
+			So(files, ShouldHaveLength, 2)
+
+			Convey("So metrics larger than the spool should be refused", func() {
+				many := []plugin.MetricType{}
+				for i := 0; i < 20; i++ {
+					many = append(many, mts...)
+				}
+				_, err := small.write(many)
+				So(err, ShouldEqual, ErrSpoolFull)
+			})
+		})
+	})
+}
+
+func TestSpoolDir(t *testing.T) {
+	Convey("Get the spool directory of a client", t, func() {
+		co := defaultClientOptions()
+		co.server = "10.0.0.1:9042,host-2"
+		dir := spoolDir("/var/spool", co)
+		So(filepath.Dir(dir), ShouldEqual, "/var/spool/10.0.0.1_9042_host-2")
+		So(filepath.Base(dir), ShouldStartWith, "snap.metrics-")
+		So(spoolDir("/var/spool", co), ShouldEqual, dir)
+
+		Convey("So clients of other tables, keyspaces or session options should not share it", func() {
+			other := co
+			other.tableName = "metrics_1h"
+			So(filepath.Base(spoolDir("/var/spool", other)), ShouldStartWith, "snap.metrics_1h-")
+			other = co
+			other.keyspace = "other"
+			So(spoolDir("/var/spool", other), ShouldNotEqual, dir)
+			other = co
+			other.port = 9043
+			So(spoolDir("/var/spool", other), ShouldNotEqual, dir)
+		})
+	})
+}
+
+func TestOpenSpool(t *testing.T) {
+	Convey("Open the spool of a directory", t, func() {
+		dir, err := ioutil.TempDir("", "cassandra-spool")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		Convey("So clients of one directory should share its spool", func() {
+			sp, err := openSpool(dir, 1<<20, 1)
+			So(err, ShouldBeNil)
+			again, err := openSpool(dir, 1<<20, 1)
+			So(err, ShouldBeNil)
+			So(again, ShouldEqual, sp)
+			other, err := openSpool(filepath.Join(dir, "other"), 1<<20, 1)
+			So(err, ShouldBeNil)
+			So(other, ShouldNotEqual, sp)
+		})
+	})
+}
@@ -0,0 +1,71 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"sync"
+)
+
+// statementKey identifies an insert statement of a client. The columns of
+// the inserts into a table only differ by the column holding the value.
+type statementKey struct {
+	keyspace string
+	table    string
+	column   string
+	// ttl is set for statements binding the TTL of the row after the columns
+	ttl bool
+	// timestamp is set for statements binding the write timestamp after the TTL
+	timestamp bool
+}
+
+// statementCache keeps the insert statements of a client, so they are built
+// once and every insert reuses the statement gocql prepared for them.
+type statementCache struct {
+	mu    sync.RWMutex
+	stmts map[statementKey]string
+}
+
+func newStatementCache() *statementCache {
+	return &statementCache{stmts: map[statementKey]string{}}
+}
+
+// get returns the statement inserting the columns for key, building it on first use.
+func (c *statementCache) get(key statementKey, cols []column) string {
+	c.mu.RLock()
+	stmt, ok := c.stmts[key]
+	c.mu.RUnlock()
+	if ok {
+		return stmt
+	}
+
+	stmt = insertCQL(key.keyspace, key.table, cols)
+	switch {
+	case key.ttl && key.timestamp:
+		stmt += insertTTLCQL + insertAndTimestampCQL
+	case key.ttl:
+		stmt += insertTTLCQL
+	case key.timestamp:
+		stmt += insertTimestampCQL
+	}
+	c.mu.Lock()
+	c.stmts[key] = stmt
+	c.mu.Unlock()
+	return stmt
+}
@@ -0,0 +1,54 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStatementCache(t *testing.T) {
+	Convey("Get insert statements from the cache", t, func() {
+		c := newStatementCache()
+		key := statementKey{"snap", "metrics", "doubleVal", false, false}
+		stmt := c.get(key, []column{{"ns", "/foo"}, {"doubleVal", 1.0}})
+		So(stmt, ShouldEqual, "INSERT INTO snap.metrics (ns, doubleVal) VALUES (?, ?)")
+
+		Convey("So a cached statement should be reused", func() {
+			So(c.get(key, nil), ShouldEqual, stmt)
+		})
+		Convey("So another value column should get its own statement", func() {
+			other := c.get(statementKey{"snap", "metrics", "strVal", false, false}, []column{{"ns", "/foo"}, {"strVal", "a"}})
+			So(other, ShouldEqual, "INSERT INTO snap.metrics (ns, strVal) VALUES (?, ?)")
+		})
+		Convey("So statements with a TTL should bind it after the columns", func() {
+			ttl := c.get(statementKey{"snap", "tags", "doubleVal", true, false}, []column{{"ns", "/foo"}, {"doubleVal", 1.0}})
+			So(ttl, ShouldEqual, "INSERT INTO snap.tags (ns, doubleVal) VALUES (?, ?) USING TTL ?")
+		})
+		Convey("So statements with a write timestamp should bind it after the TTL", func() {
+			ts := c.get(statementKey{"snap", "metrics", "boolVal", false, true}, []column{{"ns", "/foo"}, {"boolVal", true}})
+			So(ts, ShouldEqual, "INSERT INTO snap.metrics (ns, boolVal) VALUES (?, ?) USING TIMESTAMP ?")
+			both := c.get(statementKey{"snap", "metrics", "boolVal", true, true}, []column{{"ns", "/foo"}, {"boolVal", true}})
+			So(both, ShouldEqual, "INSERT INTO snap.metrics (ns, boolVal) VALUES (?, ?) USING TTL ? AND TIMESTAMP ?")
+		})
+	})
+}
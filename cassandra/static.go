@@ -0,0 +1,146 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// insertStaticCQL writes the static columns of a partition of the metrics table.
+var insertStaticCQL = `INSERT INTO %s.%s (ns, ver, host, unit, hostTags) VALUES (?, ?, ?, ?, ?)`
+
+// insertBucketStaticCQL writes the static columns of a partition of a bucketed metrics table.
+var insertBucketStaticCQL = `INSERT INTO %s.%s (ns, ver, host, bucket, unit, hostTags) VALUES (?, ?, ?, ?, ?, ?)`
+
+// staticCQL returns the statement writing the static columns of the metrics table.
+func staticCQL(co clientOptions) string {
+	names := newCQLNames(co)
+	if co.partitionBucket > 0 {
+		return fmt.Sprintf(insertBucketStaticCQL, names.keyspace, names.table)
+	}
+	return fmt.Sprintf(insertStaticCQL, names.keyspace, names.table)
+}
+
+// staticTracker remembers the static columns written for each series, so
+// they are only written again when they change.
+type staticTracker struct {
+	mu    sync.Mutex
+	state map[string]string
+}
+
+func newStaticTracker() *staticTracker {
+	return &staticTracker{state: map[string]string{}}
+}
+
+// changed records the static columns of the series and reports whether they
+// differ from the previous ones.
+func (t *staticTracker) changed(series, statics string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	last, ok := t.state[series]
+	t.state[series] = statics
+	return !ok || last != statics
+}
+
+// forget drops the static columns of the series, so they are written again.
+func (t *staticTracker) forget(series string) {
+	t.mu.Lock()
+	delete(t.state, series)
+	t.mu.Unlock()
+}
+
+// saveStatics writes the unit and the host tags of the metric into the static
+// columns of its partition when they changed, and returns the tags of the row
+// without the host tags. The insert is added to wb.
+func (cc *cassaClient) saveStatics(wb *writeBatch, p *point, tags map[string]string) (map[string]string, error) {
+	rowTags, hostTags := splitTags(tags, cc.hostTags)
+
+	series := seriesKey(p.ns, p.m.Version(), p.host)
+	statics := staticsKey(p.m.Unit(), hostTags)
+	var err error
+	if cc.partitionBucket > 0 {
+		// every bucket is a partition of its own with its own static columns
+		bucket := bucketOf(p.m.Timestamp(), cc.partitionBucket)
+		if !cc.statics.changed(series, statics+"\x00"+bucket.String()) {
+			return rowTags, nil
+		}
+		err = wb.execIn(cc.metricsPartition(wb, p), cc.staticStmt, p.ns, p.m.Version(), p.host, bucket, p.m.Unit(), hostTags)
+	} else {
+		if !cc.statics.changed(series, statics) {
+			return rowTags, nil
+		}
+		err = wb.execIn(cc.metricsPartition(wb, p), cc.staticStmt, p.ns, p.m.Version(), p.host, p.m.Unit(), hostTags)
+	}
+	if err != nil {
+		// make sure the static columns are written with the next sample
+		cc.statics.forget(series)
+		return rowTags, err
+	}
+	return rowTags, nil
+}
+
+// splitTags returns the tags without the keys and the tags of the keys.
+// The tags are only copied when they hold any of the keys.
+func splitTags(tags map[string]string, keys []string) (map[string]string, map[string]string) {
+	picked := map[string]string{}
+	for _, k := range keys {
+		if v, ok := tags[k]; ok {
+			picked[k] = v
+		}
+	}
+	if len(picked) == 0 {
+		return tags, picked
+	}
+	rest := make(map[string]string, len(tags)-len(picked))
+	for k, v := range tags {
+		if _, ok := picked[k]; !ok {
+			rest[k] = v
+		}
+	}
+	return rest, picked
+}
+
+// staticsKey identifies the values of the static columns.
+func staticsKey(unit string, hostTags map[string]string) string {
+	keys := make([]string, 0, len(hostTags))
+	for k := range hostTags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := []string{unit}
+	for _, k := range keys {
+		parts = append(parts, k+"="+hostTags[k])
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// parseHostTags returns the tag names of a comma separated list.
+func parseHostTags(list string) []string {
+	names := []string{}
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,58 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStaticColumns(t *testing.T) {
+	Convey("Split host tags off the tags of a row", t, func() {
+		tags := map[string]string{"rack": "r1", "os": "linux", "experiment": "1"}
+
+		Convey("So the host tags should be moved out of the row tags", func() {
+			rest, picked := splitTags(tags, parseHostTags(" rack, os,"))
+			So(rest, ShouldResemble, map[string]string{"experiment": "1"})
+			So(picked, ShouldResemble, map[string]string{"rack": "r1", "os": "linux"})
+			So(tags, ShouldHaveLength, 3)
+		})
+		Convey("So tags without host tags should be kept as they are", func() {
+			rest, picked := splitTags(tags, []string{"dc"})
+			So(rest, ShouldResemble, tags)
+			So(picked, ShouldBeEmpty)
+		})
+	})
+
+	Convey("Track the static columns of series", t, func() {
+		st := newStaticTracker()
+		key := staticsKey("B", map[string]string{"rack": "r1", "os": "linux"})
+		So(key, ShouldEqual, staticsKey("B", map[string]string{"os": "linux", "rack": "r1"}))
+
+		So(st.changed("/foo|1|host1", key), ShouldBeTrue)
+		So(st.changed("/foo|1|host1", key), ShouldBeFalse)
+		So(st.changed("/foo|1|host1", staticsKey("KB", nil)), ShouldBeTrue)
+
+		st.forget("/foo|1|host1")
+		So(st.changed("/foo|1|host1", staticsKey("KB", nil)), ShouldBeTrue)
+	})
+}
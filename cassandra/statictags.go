@@ -0,0 +1,82 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+)
+
+// parseStaticTags parses tags given as a comma separated list of key:value
+// pairs, e.g. "env:prod,region:us-east".
+func parseStaticTags(s string) (map[string]string, error) {
+	tags := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, ":", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("invalid static tag '%s', expected key:value", pair)
+		}
+		tags[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return tags, nil
+}
+
+// withStaticTags returns a copy of the metric carrying the static tags it
+// does not carry itself, so tags of collectors take precedence. The
+// original metric is left untouched.
+func withStaticTags(m plugin.MetricType, static map[string]string) plugin.MetricType {
+	missing := false
+	for k := range static {
+		if _, ok := m.Tags_[k]; !ok {
+			missing = true
+			break
+		}
+	}
+	if !missing {
+		return m
+	}
+	tags := make(map[string]string, len(m.Tags_)+len(static))
+	for k, v := range static {
+		tags[k] = v
+	}
+	for k, v := range m.Tags_ {
+		tags[k] = v
+	}
+	m.Tags_ = tags
+	return m
+}
+
+// addStaticTags returns the metrics carrying the static tags of the client.
+func (cc *cassaClient) addStaticTags(mts []plugin.MetricType) []plugin.MetricType {
+	if len(cc.staticTags) == 0 {
+		return mts
+	}
+	tagged := make([]plugin.MetricType, len(mts))
+	for i, m := range mts {
+		tagged[i] = withStaticTags(m, cc.staticTags)
+	}
+	return tagged
+}
@@ -0,0 +1,91 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStaticTags(t *testing.T) {
+	Convey("Given static tags", t, func() {
+		static, err := parseStaticTags("env:prod, region:us-east,,url:http://host:80")
+		So(err, ShouldBeNil)
+		So(static, ShouldResemble, map[string]string{"env": "prod", "region": "us-east", "url": "http://host:80"})
+
+		m := *plugin.NewMetricType(core.NewNamespace("foo"), time.Now(), map[string]string{"env": "staging", "dc": "east"}, "", 1.0)
+
+		Convey("They are merged into the tags of metrics, whose own tags win", func() {
+			tagged := withStaticTags(m, static)
+			So(tagged.Tags(), ShouldResemble, map[string]string{"env": "staging", "dc": "east", "region": "us-east", "url": "http://host:80"})
+			So(m.Tags(), ShouldHaveLength, 2)
+		})
+
+		Convey("Metrics carrying all of them are kept", func() {
+			m.Tags_ = map[string]string{"env": "prod", "region": "us-east", "url": "x"}
+			So(withStaticTags(m, static).Tags(), ShouldResemble, m.Tags())
+		})
+
+		Convey("Metrics without tags get them", func() {
+			m.Tags_ = nil
+			So(withStaticTags(m, static).Tags(), ShouldResemble, static)
+		})
+	})
+
+	Convey("Given invalid static tags", t, func() {
+		_, err := parseStaticTags("env=prod")
+
+		Convey("They are rejected", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestSaveStaticTags(t *testing.T) {
+	Convey("Save a metric of a client with static tags", t, func() {
+		var tags []map[string]string
+		cc := &cassaClient{names: cqlNames{keyspace: "snap", tagsKeyspace: "snap", table: "metrics"},
+			valTypeMode: valTypeNone, statements: newStatementCache(), drops: newDropCounters(),
+			schema: newSchemaState(0), tagsTableDisabled: true, staticTags: map[string]string{"env": "prod"},
+			query: func(stmt string, values []interface{}) error {
+				for _, v := range values {
+					if t, ok := v.(map[string]string); ok {
+						tags = append(tags, t)
+					}
+				}
+				return nil
+			}}
+		cc.schema.setReady()
+		m := *plugin.NewMetricType(core.NewNamespace("foo"), time.Now(), map[string]string{"dc": "east"}, "", 1.0)
+		for r := range cc.SaveMetricsWithResults([]plugin.MetricType{m}) {
+			So(r.Success, ShouldBeTrue)
+		}
+
+		Convey("So the row should carry the static tags", func() {
+			So(tags, ShouldHaveLength, 1)
+			So(tags[0], ShouldResemble, map[string]string{"env": "prod", "dc": "east"})
+		})
+	})
+}
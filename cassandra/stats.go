@@ -0,0 +1,63 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"sync"
+)
+
+// Reasons for which the publisher drops a metric instead of writing it.
+const (
+	dropInvalidType = "invalidType"
+	dropSpoolFull   = "spoolFull"
+	dropTooOld      = "tooOld"
+
+	// dropSpoolEvicted are spooled metrics evicted for newer ones
+	dropSpoolEvicted = "spoolEvicted"
+)
+
+// dropCounters counts the metrics dropped by the publisher, per reason,
+// so users can tell dropped metrics apart from metrics never collected.
+type dropCounters struct {
+	mu     sync.Mutex
+	counts map[string]uint64
+}
+
+func newDropCounters() *dropCounters {
+	return &dropCounters{counts: map[string]uint64{}}
+}
+
+// inc records a metric dropped for the given reason.
+func (d *dropCounters) inc(reason string) {
+	d.mu.Lock()
+	d.counts[reason]++
+	d.mu.Unlock()
+}
+
+// snapshot returns a copy of the counters collected so far.
+func (d *dropCounters) snapshot() map[string]uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := make(map[string]uint64, len(d.counts))
+	for reason, n := range d.counts {
+		s[reason] = n
+	}
+	return s
+}
@@ -0,0 +1,46 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDropCounters(t *testing.T) {
+	Convey("Create drop counters", t, func() {
+		d := newDropCounters()
+		So(d.snapshot(), ShouldBeEmpty)
+
+		Convey("So dropped metrics should be counted per reason", func() {
+			d.inc(dropInvalidType)
+			d.inc(dropInvalidType)
+			d.inc("other")
+			So(d.snapshot(), ShouldResemble, map[string]uint64{dropInvalidType: 2, "other": 1})
+		})
+		Convey("So a snapshot should not change with later drops", func() {
+			s := d.snapshot()
+			d.inc(dropInvalidType)
+			So(s, ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,135 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gocql/gocql"
+)
+
+// tableProfile overrides the storage settings of the config for one metrics
+// table, e.g. the table of a rollup resolution retained longer than the raw
+// samples.
+type tableProfile struct {
+	// ttl is the seconds after which rows expire, -1 keeps the ttl of the config
+	ttl         int
+	consistency *gocql.Consistency
+	// compaction of the table when it is created, nil keeps the config's
+	compaction *compactionOptions
+}
+
+// tableProfiles are the profiles of the configured tables, by table name.
+type tableProfiles map[string]tableProfile
+
+// parseTableProfiles parses profiles given as a semicolon separated list of
+// "table: key=value ..." entries, e.g.
+// "metrics_1h: ttl=94608000 compactionWindowSize=30 consistency=QUORUM".
+// Setting a compaction window selects the TimeWindowCompactionStrategy.
+func parseTableProfiles(s string) (tableProfiles, error) {
+	profiles := tableProfiles{}
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 2)
+		table := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || !tableNamePattern.MatchString(table) {
+			return nil, fmt.Errorf("invalid table profile '%s', expected table: key=value ...", entry)
+		}
+		if _, ok := profiles[table]; ok {
+			return nil, fmt.Errorf("duplicate table profile for '%s'", table)
+		}
+		p, err := parseTableProfile(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid table profile for '%s': %v", table, err)
+		}
+		profiles[table] = p
+	}
+	return profiles, nil
+}
+
+func parseTableProfile(settings string) (tableProfile, error) {
+	p := tableProfile{ttl: -1}
+	window := compactionOptions{strategy: compactionTimeWindow, windowUnit: "DAYS", windowSize: 1}
+	for _, setting := range strings.Fields(settings) {
+		kv := strings.SplitN(setting, "=", 2)
+		if len(kv) != 2 {
+			return p, fmt.Errorf("setting '%s' is not key=value", setting)
+		}
+		key, value := kv[0], kv[1]
+		switch key {
+		case ttlRuleKey:
+			ttl, err := strconv.Atoi(value)
+			if err != nil || ttl < 0 {
+				return p, fmt.Errorf("invalid ttl '%s'", value)
+			}
+			p.ttl = ttl
+		case consistencyRuleKey:
+			consistency, err := gocql.ParseConsistencyWrapper(value)
+			if err != nil {
+				return p, err
+			}
+			p.consistency = &consistency
+		case compactionUnitRuleKey:
+			unit := strings.ToUpper(value)
+			switch unit {
+			case "MINUTES", "HOURS", "DAYS":
+			default:
+				return p, fmt.Errorf("invalid compaction window unit '%s'", value)
+			}
+			window.windowUnit = unit
+			p.compaction = &window
+		case compactionSizeRuleKey:
+			size, err := strconv.Atoi(value)
+			if err != nil || size < 1 {
+				return p, fmt.Errorf("invalid compaction window size '%s'", value)
+			}
+			window.windowSize = size
+			p.compaction = &window
+		default:
+			return p, fmt.Errorf("unknown setting '%s'", key)
+		}
+	}
+	return p, nil
+}
+
+// option returns the option applying the profile of the table, if any.
+func (ps tableProfiles) option(table string) ClientOption {
+	return func(co *clientOptions) error {
+		p, ok := ps[table]
+		if !ok {
+			return nil
+		}
+		if p.ttl >= 0 {
+			co.ttl = p.ttl
+		}
+		if p.consistency != nil {
+			co.consistency = *p.consistency
+		}
+		if p.compaction != nil {
+			co.tableCompaction = p.compaction
+		}
+		return nil
+	}
+}
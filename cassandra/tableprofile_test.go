@@ -0,0 +1,85 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+
+	"github.com/gocql/gocql"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTableProfiles(t *testing.T) {
+	Convey("Given profiles of rollup tables", t, func() {
+		profiles, err := parseTableProfiles("metrics_1m: ttl=604800 compactionWindowUnit=hours; metrics_1h: ttl=94608000 compactionWindowSize=30 consistency=QUORUM")
+		So(err, ShouldBeNil)
+		So(profiles, ShouldHaveLength, 2)
+
+		Convey("The settings of every table are parsed", func() {
+			So(profiles["metrics_1m"].ttl, ShouldEqual, 604800)
+			So(profiles["metrics_1m"].consistency, ShouldBeNil)
+			So(*profiles["metrics_1m"].compaction, ShouldResemble, compactionOptions{strategy: compactionTimeWindow, windowUnit: "HOURS", windowSize: 1})
+			So(profiles["metrics_1h"].ttl, ShouldEqual, 94608000)
+			So(*profiles["metrics_1h"].consistency, ShouldEqual, gocql.Quorum)
+			So(*profiles["metrics_1h"].compaction, ShouldResemble, compactionOptions{strategy: compactionTimeWindow, windowUnit: "DAYS", windowSize: 30})
+		})
+
+		Convey("The profile of a table overrides the config", func() {
+			co := clientOptions{ttl: 3600, consistency: gocql.One}
+			So(profiles.option("metrics_1h")(&co), ShouldBeNil)
+			So(co.ttl, ShouldEqual, 94608000)
+			So(co.consistency, ShouldEqual, gocql.Quorum)
+			So(co.tableCompaction.windowSize, ShouldEqual, 30)
+		})
+
+		Convey("Tables without profile keep the config", func() {
+			co := clientOptions{ttl: 3600, consistency: gocql.One}
+			So(profiles.option("metrics")(&co), ShouldBeNil)
+			So(co.ttl, ShouldEqual, 3600)
+			So(co.consistency, ShouldEqual, gocql.One)
+			So(co.tableCompaction, ShouldBeNil)
+		})
+	})
+
+	Convey("Given a profile without settings", t, func() {
+		profiles, err := parseTableProfiles("metrics_1h:")
+		So(err, ShouldBeNil)
+		So(profiles["metrics_1h"].ttl, ShouldEqual, -1)
+		So(profiles["metrics_1h"].compaction, ShouldBeNil)
+	})
+
+	Convey("Given invalid profiles", t, func() {
+		for _, s := range []string{
+			"metrics_1h",
+			"bad-name: ttl=1",
+			"metrics_1h: ttl=-1",
+			"metrics_1h: ttl",
+			"metrics_1h: consistency=SOME",
+			"metrics_1h: compactionWindowUnit=WEEKS",
+			"metrics_1h: compactionWindowSize=0",
+			"metrics_1h: replication=3",
+			"metrics_1h: ttl=1; metrics_1h: ttl=2",
+		} {
+			_, err := parseTableProfiles(s)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
@@ -0,0 +1,97 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"sync"
+
+	"github.com/gocql/gocql"
+)
+
+// tagPartition is a partition of the tags table.
+type tagPartition struct {
+	key string
+	val string
+}
+
+// tagRow is the insert of a row into the tags table.
+type tagRow struct {
+	stmt   string
+	values []interface{}
+}
+
+// tagBatches collects the tag rows of a publish by partition, so the rows
+// of a partition are written together instead of with every metric.
+type tagBatches struct {
+	// size is the maximum number of rows written in one batch
+	size int
+	// inFlight bounds the batches executing at once
+	inFlight inFlightLimit
+	// limit bounds the rows written per second
+	limit *rateLimit
+	// timings break down the latency of the publish, nil if not logged
+	timings *publishTimings
+
+	mu   sync.Mutex
+	rows map[tagPartition][]tagRow
+}
+
+func newTagBatches(size int) *tagBatches {
+	return &tagBatches{size: size, rows: map[tagPartition][]tagRow{}}
+}
+
+// add collects the insert of a tag row.
+func (t *tagBatches) add(key, val, stmt string, values []interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p := tagPartition{key, val}
+	t.rows[p] = append(t.rows[p], tagRow{stmt, values})
+}
+
+// flush writes the collected rows, the rows of every partition in unlogged
+// batches of at most size rows, and returns the errors of the failed batches.
+func (t *tagBatches) flush(session *gocql.Session, retry retryPolicy) []error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var errs []error
+	wb := newWriteBatch(session, t.size, retry)
+	wb.inFlight = t.inFlight
+	wb.limit = t.limit
+	wb.timings = t.timings
+	for _, rows := range t.rows {
+		for _, row := range rows {
+			if err := wb.exec(row.stmt, row.values...); err != nil {
+				errs = append(errs, err)
+			}
+			if wb.full() {
+				if err := wb.flush(); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+		// batches never span partitions
+		if err := wb.flush(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	t.rows = map[tagPartition][]tagRow{}
+	return errs
+}
@@ -0,0 +1,62 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTagBatches(t *testing.T) {
+	Convey("Collect the tag rows of a publish", t, func() {
+		cc := &cassaClient{tagsKeyspace: "snap", names: cqlNames{tagsKeyspace: "snap"}, valTypeMode: valTypeNone, statements: newStatementCache()}
+		tb := newTagBatches(10)
+		wb := &writeBatch{tags: tb}
+
+		now := time.Now()
+		for _, host := range []string{"host1", "host2"} {
+			m := *plugin.NewMetricType(core.NewNamespace("foo", "bar"), now, map[string]string{"experiment": "1", "scope": host}, "", 1.0)
+			p, err := newPoint(m)
+			So(err, ShouldBeNil)
+			for _, tag := range []string{"experiment", "scope"} {
+				So(cc.executeTagsQuery(wb, tag, p, now), ShouldBeNil)
+			}
+		}
+
+		Convey("So the rows should be grouped by partition", func() {
+			So(tb.rows, ShouldHaveLength, 3)
+			So(tb.rows[tagPartition{"experiment", "1"}], ShouldHaveLength, 2)
+			So(tb.rows[tagPartition{"scope", "host1"}], ShouldHaveLength, 1)
+		})
+		Convey("So the rows should be bound like direct inserts", func() {
+			row := tb.rows[tagPartition{"scope", "host2"}][0]
+			So(row.stmt, ShouldStartWith, "INSERT INTO snap.tags (key, val, time, ns")
+			So(row.values[:2], ShouldResemble, []interface{}{"scope", "host2"})
+		})
+		Convey("So flushing without rows should write nothing", func() {
+			So(newTagBatches(10).flush(nil, retryPolicy{}), ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,99 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"strings"
+)
+
+// tagFilter selects the tags written into the tags map columns, so tags
+// like container IDs do not blow up the size and cardinality of the rows.
+// Patterns are tag names, or prefixes of tag names followed by a '*'.
+type tagFilter struct {
+	include []string
+	exclude []string
+}
+
+// newTagFilter returns the filter of comma separated include and exclude
+// patterns, nil if neither is given.
+func newTagFilter(include, exclude string) *tagFilter {
+	f := &tagFilter{include: splitPatterns(include), exclude: splitPatterns(exclude)}
+	if len(f.include) == 0 && len(f.exclude) == 0 {
+		return nil
+	}
+	return f
+}
+
+func splitPatterns(patterns string) []string {
+	var ps []string
+	for _, p := range strings.Split(patterns, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			ps = append(ps, p)
+		}
+	}
+	return ps
+}
+
+// matchTag returns true if a pattern matches the tag name.
+func matchTag(patterns []string, tag string) bool {
+	for _, p := range patterns {
+		if p == tag || strings.HasSuffix(p, "*") && strings.HasPrefix(tag, strings.TrimSuffix(p, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// keep returns true if the tag is written, i.e. it is included, or all tags
+// are included, and it is not excluded.
+func (f *tagFilter) keep(tag string) bool {
+	if len(f.include) > 0 && !matchTag(f.include, tag) {
+		return false
+	}
+	return !matchTag(f.exclude, tag)
+}
+
+// apply returns the tags to write. A nil tagFilter keeps all tags, the tags
+// of the metric are never modified.
+func (f *tagFilter) apply(tags map[string]string) map[string]string {
+	if f == nil || tags == nil {
+		return tags
+	}
+	kept := make(map[string]string, len(tags))
+	for k, v := range tags {
+		if f.keep(k) {
+			kept[k] = v
+		}
+	}
+	return kept
+}
+
+// filterTagSet returns the tag set of the tags of ts kept by the filter, nil
+// if none is kept.
+func filterTagSet(ts *tagSet, f *tagFilter) *tagSet {
+	if ts == nil || f == nil {
+		return ts
+	}
+	tags := f.apply(ts.tags)
+	if len(tags) == 0 {
+		return nil
+	}
+	return &tagSet{id: tagSetID(tags), tags: tags}
+}
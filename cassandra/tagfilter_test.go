@@ -0,0 +1,75 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTagFilter(t *testing.T) {
+	tags := map[string]string{
+		"plugin_running_on": "host1",
+		"container_id":      "4f2a9c",
+		"container_name":    "web",
+		"dc":                "east",
+	}
+
+	Convey("Given excluded tags", t, func() {
+		f := newTagFilter("", "container_id, plugin_*")
+
+		Convey("All other tags are written", func() {
+			So(f.apply(tags), ShouldResemble, map[string]string{"container_name": "web", "dc": "east"})
+			So(tags, ShouldHaveLength, 4)
+		})
+	})
+
+	Convey("Given included tags", t, func() {
+		f := newTagFilter("container_*,dc", "container_id")
+
+		Convey("Only they are written, unless excluded", func() {
+			So(f.apply(tags), ShouldResemble, map[string]string{"container_name": "web", "dc": "east"})
+		})
+	})
+
+	Convey("Given no patterns", t, func() {
+		f := newTagFilter(" ", "")
+
+		Convey("All tags are written", func() {
+			So(f, ShouldBeNil)
+			So(f.apply(tags), ShouldResemble, tags)
+		})
+	})
+
+	Convey("Given a shared tag set", t, func() {
+		ts := &tagSet{id: tagSetID(tags), tags: tags}
+
+		Convey("The filtered tags make up a new tag set", func() {
+			filtered := filterTagSet(ts, newTagFilter("dc", ""))
+			So(filtered.tags, ShouldResemble, map[string]string{"dc": "east"})
+			So(filtered.id, ShouldNotEqual, ts.id)
+		})
+		Convey("No tag set is left if no tag is kept", func() {
+			So(filterTagSet(ts, newTagFilter("rack", "")), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,171 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"container/list"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+)
+
+// tagSetRefKey is the tag referencing the shared tag set of a metrics table row.
+const tagSetRefKey = "_tagset"
+
+var (
+	createTagSetTableCQL = "CREATE TABLE IF NOT EXISTS %s.tagsets (id text PRIMARY KEY, tags map<text,text>);"
+	insertTagSetCQL      = `INSERT INTO %s.tagsets (id, tags) VALUES (?, ?)`
+)
+
+// tagSet is a set of tags shared by all metrics of a publish, stored once
+// in the tagsets table and referenced by id from the metrics table rows.
+type tagSet struct {
+	id   string
+	tags map[string]string
+}
+
+// newTagSet returns the tag set of the tags carried with the same value by
+// all metrics, or nil if they have none in common. The host tag is never
+// shared since it is also stored in its own column.
+func newTagSet(mts []plugin.MetricType) *tagSet {
+	if len(mts) == 0 {
+		return nil
+	}
+	common := map[string]string{}
+	for k, v := range normalizeTags(mts[0].Tags()) {
+		common[k] = v
+	}
+	delete(common, core.STD_TAG_PLUGIN_RUNNING_ON)
+	for _, m := range mts[1:] {
+		tags := normalizeTags(m.Tags())
+		for k, v := range common {
+			if tv, ok := tags[k]; !ok || tv != v {
+				delete(common, k)
+			}
+		}
+	}
+	if len(common) == 0 {
+		return nil
+	}
+	return &tagSet{id: tagSetID(common), tags: common}
+}
+
+// tagSetID returns an id derived from the content of the tags, so the same
+// tag set always gets the same id.
+func tagSetID(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha1.New()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s=%s\x00", k, tags[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// compact returns the tags not found in the tag set with a reference to it.
+func (ts *tagSet) compact(tags map[string]string) map[string]string {
+	ct := map[string]string{tagSetRefKey: ts.id}
+	for k, v := range tags {
+		if sv, ok := ts.tags[k]; !ok || sv != v {
+			ct[k] = v
+		}
+	}
+	return ct
+}
+
+// tagSetCache remembers the tag sets written by the client, up to size tag
+// sets, the least recently used ones are forgotten first. A tag set is
+// written again once per interval, unless the interval is 0.
+type tagSetCache struct {
+	mu       sync.Mutex
+	size     int
+	interval time.Duration
+	entries  map[string]*list.Element
+	order    *list.List
+}
+
+type tagSetCacheEntry struct {
+	id      string
+	written time.Time
+}
+
+func newTagSetCache(size int, interval time.Duration) *tagSetCache {
+	return &tagSetCache{size: size, interval: interval, entries: map[string]*list.Element{}, order: list.New()}
+}
+
+// contains returns true if the tag set was written within the interval.
+func (c *tagSetCache) contains(id string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[id]
+	if !ok {
+		return false
+	}
+	if c.interval > 0 && now.Sub(e.Value.(*tagSetCacheEntry).written) >= c.interval {
+		return false
+	}
+	c.order.MoveToFront(e)
+	return true
+}
+
+// add records the write of the tag set. A zero sized cache remembers nothing.
+func (c *tagSetCache) add(id string, now time.Time) {
+	if c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[id]; ok {
+		e.Value.(*tagSetCacheEntry).written = now
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[id] = c.order.PushFront(&tagSetCacheEntry{id: id, written: now})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*tagSetCacheEntry).id)
+	}
+}
+
+// writeTagSet stores the tag set shared by the metrics, unless it was already
+// written, and returns it. It returns nil if the metrics share no tags.
+func (cc *cassaClient) writeTagSet(mts []plugin.MetricType) (*tagSet, error) {
+	ts := filterTagSet(newTagSet(mts), cc.tagFilter)
+	if ts == nil || cc.tagSets.contains(ts.id, time.Now()) {
+		return ts, nil
+	}
+	query := cc.currentSession().Query(fmt.Sprintf(insertTagSetCQL, cc.names.keyspace), ts.id, ts.tags)
+	if err := query.Exec(); err != nil {
+		return nil, err
+	}
+	cc.tagSets.add(ts.id, time.Now())
+	return ts, nil
+}
@@ -0,0 +1,96 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTagSet(t *testing.T) {
+	Convey("Create a tag set shared by metrics", t, func() {
+		metrics := []plugin.MetricType{
+			*plugin.NewMetricType(core.NewNamespace("foo"), time.Now(),
+				map[string]string{core.STD_TAG_PLUGIN_RUNNING_ON: "host", "dc": "east", "rack": "1", "mode": "a"}, "", 1),
+			*plugin.NewMetricType(core.NewNamespace("bar"), time.Now(),
+				map[string]string{core.STD_TAG_PLUGIN_RUNNING_ON: "host", "dc": "east", "rack": "1", "mode": "b"}, "", 2),
+		}
+		ts := newTagSet(metrics)
+
+		Convey("So it should contain the common tags except the host", func() {
+			So(ts, ShouldNotBeNil)
+			So(ts.tags, ShouldResemble, map[string]string{"dc": "east", "rack": "1"})
+			So(ts.id, ShouldEqual, tagSetID(map[string]string{"rack": "1", "dc": "east"}))
+		})
+		Convey("So compacted tags should reference it and keep the other tags", func() {
+			So(ts.compact(metrics[0].Tags()), ShouldResemble, map[string]string{
+				tagSetRefKey:                   ts.id,
+				core.STD_TAG_PLUGIN_RUNNING_ON: "host",
+				"mode":                         "a",
+			})
+		})
+		Convey("So different tag sets should get different ids", func() {
+			So(tagSetID(map[string]string{"dc": "west"}), ShouldNotEqual, ts.id)
+		})
+		Convey("So metrics without common tags should have no tag set", func() {
+			metrics[1].Tags_ = map[string]string{"dc": "west"}
+			So(newTagSet(metrics), ShouldBeNil)
+		})
+	})
+}
+
+func TestTagSetCache(t *testing.T) {
+	Convey("Given a cache of two tag sets rewritten every minute", t, func() {
+		c := newTagSetCache(2, time.Minute)
+		now := time.Now()
+		c.add("a", now)
+
+		Convey("A tag set is written again once the interval passed", func() {
+			So(c.contains("a", now.Add(30*time.Second)), ShouldBeTrue)
+			So(c.contains("a", now.Add(time.Minute)), ShouldBeFalse)
+			c.add("a", now.Add(time.Minute))
+			So(c.contains("a", now.Add(90*time.Second)), ShouldBeTrue)
+		})
+
+		Convey("The least recently used tag set is forgotten beyond the size", func() {
+			c.add("b", now)
+			So(c.contains("a", now), ShouldBeTrue)
+			c.add("c", now)
+			So(c.contains("a", now), ShouldBeTrue)
+			So(c.contains("b", now), ShouldBeFalse)
+			So(c.contains("c", now), ShouldBeTrue)
+			So(c.order.Len(), ShouldEqual, 2)
+		})
+	})
+
+	Convey("Given a zero sized cache", t, func() {
+		c := newTagSetCache(0, 0)
+
+		Convey("Every tag set is written", func() {
+			c.add("a", time.Now())
+			So(c.contains("a", time.Now()), ShouldBeFalse)
+		})
+	})
+}
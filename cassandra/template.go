@@ -0,0 +1,178 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// templatePlaceholder matches the named placeholders of schema templates, e.g. {ns}.
+var templatePlaceholder = regexp.MustCompile(`\{(\w+)\}`)
+
+// insertPlaceholders are the placeholders of insert templates bound as values.
+// The value columns are null unless the metric is stored in them.
+var insertPlaceholders = map[string]bool{
+	"ns":           true,
+	"ver":          true,
+	"host":         true,
+	"time":         true,
+	"value":        true,
+	"doubleVal":    true,
+	"strVal":       true,
+	"boolVal":      true,
+	"int64Val":     true,
+	"varintVal":    true,
+	"valType":      true,
+	"tags":         true,
+	"unit":         true,
+	"appVer":       true,
+	"checksum":     true,
+	"ttl":          true,
+	"timestamp":    true,
+	"bucket":       true,
+	"outOfOrder":   true,
+	"counterReset": true,
+}
+
+// expandTemplate replaces the placeholders of tmpl found in names by their
+// value and the ones found in binds by bind markers. It returns the statement
+// and the names of the bound placeholders, in order. Unknown placeholders are
+// refused, so typos are reported when the config is read.
+func expandTemplate(tmpl string, names map[string]string, binds map[string]bool) (string, []string, error) {
+	var bound []string
+	var unknown string
+	stmt := templatePlaceholder.ReplaceAllStringFunc(tmpl, func(ph string) string {
+		name := ph[1 : len(ph)-1]
+		if v, ok := names[name]; ok {
+			return v
+		}
+		if binds[name] {
+			bound = append(bound, name)
+			return "?"
+		}
+		if unknown == "" {
+			unknown = ph
+		}
+		return ph
+	})
+	if unknown != "" {
+		return "", nil, fmt.Errorf("unknown placeholder %s", unknown)
+	}
+	return stmt, bound, nil
+}
+
+// tableTemplateCQL returns the CREATE TABLE statement of a user-defined table
+// template, with the placeholders {keyspace}, {table}, {timeColumn} and {timeColumnType}.
+func tableTemplateCQL(tmpl string, co clientOptions) (string, error) {
+	stmt, _, err := expandTemplate(tmpl, map[string]string{
+		"keyspace":       co.keyspace,
+		"table":          co.tableName,
+		"timeColumn":     co.timeColumn,
+		"timeColumnType": co.timeColumnType,
+	}, nil)
+	return stmt, err
+}
+
+// insertTemplate is a user-defined insert into the metrics table, so metrics
+// can be written into pre-existing tables not matching the built-in layout.
+type insertTemplate struct {
+	tmpl string
+	stmt string
+	// binds are the placeholders bound to the markers of stmt, in order
+	binds []string
+}
+
+// parseInsertTemplate parses an insert template with the placeholders
+// {keyspace} and {table} and the insertPlaceholders.
+func parseInsertTemplate(tmpl, keyspace, table string) (*insertTemplate, error) {
+	stmt, binds, err := expandTemplate(tmpl, map[string]string{
+		"keyspace": keyspace,
+		"table":    table,
+	}, insertPlaceholders)
+	if err != nil {
+		return nil, err
+	}
+	return &insertTemplate{tmpl: tmpl, stmt: stmt, binds: binds}, nil
+}
+
+// forTable returns the insert template writing into another table.
+// A nil template stays nil.
+func (t *insertTemplate) forTable(keyspace, table string) *insertTemplate {
+	if t == nil {
+		return nil
+	}
+	// the template was parsed before, so it is valid
+	it, _ := parseInsertTemplate(t.tmpl, keyspace, table)
+	return it
+}
+
+// templateValue returns the value bound to a placeholder of an insert template.
+func (cc *cassaClient) templateValue(name string, p *point, tags map[string]string) interface{} {
+	switch name {
+	case "ns":
+		return p.ns
+	case "ver":
+		return p.m.Version()
+	case "host":
+		return p.host
+	case "time":
+		return cc.timeValue(p)
+	case "value":
+		return p.value
+	case "valType":
+		return valTypeNames[p.column]
+	case "tags":
+		return tags
+	case "unit":
+		return p.m.Unit()
+	case "appVer":
+		return p.m.Tags()[cc.versionTag]
+	case "checksum":
+		return checksum(p, cc.tagFilter.apply(p.m.Tags()))
+	case "ttl":
+		return cc.ttl
+	case "timestamp":
+		return writeTime(p.m.Timestamp())
+	case "bucket":
+		return bucketOf(p.m.Timestamp(), cc.partitionBucket)
+	case "outOfOrder":
+		return p.outOfOrder
+	case "counterReset":
+		return p.counterReset
+	}
+	// value columns
+	if name == p.column {
+		return p.value
+	}
+	return nil
+}
+
+// executeTemplateQuery adds the insert of the metric built from the insert template to wb.
+func (cc *cassaClient) executeTemplateQuery(wb *writeBatch, p *point, tags map[string]string) error {
+	values := make([]interface{}, len(cc.insertTemplate.binds))
+	for i, name := range cc.insertTemplate.binds {
+		values[i] = cc.templateValue(name, p, tags)
+	}
+	if consistency, ok := cc.consistencyOf(p.ns); ok {
+		return wb.execWith(consistency, cc.insertTemplate.stmt, values...)
+	}
+	return wb.exec(cc.insertTemplate.stmt, values...)
+}
@@ -0,0 +1,70 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSchemaTemplates(t *testing.T) {
+	Convey("Expand user-defined schema templates", t, func() {
+		Convey("So table templates should name the keyspace, the table and the time column", func() {
+			co := clientOptions{keyspace: "snap", tableName: "samples", timeColumn: "ts", timeColumnType: "timestamp"}
+			stmt, err := tableTemplateCQL("CREATE TABLE IF NOT EXISTS {keyspace}.{table} (name text, {timeColumn} {timeColumnType}, v double, PRIMARY KEY (name, {timeColumn}))", co)
+			So(err, ShouldBeNil)
+			So(stmt, ShouldEqual, "CREATE TABLE IF NOT EXISTS snap.samples (name text, ts timestamp, v double, PRIMARY KEY (name, ts))")
+		})
+		Convey("So insert templates should bind the standard columns in order", func() {
+			it, err := parseInsertTemplate("INSERT INTO {keyspace}.{table} (name, ts, v) VALUES ({ns}, {time}, {doubleVal}) USING TTL {ttl}", "snap", "samples")
+			So(err, ShouldBeNil)
+			So(it.stmt, ShouldEqual, "INSERT INTO snap.samples (name, ts, v) VALUES (?, ?, ?) USING TTL ?")
+			So(it.binds, ShouldResemble, []string{"ns", "time", "doubleVal", "ttl"})
+			So(it.forTable("snap", "psutil").stmt, ShouldEqual, "INSERT INTO snap.psutil (name, ts, v) VALUES (?, ?, ?) USING TTL ?")
+		})
+		Convey("So unknown placeholders should be refused", func() {
+			_, err := parseInsertTemplate("INSERT INTO {keyspace}.{table} (name) VALUES ({name})", "snap", "samples")
+			So(err, ShouldNotBeNil)
+			_, err = tableTemplateCQL("CREATE TABLE {keyspace}.{tabel} (name text PRIMARY KEY)", clientOptions{})
+			So(err, ShouldNotBeNil)
+		})
+	})
+	Convey("Bind the values of insert templates", t, func() {
+		cc := &cassaClient{ttl: 60}
+		p, err := newPoint(plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "load"),
+			Timestamp_: time.Now(),
+			Data_:      2.5,
+			Tags_:      map[string]string{core.STD_TAG_PLUGIN_RUNNING_ON: "host1"},
+		})
+		So(err, ShouldBeNil)
+		So(cc.templateValue("ns", p, nil), ShouldEqual, "/intel/load")
+		So(cc.templateValue("host", p, nil), ShouldEqual, "host1")
+		So(cc.templateValue("doubleVal", p, nil), ShouldEqual, 2.5)
+		So(cc.templateValue("strVal", p, nil), ShouldBeNil)
+		So(cc.templateValue("valType", p, nil), ShouldEqual, "double")
+		So(cc.templateValue("ttl", p, nil), ShouldEqual, 60)
+	})
+}
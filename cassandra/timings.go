@@ -0,0 +1,98 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// publishTimings break down the latency of a publish, to tell whether a
+// slow publish is spent in the plugin and the driver or in the cluster.
+// The durations are summed over the workers of the publish. A nil
+// breakdown, of publishes not logged at debug level, records nothing.
+type publishTimings struct {
+	// nanoseconds, first for their 64 bit alignment
+	decode    int64
+	convert   int64
+	queueWait int64
+	cassandra int64
+
+	writes  int64
+	retries int64
+}
+
+// newPublishTimings returns a breakdown if publishes are logged at debug level.
+func newPublishTimings() *publishTimings {
+	if log.GetLevel() < log.DebugLevel {
+		return nil
+	}
+	return &publishTimings{}
+}
+
+// addDecode records the decoding of the content of the publish.
+func (t *publishTimings) addDecode(d time.Duration) {
+	if t != nil {
+		atomic.AddInt64(&t.decode, int64(d))
+	}
+}
+
+// addConvert records the conversion of a metric into the values of its rows.
+func (t *publishTimings) addConvert(d time.Duration) {
+	if t != nil {
+		atomic.AddInt64(&t.convert, int64(d))
+	}
+}
+
+// addQueueWait records the wait of a write for the rate limit and a slot of the in-flight limit.
+func (t *publishTimings) addQueueWait(d time.Duration) {
+	if t != nil {
+		atomic.AddInt64(&t.queueWait, int64(d))
+	}
+}
+
+// addWrite records an execution of a statement or batch by Cassandra. The
+// executions after the first one of a write, retried or speculative, are
+// counted as retries.
+func (t *publishTimings) addWrite(d time.Duration, retry bool) {
+	if t == nil {
+		return
+	}
+	atomic.AddInt64(&t.cassandra, int64(d))
+	atomic.AddInt64(&t.writes, 1)
+	if retry {
+		atomic.AddInt64(&t.retries, 1)
+	}
+}
+
+// fields returns the breakdown of a publish of total duration as log fields.
+func (t *publishTimings) fields(total time.Duration) log.Fields {
+	return log.Fields{
+		"total":     total,
+		"decode":    time.Duration(atomic.LoadInt64(&t.decode)),
+		"convert":   time.Duration(atomic.LoadInt64(&t.convert)),
+		"queueWait": time.Duration(atomic.LoadInt64(&t.queueWait)),
+		"cassandra": time.Duration(atomic.LoadInt64(&t.cassandra)),
+		"writes":    atomic.LoadInt64(&t.writes),
+		"retries":   atomic.LoadInt64(&t.retries),
+	}
+}
@@ -0,0 +1,86 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	log "github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPublishTimings(t *testing.T) {
+	Convey("Break down the latency of a publish", t, func() {
+		level := log.GetLevel()
+		defer log.SetLevel(level)
+
+		Convey("So publishes not logged at debug level should not be timed", func() {
+			log.SetLevel(log.InfoLevel)
+			timings := newPublishTimings()
+			So(timings, ShouldBeNil)
+			So(func() {
+				timings.addDecode(time.Millisecond)
+				timings.addConvert(time.Millisecond)
+				timings.addQueueWait(time.Millisecond)
+				timings.addWrite(time.Millisecond, true)
+			}, ShouldNotPanic)
+		})
+		Convey("So the durations should be summed", func() {
+			log.SetLevel(log.DebugLevel)
+			timings := newPublishTimings()
+			So(timings, ShouldNotBeNil)
+			timings.addDecode(time.Millisecond)
+			timings.addConvert(2 * time.Millisecond)
+			timings.addConvert(3 * time.Millisecond)
+			timings.addWrite(10*time.Millisecond, false)
+			timings.addWrite(20*time.Millisecond, true)
+
+			fields := timings.fields(time.Second)
+			So(fields["total"], ShouldEqual, time.Second)
+			So(fields["decode"], ShouldEqual, time.Millisecond)
+			So(fields["convert"], ShouldEqual, 5*time.Millisecond)
+			So(fields["cassandra"], ShouldEqual, 30*time.Millisecond)
+			So(fields["writes"], ShouldEqual, 2)
+			So(fields["retries"], ShouldEqual, 1)
+		})
+		Convey("So the executions of a write should be timed", func() {
+			wb := newWriteBatch(nil, 1, retryPolicy{attempts: 3})
+			wb.timings = &publishTimings{}
+			calls := 0
+			err := wb.execute(1, func() error {
+				calls++
+				if calls < 2 {
+					return gocql.ErrTimeoutNoResponse
+				}
+				time.Sleep(time.Millisecond)
+				return nil
+			})
+			So(err, ShouldBeNil)
+
+			fields := wb.timings.fields(0)
+			So(fields["writes"], ShouldEqual, 2)
+			So(fields["retries"], ShouldEqual, 1)
+			So(fields["cassandra"], ShouldBeGreaterThanOrEqualTo, time.Millisecond)
+		})
+	})
+}
@@ -0,0 +1,84 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"strconv"
+	"sync"
+)
+
+var (
+	createTransitionTableCQL = "CREATE TABLE IF NOT EXISTS %s.transitions (ns text, ver int, host text, time timestamp, boolVal boolean, PRIMARY KEY ((ns, ver, host), time)) WITH CLUSTERING ORDER BY (time DESC);"
+	insertTransitionCQL      = `INSERT INTO %s.transitions (ns, ver, host, time, boolVal) VALUES (?, ?, ?, ?, ?)`
+)
+
+// transitionTracker remembers the last state of boolean series to detect
+// changes. The first sample of a series seen by the publisher always counts
+// as a change, since the state stored before a restart is not known.
+type transitionTracker struct {
+	mu    sync.Mutex
+	state map[string]bool
+}
+
+func newTransitionTracker() *transitionTracker {
+	return &transitionTracker{state: map[string]bool{}}
+}
+
+// changed records the value of the series and reports whether it differs from the previous one.
+func (t *transitionTracker) changed(series string, value bool) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	last, ok := t.state[series]
+	t.state[series] = value
+	return !ok || last != value
+}
+
+// forget drops the state of the series, so the next sample is written again.
+func (t *transitionTracker) forget(series string) {
+	t.mu.Lock()
+	delete(t.state, series)
+	t.mu.Unlock()
+}
+
+// seriesKey identifies the series of a metric: its namespace, version and host.
+func seriesKey(ns string, ver int, host string) string {
+	return ns + "|" + strconv.Itoa(ver) + "|" + host
+}
+
+// saveTransition inserts a row into the transitions table when the value of a
+// boolean metric changed. The insert is added to wb.
+func (cc *cassaClient) saveTransition(wb *writeBatch, p *point, value bool) error {
+	series := seriesKey(p.ns, p.m.Version(), p.host)
+	if !cc.transitions.changed(series, value) {
+		return nil
+	}
+	err := wb.execIn("transitions|"+series, cc.transitionStmt,
+		p.ns,
+		p.m.Version(),
+		p.host,
+		p.m.Timestamp(),
+		value)
+	if err != nil {
+		// make sure the change is written with the next sample
+		cc.transitions.forget(series)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTransitionTracker(t *testing.T) {
+	Convey("Track boolean series", t, func() {
+		tr := newTransitionTracker()
+
+		Convey("So the first sample of a series should be a change", func() {
+			So(tr.changed("a", true), ShouldBeTrue)
+			So(tr.changed("b", true), ShouldBeTrue)
+		})
+		Convey("So only different values should be changes", func() {
+			tr.changed("a", true)
+			So(tr.changed("a", true), ShouldBeFalse)
+			So(tr.changed("a", false), ShouldBeTrue)
+			So(tr.changed("a", false), ShouldBeFalse)
+		})
+		Convey("So a forgotten series should change again", func() {
+			tr.changed("a", true)
+			tr.forget("a")
+			So(tr.changed("a", true), ShouldBeTrue)
+		})
+	})
+}
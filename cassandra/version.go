@@ -0,0 +1,110 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gocql/gocql"
+)
+
+// releaseVersionCQL returns the Cassandra version of the coordinator.
+const releaseVersionCQL = "SELECT release_version FROM system.local"
+
+// serverVersion is the Cassandra version of a cluster, the zero version if
+// it is unknown. The DDL of an unknown version is the one of current
+// versions.
+type serverVersion struct {
+	major, minor, patch int
+}
+
+// parseServerVersion parses a release version like 3.11.4, 2.1.22 or
+// 4.0-beta1, ignoring any components after the patch.
+func parseServerVersion(release string) (serverVersion, error) {
+	release = strings.TrimSpace(release)
+	if i := strings.IndexAny(release, "-+ "); i >= 0 {
+		release = release[:i]
+	}
+	parts := strings.Split(release, ".")
+	nums := []int{0, 0, 0}
+	for i := 0; i < len(parts) && i < len(nums); i++ {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil || n < 0 {
+			return serverVersion{}, fmt.Errorf("invalid Cassandra release version %q", release)
+		}
+		nums[i] = n
+	}
+	if nums[0] == 0 {
+		return serverVersion{}, fmt.Errorf("invalid Cassandra release version %q", release)
+	}
+	return serverVersion{nums[0], nums[1], nums[2]}, nil
+}
+
+// queryServerVersion returns the Cassandra version of the coordinator of the session.
+func queryServerVersion(session *gocql.Session) (serverVersion, error) {
+	var release string
+	if err := session.Query(releaseVersionCQL).Scan(&release); err != nil {
+		return serverVersion{}, err
+	}
+	return parseServerVersion(release)
+}
+
+func (v serverVersion) String() string {
+	if v == (serverVersion{}) {
+		return "unknown"
+	}
+	return fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+}
+
+// before returns true if v is known and older than major.minor.patch.
+func (v serverVersion) before(major, minor, patch int) bool {
+	if v == (serverVersion{}) {
+		return false
+	}
+	if v.major != major {
+		return v.major < major
+	}
+	if v.minor != minor {
+		return v.minor < minor
+	}
+	return v.patch < patch
+}
+
+// timeWindowCompaction returns true if the version has the
+// TimeWindowCompactionStrategy, added in 3.0.8 and 3.8.
+func (v serverVersion) timeWindowCompaction() bool {
+	if v.before(3, 0, 8) {
+		return false
+	}
+	return !(!v.before(3, 1, 0) && v.before(3, 8, 0))
+}
+
+// forServer returns the compaction options supported by the version.
+// Servers without the TimeWindowCompactionStrategy get the
+// DateTieredCompactionStrategy, with the window as the size of its first
+// window.
+func (c compactionOptions) forServer(v serverVersion) compactionOptions {
+	if c.strategy != compactionTimeWindow || v.timeWindowCompaction() {
+		return c
+	}
+	return compactionOptions{strategy: compactionDateTiered, windowUnit: c.windowUnit, windowSize: c.windowSize}
+}
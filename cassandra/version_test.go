@@ -0,0 +1,80 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestServerVersion(t *testing.T) {
+	Convey("Parse Cassandra release versions", t, func() {
+		v, err := parseServerVersion("3.11.4")
+		So(err, ShouldBeNil)
+		So(v, ShouldResemble, serverVersion{3, 11, 4})
+
+		v, err = parseServerVersion("4.0-beta1")
+		So(err, ShouldBeNil)
+		So(v, ShouldResemble, serverVersion{4, 0, 0})
+
+		v, err = parseServerVersion("3.0.15.2172")
+		So(err, ShouldBeNil)
+		So(v.String(), ShouldEqual, "3.0.15")
+
+		_, err = parseServerVersion("unknown")
+		So(err, ShouldNotBeNil)
+		So(serverVersion{}.String(), ShouldEqual, "unknown")
+	})
+
+	Convey("Compare versions", t, func() {
+		So(serverVersion{2, 1, 22}.before(3, 0, 0), ShouldBeTrue)
+		So(serverVersion{3, 11, 0}.before(3, 8, 0), ShouldBeFalse)
+		So(serverVersion{3, 0, 8}.before(3, 0, 8), ShouldBeFalse)
+		So(serverVersion{}.before(3, 0, 0), ShouldBeFalse)
+	})
+
+	Convey("Detect the TimeWindowCompactionStrategy", t, func() {
+		So(serverVersion{2, 1, 22}.timeWindowCompaction(), ShouldBeFalse)
+		So(serverVersion{3, 0, 7}.timeWindowCompaction(), ShouldBeFalse)
+		So(serverVersion{3, 0, 8}.timeWindowCompaction(), ShouldBeTrue)
+		So(serverVersion{3, 7, 0}.timeWindowCompaction(), ShouldBeFalse)
+		So(serverVersion{3, 11, 4}.timeWindowCompaction(), ShouldBeTrue)
+		So(serverVersion{}.timeWindowCompaction(), ShouldBeTrue)
+	})
+
+	Convey("Generate compaction options for the server", t, func() {
+		window := compactionOptions{strategy: compactionTimeWindow, windowUnit: "HOURS", windowSize: 6}
+
+		Convey("So current servers should keep the time windows", func() {
+			So(window.forServer(serverVersion{3, 11, 4}), ShouldResemble, window)
+		})
+		Convey("So older servers should get date tiered compaction", func() {
+			c := window.forServer(serverVersion{2, 1, 22})
+			So(c.strategy, ShouldEqual, compactionDateTiered)
+			So(c.cql(), ShouldEqual, " AND compaction = {'class': 'DateTieredCompactionStrategy', 'base_time_seconds': 21600}")
+		})
+		Convey("So other strategies should be kept", func() {
+			leveled := compactionOptions{strategy: compactionLeveled}
+			So(leveled.forServer(serverVersion{2, 1, 22}), ShouldResemble, leveled)
+		})
+	})
+}
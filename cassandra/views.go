@@ -0,0 +1,119 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gocql/gocql"
+	log "github.com/sirupsen/logrus"
+)
+
+// createHostViewCQL creates a materialized view of a metrics table keyed by
+// host and time, so all metrics of a host in a time range are read from one
+// partition, or one per bucket. Cassandra maintains the view, so the
+// publisher writes the rows once.
+const createHostViewCQL = "CREATE MATERIALIZED VIEW IF NOT EXISTS %[1]s.%[2]s AS SELECT %[7]s FROM %[1]s.%[3]s WHERE %[4]s PRIMARY KEY ((%[5]s), %[6]s, ns, ver) WITH CLUSTERING ORDER BY (%[6]s DESC);"
+
+// hostViewName returns the name of the host-centric view of a table.
+func hostViewName(table string, preserve bool) string {
+	return cqlIdentifier(table+"_by_host", preserve)
+}
+
+// hostViewCQL returns the statement creating the host-centric view of the
+// metrics table configured by the options, for the version of the cluster,
+// or an empty statement if it is not created. The view selects the columns,
+// or all columns of the table if none are given.
+func hostViewCQL(co clientOptions, version serverVersion, columns []string) string {
+	// tables of a template keep their layout
+	if !co.createHostView || co.tableTemplate != "" || co.metadataOnly {
+		return ""
+	}
+	if version.before(3, 0, 0) {
+		cassaLog.WithFields(log.Fields{
+			"version": version,
+		}).Warn("Cassandra version has no materialized views, the host view is not created")
+		return ""
+	}
+	// a bucketed table keeps the partitions of the view bounded as well
+	partition := "host"
+	if co.partitionBucket > 0 {
+		partition = "host, bucket"
+	}
+	// every column of the primary key of the table must be in the one of the view
+	notNull := []string{}
+	for _, col := range []string{"host", co.timeColumn, "ns", "ver"} {
+		notNull = append(notNull, col+" IS NOT NULL")
+	}
+	if co.partitionBucket > 0 {
+		notNull = append(notNull, "bucket IS NOT NULL")
+	}
+	selected := "*"
+	if len(columns) > 0 {
+		selected = strings.Join(columns, ", ")
+	}
+	names := newCQLNames(co)
+	return fmt.Sprintf(createHostViewCQL, names.keyspace, hostViewName(co.tableName, co.preserveCase), names.table,
+		strings.Join(notNull, " AND "), partition, co.timeColumn, selected)
+}
+
+// viewColumns returns the columns of the table a view can select, which are
+// all but the static ones, or nil if the view can select all columns.
+func viewColumns(tm *gocql.TableMetadata) []string {
+	columns := []string{}
+	static := false
+	for name, col := range tm.Columns {
+		if col.Kind == gocql.ColumnStatic {
+			static = true
+			continue
+		}
+		// names of the schema metadata are exact, so they are quoted
+		columns = append(columns, cqlIdentifier(name, true))
+	}
+	if !static {
+		return nil
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// createViews creates the materialized views of the metrics table. Cassandra
+// rejects static columns in views, so the host view of a table with static
+// columns selects the other columns only.
+func createViews(session *gocql.Session, co clientOptions, version serverVersion) error {
+	var columns []string
+	if co.createHostView && co.staticColumns {
+		km, err := session.KeyspaceMetadata(schemaIdentifier(co.keyspace, co.preserveCase))
+		if err != nil {
+			return err
+		}
+		tm, ok := km.Tables[schemaIdentifier(co.tableName, co.preserveCase)]
+		if !ok {
+			return fmt.Errorf("table %s.%s not found", co.keyspace, co.tableName)
+		}
+		columns = viewColumns(tm)
+	}
+	if stmt := hostViewCQL(co, version, columns); stmt != "" {
+		return session.Query(stmt).Exec()
+	}
+	return nil
+}
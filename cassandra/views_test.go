@@ -0,0 +1,88 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHostView(t *testing.T) {
+	Convey("Given options creating the host view", t, func() {
+		co := clientOptions{keyspace: "snap", tableName: "metrics", timeColumn: "time", createHostView: true}
+
+		Convey("The view is keyed by host and time", func() {
+			So(hostViewCQL(co, serverVersion{3, 11, 4}, nil), ShouldEqual,
+				"CREATE MATERIALIZED VIEW IF NOT EXISTS snap.metrics_by_host AS SELECT * FROM snap.metrics WHERE host IS NOT NULL AND time IS NOT NULL AND ns IS NOT NULL AND ver IS NOT NULL PRIMARY KEY ((host), time, ns, ver) WITH CLUSTERING ORDER BY (time DESC);")
+		})
+
+		Convey("The view of a bucketed table is partitioned by host and bucket", func() {
+			co.partitionBucket = 24 * time.Hour
+			So(hostViewCQL(co, serverVersion{3, 11, 4}, nil), ShouldEqual,
+				"CREATE MATERIALIZED VIEW IF NOT EXISTS snap.metrics_by_host AS SELECT * FROM snap.metrics WHERE host IS NOT NULL AND time IS NOT NULL AND ns IS NOT NULL AND ver IS NOT NULL AND bucket IS NOT NULL PRIMARY KEY ((host, bucket), time, ns, ver) WITH CLUSTERING ORDER BY (time DESC);")
+		})
+
+		Convey("Names preserving their case are quoted", func() {
+			co.tableName, co.preserveCase = "Metrics", true
+			So(hostViewCQL(co, serverVersion{}, nil), ShouldStartWith, `CREATE MATERIALIZED VIEW IF NOT EXISTS "snap"."Metrics_by_host" AS SELECT * FROM "snap"."Metrics" WHERE`)
+		})
+
+		Convey("Versions without materialized views get none", func() {
+			So(hostViewCQL(co, serverVersion{2, 2, 19}, nil), ShouldBeEmpty)
+		})
+
+		Convey("Tables of a template or of metadata-only mode get none", func() {
+			co.metadataOnly = true
+			So(hostViewCQL(co, serverVersion{}, nil), ShouldBeEmpty)
+			co.metadataOnly, co.tableTemplate = false, "CREATE TABLE %s.%s (ns text PRIMARY KEY);"
+			So(hostViewCQL(co, serverVersion{}, nil), ShouldBeEmpty)
+		})
+	})
+
+	Convey("Given a table with static columns", t, func() {
+		tm := &gocql.TableMetadata{Columns: map[string]*gocql.ColumnMetadata{
+			"host":     {Name: "host", Kind: gocql.ColumnPartitionKey},
+			"time":     {Name: "time", Kind: gocql.ColumnClusteringKey},
+			"appVer":   {Name: "appVer", Kind: gocql.ColumnRegular},
+			"unit":     {Name: "unit", Kind: gocql.ColumnStatic},
+			"hosttags": {Name: "hosttags", Kind: gocql.ColumnStatic},
+		}}
+		co := clientOptions{keyspace: "snap", tableName: "metrics", timeColumn: "time", createHostView: true, staticColumns: true}
+
+		Convey("The view selects all but the static columns", func() {
+			So(hostViewCQL(co, serverVersion{3, 11, 4}, viewColumns(tm)), ShouldStartWith,
+				`CREATE MATERIALIZED VIEW IF NOT EXISTS snap.metrics_by_host AS SELECT "appVer", "host", "time" FROM snap.metrics WHERE`)
+		})
+
+		Convey("The view of a table without static columns selects all columns", func() {
+			delete(tm.Columns, "unit")
+			delete(tm.Columns, "hosttags")
+			So(viewColumns(tm), ShouldBeNil)
+		})
+	})
+
+	Convey("Given options without the host view", t, func() {
+		So(hostViewCQL(clientOptions{keyspace: "snap", tableName: "metrics", timeColumn: "time"}, serverVersion{}, nil), ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,160 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cassandra
+
+import (
+	"hash/fnv"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	log "github.com/sirupsen/logrus"
+)
+
+// workerQueueSize is the number of metrics queued for a busy worker
+const workerQueueSize = 64
+
+// writeResult sums up the metrics written by a worker.
+type writeResult struct {
+	errs    []string
+	dropped int
+
+	// failed are the metrics which could not be inserted, because of insertErrs
+	failed     []plugin.MetricType
+	insertErrs []string
+}
+
+// writeConcurrently writes the metrics with the workers of the client.
+// Metrics of a series are always written by the same worker, so the order
+// of their writes is kept. With tag batching the tag rows of all workers are
+// written grouped by partition once the metrics are written.
+func (cc *cassaClient) writeConcurrently(mts []plugin.MetricType, ts *tagSet, timings *publishTimings) writeResult {
+	var tb *tagBatches
+	if cc.tagBatchSize > 0 {
+		tb = newTagBatches(cc.tagBatchSize)
+		tb.inFlight = cc.inFlight
+		tb.limit = cc.writeLimit
+		tb.timings = timings
+		defer cc.flushTagBatches(tb)
+	}
+
+	workers := cc.adaptive.concurrency(cc.concurrency)
+	if workers <= 1 {
+		queue := make(chan plugin.MetricType, len(mts))
+		for _, m := range mts {
+			queue <- m
+		}
+		close(queue)
+		return cc.writeQueue(queue, ts, tb, timings)
+	}
+
+	queues := make([]chan plugin.MetricType, workers)
+	results := make(chan writeResult, workers)
+	for i := range queues {
+		queues[i] = make(chan plugin.MetricType, workerQueueSize)
+		go func(queue <-chan plugin.MetricType) {
+			results <- cc.writeQueue(queue, ts, tb, timings)
+		}(queues[i])
+	}
+	for _, m := range mts {
+		queues[workerIndex(m, workers)] <- m
+	}
+	for _, queue := range queues {
+		close(queue)
+	}
+
+	res := writeResult{}
+	for range queues {
+		r := <-results
+		res.errs = append(res.errs, r.errs...)
+		res.dropped += r.dropped
+		res.failed = append(res.failed, r.failed...)
+		res.insertErrs = append(res.insertErrs, r.insertErrs...)
+	}
+	return res
+}
+
+// writeQueue writes the metrics of the queue until it is closed, using a write batch of its own.
+// Tag rows are collected into tb if it is not nil.
+func (cc *cassaClient) writeQueue(queue <-chan plugin.MetricType, ts *tagSet, tb *tagBatches, timings *publishTimings) writeResult {
+	res := writeResult{}
+	wb := newWriteBatch(cc.currentSession(), cc.adaptive.batchSize(cc.batchSize), cc.retry)
+	wb.byPartition = cc.batchByPartition
+	wb.inFlight = cc.inFlight
+	wb.limit = cc.writeLimit
+	wb.adaptive = cc.adaptive
+	wb.timings = timings
+	wb.tags = tb
+	// metrics whose inserts are in the batch
+	batched := []plugin.MetricType{}
+	flush := func() {
+		if err := wb.flush(); err != nil {
+			cassaLog.WithFields(log.Fields{
+				"err": err,
+			}).Error("Cassandra client batch insertion error")
+			res.failed = append(res.failed, batched...)
+			res.insertErrs = append(res.insertErrs, err.Error())
+		}
+		batched = batched[:0]
+		// the batch is empty, so it may change its size
+		wb.size = cc.adaptive.batchSize(wb.size)
+	}
+
+	for m := range queue {
+		err := cc.saveMetric(m, ts, wb)
+		switch e := err.(type) {
+		case nil:
+			batched = append(batched, m)
+		case dropError:
+			res.dropped++
+			// metrics filtered by age are expected, not publish errors
+			if e.reason != dropTooOld {
+				res.errs = append(res.errs, err.Error())
+			}
+		case insertError:
+			res.failed = append(res.failed, m)
+			res.insertErrs = append(res.insertErrs, err.Error())
+		default:
+			batched = append(batched, m)
+			res.errs = append(res.errs, err.Error())
+		}
+		if wb.full() {
+			flush()
+		}
+	}
+	flush()
+	return res
+}
+
+// flushTagBatches writes the tag rows collected during a publish. Like other
+// inserts into the tags table, failures are only logged.
+func (cc *cassaClient) flushTagBatches(tb *tagBatches) {
+	for _, err := range tb.flush(cc.currentSession(), cc.retry) {
+		cassaLog.WithFields(log.Fields{
+			"err": err,
+		}).Error("Cassandra client tag batch insertion error")
+	}
+}
+
+// workerIndex returns the worker writing the series of the metric.
+func workerIndex(m plugin.MetricType, workers int) int {
+	h := fnv.New32a()
+	h.Write([]byte(seriesKey(m.Namespace().String(), m.Version(), m.Tags()[core.STD_TAG_PLUGIN_RUNNING_ON])))
+	return int(h.Sum32() % uint32(workers))
+}
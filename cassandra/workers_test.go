@@ -0,0 +1,71 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassandra
+
+import (
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWriteConcurrently(t *testing.T) {
+	Convey("Write metrics with workers", t, func() {
+		mts := []plugin.MetricType{}
+		for i := 0; i < 100; i++ {
+			mts = append(mts, *plugin.NewMetricType(core.NewNamespace("foo", "bar"), time.Now(), nil, "", []int{i}))
+		}
+		for _, workers := range []int{1, 4} {
+			cc := &cassaClient{drops: newDropCounters(), concurrency: workers}
+			res := cc.writeConcurrently(mts, nil, nil)
+			So(res.dropped, ShouldEqual, 100)
+			So(len(res.errs), ShouldEqual, 100)
+		}
+	})
+}
+
+func TestMaxMetricAge(t *testing.T) {
+	Convey("Drop metrics older than the maximum age", t, func() {
+		mts := []plugin.MetricType{
+			*plugin.NewMetricType(core.NewNamespace("foo"), time.Now().Add(-2*time.Hour), nil, "", 1),
+			*plugin.NewMetricType(core.NewNamespace("foo"), time.Now().Add(-3*time.Hour), nil, "", 2),
+		}
+		cc := &cassaClient{drops: newDropCounters(), concurrency: 1, maxMetricAge: time.Hour}
+		res := cc.writeConcurrently(mts, nil, nil)
+		So(res.dropped, ShouldEqual, 2)
+		So(res.errs, ShouldBeEmpty)
+		So(cc.drops.snapshot()[dropTooOld], ShouldEqual, 2)
+	})
+}
+
+func TestWorkerIndex(t *testing.T) {
+	Convey("Assign metrics to workers", t, func() {
+		m := *plugin.NewMetricType(core.NewNamespace("foo", "bar"), time.Now(), map[string]string{core.STD_TAG_PLUGIN_RUNNING_ON: "host1"}, "", 1)
+		Convey("So the metrics of a series should go to the same worker", func() {
+			i := workerIndex(m, 8)
+			So(i, ShouldBeBetweenOrEqual, 0, 7)
+			m.Timestamp_ = time.Now().Add(time.Second)
+			So(workerIndex(m, 8), ShouldEqual, i)
+		})
+	})
+}
@@ -20,15 +20,109 @@ limitations under the License.
 package main
 
 import (
+	"errors"
+	"fmt"
 	"os"
 
 	"github.com/intelsdi-x/snap-plugin-publisher-cassandra/cassandra"
 	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+const (
+	bootstrapUsage      = "usage: snap-plugin-publisher-cassandra bootstrap <config.json>"
+	externalTablesUsage = "usage: snap-plugin-publisher-cassandra external-tables <spark|presto> <config.json>"
+	lintUsage           = "usage: snap-plugin-publisher-cassandra lint <config.json>"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "bootstrap" {
+		if err := bootstrap(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "external-tables" {
+		if err := externalTables(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "lint" {
+		if err := lint(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	meta := cassandra.Meta()
 	pub := cassandra.NewCassandraPublisher()
 	plugin.Start(meta, pub, os.Args[1])
 	defer pub.Close()
 }
+
+// bootstrap creates the schema for the publisher config in the file given as argument.
+func bootstrap(args []string) error {
+	if len(args) != 1 {
+		return errors.New(bootstrapUsage)
+	}
+	config, err := loadConfig(args[0])
+	if err != nil {
+		return err
+	}
+	return cassandra.Bootstrap(config)
+}
+
+// externalTables prints the Spark or Presto table definitions for the
+// publisher config in the file given as argument.
+func externalTables(args []string) error {
+	if len(args) != 2 {
+		return errors.New(externalTablesUsage)
+	}
+	config, err := loadConfig(args[1])
+	if err != nil {
+		return err
+	}
+	tables, err := cassandra.ExternalTables(config, args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Print(tables)
+	return nil
+}
+
+// lint prints the warnings about the publisher config in the file given as
+// argument, failing if there are any.
+func lint(args []string) error {
+	if len(args) != 1 {
+		return errors.New(lintUsage)
+	}
+	config, err := loadConfig(args[0])
+	if err != nil {
+		return err
+	}
+	warnings, err := cassandra.LintConfig(config)
+	if err != nil {
+		return err
+	}
+	for _, w := range warnings {
+		fmt.Println("warning:", w)
+	}
+	if len(warnings) > 0 {
+		return fmt.Errorf("%d warnings", len(warnings))
+	}
+	return nil
+}
+
+// loadConfig reads the publisher config from the file.
+func loadConfig(path string) (map[string]ctypes.ConfigValue, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return cassandra.LoadConfig(f)
+}
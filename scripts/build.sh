@@ -30,10 +30,14 @@ __proj_dir="$(dirname "$__dir")"
 
 plugin_name=${__proj_dir##*/}
 build_dir="${__proj_dir}/build"
-go_build=(go build -ldflags "-w")
+build_version=$(git -C "${__proj_dir}" describe --tags --always 2>/dev/null || echo dev)
+git_commit=$(git -C "${__proj_dir}" rev-parse --short HEAD 2>/dev/null || echo unknown)
+build_pkg="github.com/intelsdi-x/snap-plugin-publisher-cassandra/cassandra"
+go_build=(go build -ldflags "-w -X ${build_pkg}.buildVersion=${build_version} -X ${build_pkg}.gitCommit=${git_commit}")
 
 _info "project path: ${__proj_dir}"
 _info "plugin name: ${plugin_name}"
+_info "build version: ${build_version} (${git_commit})"
 
 export CGO_ENABLED=0
 